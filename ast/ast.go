@@ -73,6 +73,46 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// TypeStatement declares a struct type: type Vec = struct { x, y }.
+type TypeStatement struct {
+	Token  token.Token // the token.TYPE token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ts *TypeStatement) statementNode()       {}
+func (ts *TypeStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TypeStatement) String() string {
+	fields := make([]string, len(ts.Fields))
+	for i, f := range ts.Fields {
+		fields[i] = f.String()
+	}
+	return "type " + ts.Name.String() + " = struct { " + strings.Join(fields, ", ") + " }"
+}
+
+// EnumStatement declares a group of named integer constants:
+// enum State { Idle, Walk = 5 }. Values[i] is nil when member i takes the
+// value after the previous one.
+type EnumStatement struct {
+	Token   token.Token // the token.ENUM token
+	Name    *Identifier
+	Members []*Identifier
+	Values  []Expression
+}
+
+func (es *EnumStatement) statementNode()       {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) String() string {
+	members := make([]string, len(es.Members))
+	for i, m := range es.Members {
+		members[i] = m.String()
+		if es.Values[i] != nil {
+			members[i] += " = " + es.Values[i].String()
+		}
+	}
+	return "enum " + es.Name.String() + " { " + strings.Join(members, ", ") + " }"
+}
+
 type ShortVarDeclaration struct {
 	Token token.Token // the := token
 	Names []*Identifier
@@ -99,6 +139,31 @@ func (sv *ShortVarDeclaration) String() string {
 	return out.String()
 }
 
+// ConstStatement declares an immutable name: const NAME = value
+type ConstStatement struct {
+	Token token.Token // the token.CONST token
+	Name  *Identifier
+	Value Expression
+}
+
+func (cs *ConstStatement) statementNode()       {}
+func (cs *ConstStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ConstStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(cs.TokenLiteral() + " ")
+	out.WriteString(cs.Name.String())
+	out.WriteString(" = ")
+
+	if cs.Value != nil {
+		out.WriteString(cs.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
 type ReturnStatement struct {
 	Token       token.Token // the 'return' token
 	ReturnValue Expression
@@ -255,6 +320,8 @@ func (ie *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // The 'func' token
 	Parameters []*Identifier
+	Defaults   []Expression // Default values by parameter, nil for a required parameter
+	Variadic   bool         // The last parameter collects any extra arguments
 	Body       *BlockStatement
 	Name       string
 }
@@ -265,8 +332,14 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		param := p.String()
+		if fl.Variadic && i == len(fl.Parameters)-1 {
+			param = "..." + param
+		} else if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			param += " = " + fl.Defaults[i].String()
+		}
+		params = append(params, param)
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -282,10 +355,58 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// SpreadExpression passes the elements of an array or tuple as separate
+// arguments: f(...args).
+type SpreadExpression struct {
+	Token token.Token // The '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// YieldExpression suspends the enclosing generator function, handing Value
+// (null when omitted) to whoever resumed it. It evaluates to the value passed
+// to the next resume.
+type YieldExpression struct {
+	Token token.Token // The 'yield' token
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	if ye.Value == nil {
+		return "yield"
+	}
+	return "yield " + ye.Value.String()
+}
+
+// InterpolatedString is a string with embedded expressions, "hp: ${hp}". It
+// is evaluated by the format builtin with Format as the layout, which the
+// compiler refers to directly so that a variable named format cannot shadow it.
+type InterpolatedString struct {
+	Token  token.Token // The first string part
+	Format string      // The literal parts joined by %v, with % escaped
+	Values []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	args := []string{is.Format}
+	for _, v := range is.Values {
+		args = append(args, v.String())
+	}
+	return "format(" + strings.Join(args, ", ") + ")"
+}
+
 type CallExpression struct {
-	Token     token.Token // The '(' token
+	Token     token.Token // The '(' token, or '?.' for f?.(args)
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Optional  bool // f?.(args): null instead of a call when Function is null
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -299,6 +420,9 @@ func (ce *CallExpression) String() string {
 	}
 
 	out.WriteString(ce.Function.String())
+	if ce.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -374,9 +498,10 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ or ?[ token
+	Left     Expression
+	Index    Expression
+	Optional bool // left?[index]: null instead of an index when Left is null
 }
 
 func (ie *IndexExpression) expressionNode()      {}
@@ -386,6 +511,9 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -393,6 +521,71 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// MemberExpression is dot access: left.name
+type MemberExpression struct {
+	Token token.Token // The . token
+	Left  Expression
+	Name  *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return "(" + me.Left.String() + "." + me.Name.String() + ")"
+}
+
+type MemberAssignExpression struct {
+	Token token.Token // The '=' token
+	Left  *MemberExpression
+	Value Expression
+}
+
+func (ae *MemberAssignExpression) expressionNode()      {}
+func (ae *MemberAssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *MemberAssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ae.Left.String())
+	out.WriteString(" = ")
+	out.WriteString(ae.Value.String())
+
+	return out.String()
+}
+
+// CompoundAssignExpression applies an arithmetic operator to an assignable
+// target in place: x += 1, arr[i] -= 2, obj.hp *= 3.
+type CompoundAssignExpression struct {
+	Token    token.Token // The operator token, e.g. '+='
+	Target   Expression  // *Identifier, *IndexExpression or *MemberExpression
+	Operator string
+	Value    Expression
+}
+
+func (ce *CompoundAssignExpression) expressionNode()      {}
+func (ce *CompoundAssignExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CompoundAssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ce.Target.String())
+	out.WriteString(" " + ce.Operator + " ")
+	out.WriteString(ce.Value.String())
+
+	return out.String()
+}
+
+// IncDecExpression is x++ or x--, shorthand for x += 1 and x -= 1.
+type IncDecExpression struct {
+	Token    token.Token // The '++' or '--' token
+	Target   Expression  // *Identifier, *IndexExpression or *MemberExpression
+	Operator string
+}
+
+func (ie *IncDecExpression) expressionNode()      {}
+func (ie *IncDecExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IncDecExpression) String() string {
+	return ie.Target.String() + ie.Operator
+}
+
 type SliceExpression struct {
 	Token token.Token // The [ token
 	Left  Expression
@@ -458,6 +651,7 @@ func (n *NullLiteral) String() string       { return "null" }
 
 type ForStatement struct {
 	Token     token.Token
+	Label     string // Optional label for labeled break/continue
 	Init      Statement
 	Condition Expression
 	Post      Statement
@@ -473,6 +667,7 @@ func (fs *ForStatement) String() string {
 
 type RangeStatement struct {
 	Token    token.Token
+	Label    string // Optional label for labeled break/continue
 	Key      *Identifier
 	Value    *Identifier
 	Iterable Expression
@@ -484,3 +679,131 @@ func (rs *RangeStatement) TokenLiteral() string { return rs.Token.Literal }
 func (rs *RangeStatement) String() string {
 	return "range()"
 }
+
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+	Label *Identifier // Optional (can be nil)
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) String() string {
+	if bs.Label != nil {
+		return "break " + bs.Label.String() + ";"
+	}
+	return "break;"
+}
+
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+	Label *Identifier // Optional (can be nil)
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) String() string {
+	if cs.Label != nil {
+		return "continue " + cs.Label.String() + ";"
+	}
+	return "continue;"
+}
+
+// TryStatement runs Block and hands any runtime error to the catch block.
+// Catch and Finally are each optional, but at least one is present.
+type TryStatement struct {
+	Token      token.Token // the 'try' token
+	Block      *BlockStatement
+	CatchParam *Identifier // Optional (can be nil)
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (ts *TryStatement) statementNode()       {}
+func (ts *TryStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TryStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(ts.Block.String())
+	if ts.Catch != nil {
+		out.WriteString(" catch ")
+		if ts.CatchParam != nil {
+			out.WriteString("(" + ts.CatchParam.String() + ") ")
+		}
+		out.WriteString(ts.Catch.String())
+	}
+	if ts.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(ts.Finally.String())
+	}
+
+	return out.String()
+}
+
+// SwitchStatement runs the body of the first case with a value equal to
+// Subject, or Default if none matches. Without a Subject every case value is
+// a condition. In a type switch (switch x is { ... }) case values name types,
+// as on the right side of 'is'.
+type SwitchStatement struct {
+	Token      token.Token // the 'switch' token
+	Subject    Expression  // Optional (can be nil)
+	TypeSwitch bool
+	Cases      []*SwitchCase
+	Default    *BlockStatement // Optional (can be nil)
+}
+
+func (ss *SwitchStatement) statementNode()       {}
+func (ss *SwitchStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *SwitchStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("switch ")
+	if ss.Subject != nil {
+		out.WriteString(ss.Subject.String() + " ")
+	}
+	if ss.TypeSwitch {
+		out.WriteString("is ")
+	}
+	out.WriteString("{ ")
+	for _, c := range ss.Cases {
+		out.WriteString(c.String() + " ")
+	}
+	if ss.Default != nil {
+		out.WriteString("default: " + ss.Default.String() + " ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// SwitchCase is one 'case v1, v2: body' clause of a SwitchStatement.
+type SwitchCase struct {
+	Token  token.Token // the 'case' token
+	Values []Expression
+	Body   *BlockStatement
+}
+
+func (sc *SwitchCase) String() string {
+	values := []string{}
+	for _, v := range sc.Values {
+		values = append(values, v.String())
+	}
+	return "case " + strings.Join(values, ", ") + ": " + sc.Body.String()
+}
+
+// ImportStatement binds the namespace of a module: import "path" [as Alias]
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+	Alias *Identifier // Optional (can be nil)
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	out := "import \"" + is.Path.Value + "\""
+	if is.Alias != nil {
+		out += " as " + is.Alias.String()
+	}
+	return out + ";"
+}
@@ -91,6 +91,23 @@ func (r *RedisStorage) Delete(ctx context.Context, name string) error {
 	return r.client.Del(ctx, r.prefix+name).Err()
 }
 
+// StorageLoader resolves import paths by loading scripts from a ScriptStorage,
+// so stored scripts can import each other by name.
+type StorageLoader struct {
+	ctx     context.Context
+	storage ScriptStorage
+}
+
+// NewStorageLoader returns a compiler.ModuleLoader backed by storage. ctx is
+// used for every Load issued while compiling.
+func NewStorageLoader(ctx context.Context, storage ScriptStorage) *StorageLoader {
+	return &StorageLoader{ctx: ctx, storage: storage}
+}
+
+func (l *StorageLoader) Load(path string) (string, error) {
+	return l.storage.Load(l.ctx, path)
+}
+
 // NewService creates a new script service with the given storage
 func NewService(storage ScriptStorage, opts ...Option) *Service {
 	svc := &Service{
@@ -183,7 +200,7 @@ func (s *Service) Test(ctx context.Context, content string) (*TestResult, error)
 		}, nil
 	}
 
-	c := compiler.New()
+	c := compiler.New(compiler.WithModuleLoader(NewStorageLoader(ctx, s.storage)))
 	err := c.Compile(program)
 	if err != nil {
 		return &TestResult{
@@ -2,6 +2,7 @@ package auxlib
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -93,3 +94,59 @@ func TestWithVmTestHarness(t *testing.T) {
 		t.Errorf("Test harness was not called")
 	}
 }
+
+// memoryStorage is an in-memory ScriptStorage for tests that should not
+// depend on a running redis.
+type memoryStorage map[string]string
+
+func (m memoryStorage) List(ctx context.Context) ([]string, error) {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (m memoryStorage) Load(ctx context.Context, name string) (string, error) {
+	content, ok := m[name]
+	if !ok {
+		return "", fmt.Errorf("script not found: %s", name)
+	}
+	return content, nil
+}
+
+func (m memoryStorage) Save(ctx context.Context, name, content string) error {
+	m[name] = content
+	return nil
+}
+
+func (m memoryStorage) Delete(ctx context.Context, name string) error {
+	delete(m, name)
+	return nil
+}
+
+func TestService_TestImport(t *testing.T) {
+	storage := memoryStorage{
+		"utils": `func Double(n) { return n * 2 }`,
+	}
+	svc := NewService(storage)
+
+	res, err := svc.Test(context.Background(), `import "utils"; print(utils.Double(21))`)
+	if err != nil {
+		t.Fatalf("Test() unexpected error: %v", err)
+	}
+	if res.Error != "" {
+		t.Fatalf("Unexpected error in result: %s", res.Error)
+	}
+	if !strings.Contains(res.Output, "42") {
+		t.Errorf("Output mismatch. want substring %q, got %q", "42", res.Output)
+	}
+
+	res, err = svc.Test(context.Background(), `import "missing"`)
+	if err != nil {
+		t.Fatalf("Test() unexpected error: %v", err)
+	}
+	if !strings.Contains(res.Error, `cannot import "missing": script not found: missing`) {
+		t.Errorf("Expected import error, got %q", res.Error)
+	}
+}
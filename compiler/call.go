@@ -0,0 +1,81 @@
+package compiler
+
+import (
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+// compileDefaults emits the code that assigns the default values of a
+// function's optional parameters, one after the other at the start of the
+// function, and returns the entry offsets for object.CompiledFunction: the
+// offset of each initializer followed by the start of the body. The VM enters
+// past the initializers of the parameters a call passed.
+func (c *Compiler) compileDefaults(node *ast.FunctionLiteral) ([]int, error) {
+	var entries []int
+	for i, value := range node.Defaults {
+		if value == nil {
+			continue
+		}
+
+		entries = append(entries, len(c.currentInstructions()))
+		err := c.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		// Parameters occupy the first local slots in order
+		c.emitSetLocal(i, true)
+	}
+
+	if entries != nil {
+		entries = append(entries, len(c.currentInstructions()))
+	}
+	return entries, nil
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSpreadArguments pushes the arguments of a call that spreads an array
+// or tuple as a list of arrays: each run of plain arguments is collected by
+// OpArray and each spread value is pushed as is. OpCallSpread flattens them.
+func (c *Compiler) compileSpreadArguments(args []ast.Expression) error {
+	segments := 0
+	plain := 0
+	flush := func() {
+		if plain > 0 {
+			c.emit(opcode.OpArray, plain)
+			segments++
+			plain = 0
+		}
+	}
+
+	for _, arg := range args {
+		spread, ok := arg.(*ast.SpreadExpression)
+		if !ok {
+			err := c.Compile(arg)
+			if err != nil {
+				return err
+			}
+			plain++
+			continue
+		}
+
+		flush()
+		c.lastLine = spread.Token.Line
+		err := c.Compile(spread.Value)
+		if err != nil {
+			return err
+		}
+		segments++
+	}
+	flush()
+
+	c.emit(opcode.OpCallSpread, segments)
+	return nil
+}
@@ -0,0 +1,103 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+func TestDefaultParameters(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `func(a, b = 1, c = 2) { return c }`,
+			expectedConstants: []any{
+				1,
+				2,
+				[]code{
+					{opcode.OpConstant, []int{0}}, // 0000 entry when neither b nor c is passed
+					{opcode.OpSetLocal, []int{1}},
+					{opcode.OpConstant, []int{1}}, // 0005 entry when only b is passed
+					{opcode.OpSetLocal, []int{2}},
+					{opcode.OpGetLocal, []int{2}}, // 0010 entry when both are passed
+					{opcode.OpReturnValue, []int{}},
+				},
+			},
+			expectedInstructions: []code{
+				{opcode.OpClosure, []int{2, 0}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionParameterMetadata(t *testing.T) {
+	tests := []struct {
+		input       string
+		numParams   int
+		numDefaults int
+		numRequired int
+		variadic    bool
+		entries     []int
+	}{
+		{"func(a, b) {}", 2, 0, 2, false, nil},
+		{"func(a, b = 1, c = 2) {}", 3, 2, 1, false, []int{0, 5, 10}},
+		{"func(a, ...rest) {}", 2, 0, 1, true, nil},
+		{"func(a = 1, ...rest) {}", 2, 1, 0, true, []int{0, 5}},
+	}
+
+	for _, tt := range tests {
+		comp := New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		var fn *object.CompiledFunction
+		for _, constant := range comp.Bytecode().Constants {
+			if f, ok := constant.(*object.CompiledFunction); ok {
+				fn = f
+			}
+		}
+		if fn == nil {
+			t.Fatalf("%q: no compiled function in constants", tt.input)
+		}
+
+		if fn.NumParameters != tt.numParams || fn.NumDefaults != tt.numDefaults ||
+			fn.NumRequired() != tt.numRequired || fn.Variadic != tt.variadic {
+			t.Errorf("%q: wrong metadata. got params=%d defaults=%d required=%d variadic=%t",
+				tt.input, fn.NumParameters, fn.NumDefaults, fn.NumRequired(), fn.Variadic)
+		}
+		if len(fn.Entries) != len(tt.entries) {
+			t.Fatalf("%q: wrong entries. want=%v, got=%v", tt.input, tt.entries, fn.Entries)
+		}
+		for i, entry := range tt.entries {
+			if fn.Entries[i] != entry {
+				t.Errorf("%q: wrong entries. want=%v, got=%v", tt.input, tt.entries, fn.Entries)
+			}
+		}
+	}
+}
+
+func TestSpreadCall(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `var xs = [2]; len(1, ...xs, 3)`,
+			expectedConstants: []any{2, 1, 3},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpArray, []int{1}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpGetBuiltin, []int{0}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpArray, []int{1}},
+				{opcode.OpGetGlobal, []int{0}},
+				{opcode.OpConstant, []int{2}},
+				{opcode.OpArray, []int{1}},
+				{opcode.OpCallSpread, []int{3}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
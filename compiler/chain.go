@@ -0,0 +1,54 @@
+package compiler
+
+import (
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+// nullChain collects the null jumps of a chain of member, index, call and
+// slice expressions such as m?["a"]["b"](x). A null before ?[ or ?. skips the
+// whole rest of the chain, so every jump lands at the end of the outermost
+// link.
+type nullChain struct {
+	jumps []int
+}
+
+// beginChainLink starts compiling a link of a chain. A link compiled as the
+// left side of another link joins that link's chain; any other link starts a
+// chain of its own, which it must close with endChain.
+func (c *Compiler) beginChainLink() (chain *nullChain, outermost bool) {
+	chain = c.chain
+	c.chain = nil
+	if chain == nil {
+		return &nullChain{}, true
+	}
+	return chain, false
+}
+
+// compileChainLeft compiles the left side of a link. Only another link
+// continues the chain; the operands of anything else are separate expressions.
+func (c *Compiler) compileChainLeft(left ast.Expression, chain *nullChain) error {
+	switch left.(type) {
+	case *ast.MemberExpression, *ast.IndexExpression, *ast.SliceExpression, *ast.CallExpression:
+		c.chain = chain
+	}
+	err := c.Compile(left)
+	c.chain = nil
+	return err
+}
+
+// emitChainJumpNull skips the rest of the chain, leaving null as its result,
+// if the value on top of the stack is null.
+func (c *Compiler) emitChainJumpNull(chain *nullChain) {
+	c.emit(opcode.OpDup)
+	chain.jumps = append(chain.jumps, c.emit(opcode.OpJumpNull, 9999))
+}
+
+func (c *Compiler) endChain(chain *nullChain, outermost bool) {
+	if !outermost {
+		return
+	}
+	for _, pos := range chain.jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+}
@@ -18,6 +18,32 @@ type Compiler struct {
 	lastLine   int
 
 	symbolDefinitions map[ast.Node][]Symbol
+
+	fileName  string
+	loader    ModuleLoader
+	modules   map[string]Symbol // import path -> global holding the module
+	importing []string          // modules being compiled, for cycle detection
+
+	chain *nullChain // optional chain the expression being compiled continues
+}
+
+// Option configures optional compiler behavior at construction time.
+type Option func(*Compiler)
+
+// WithFileName sets the file name reported in runtime errors raised by the
+// compiled script.
+func WithFileName(name string) Option {
+	return func(c *Compiler) {
+		c.fileName = name
+	}
+}
+
+// WithModuleLoader enables import statements, resolving module paths through
+// loader.
+func WithModuleLoader(loader ModuleLoader) Option {
+	return func(c *Compiler) {
+		c.loader = loader
+	}
 }
 
 type CompilationScope struct {
@@ -25,6 +51,39 @@ type CompilationScope struct {
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
 	sourceMap           map[int]int // instruction index -> line number
+	loops               []*loopContext
+	tries               []*tryContext
+	localSites          []localSite
+	function            bool // compiling a function literal body
+	module              bool // compiling the top level of an imported module
+	generator           bool // the function contains yield
+}
+
+// localSite records an access to a local variable. If an inner function later
+// captures the variable, the access is rewritten to go through its cell when
+// the scope is left.
+type localSite struct {
+	position int
+	index    int
+	declare  bool // a declaration binds a fresh cell rather than updating one
+}
+
+// loopContext tracks the pending jumps of an enclosing loop so that break and
+// continue can be back-patched once the loop's layout is known. A switch
+// statement is also a break target, but continue passes through it.
+type loopContext struct {
+	label         string
+	breakJumps    []int
+	continueJumps []int
+	tryDepth      int  // try regions already open when the loop was entered
+	breakOnly     bool // a switch statement
+}
+
+// tryContext is a region guarded by a runtime handler (a try block, or a catch
+// block followed by finally). Jumping out of it must uninstall the handler and
+// run the finally block.
+type tryContext struct {
+	finally *ast.BlockStatement
 }
 
 type EmittedInstruction struct {
@@ -32,7 +91,7 @@ type EmittedInstruction struct {
 	Position int
 }
 
-func New() *Compiler {
+func New(opts ...Option) *Compiler {
 	mainScope := CompilationScope{
 		instructions:        []byte{},
 		lastInstruction:     EmittedInstruction{},
@@ -46,17 +105,24 @@ func New() *Compiler {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
 
-	return &Compiler{
+	c := &Compiler{
 		constants:         []object.Object{},
 		symbolTable:       symbolTable,
 		symbolDefinitions: make(map[ast.Node][]Symbol),
 		scopes:            []CompilationScope{mainScope},
 		scopeIndex:        0,
+		modules:           make(map[string]Symbol),
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
-func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
-	compiler := New()
+func NewWithState(s *SymbolTable, constants []object.Object, opts ...Option) *Compiler {
+	compiler := New(opts...)
 	compiler.symbolTable = s
 	compiler.constants = constants
 	return compiler
@@ -65,17 +131,23 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 func (c *Compiler) Compile(node ast.Node) error {
 	switch node := node.(type) {
 	case *ast.Program:
-		c.scanSymbols(node.Statements)
-		for _, s := range node.Statements {
-			err := c.Compile(s)
-			if err != nil {
-				return err
-			}
+		err := c.compileFile(node.Statements)
+		if err != nil {
+			return err
 		}
 
+	case *ast.ImportStatement:
+		c.lastLine = node.Token.Line
+		return fmt.Errorf("import is only allowed at the top level of a file")
+
 	case *ast.ExpressionStatement:
 		c.lastLine = node.Token.Line
-		err := c.Compile(node.Expression)
+		var err error
+		if incDec, ok := node.Expression.(*ast.IncDecExpression); ok {
+			err = c.compileIncDec(incDec)
+		} else {
+			err = c.Compile(node.Expression)
+		}
 		if err != nil {
 			return err
 		}
@@ -83,6 +155,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.InfixExpression:
 		c.lastLine = node.Token.Line
+		// Expressions over named constants are folded; plain literal
+		// arithmetic is left as written.
+		if value, named, ok := c.constantValue(node); ok && named {
+			c.emitConstantValue(value)
+			return nil
+		}
+
 		if node.Operator == "<" {
 			err := c.Compile(node.Right) // Reorder for <
 			if err != nil {
@@ -188,49 +267,42 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return nil
 		}
 
-		if node.Operator == "is" {
+		if node.Operator == "??" {
 			err := c.Compile(node.Left)
 			if err != nil {
 				return err
 			}
 
-			var typeID int
-
-			if ident, ok := node.Right.(*ast.Identifier); ok {
-				switch ident.Value {
-				case "int", "integer":
-					typeID = opcode.VMTypeInteger
-				case "float":
-					typeID = opcode.VMTypeFloat
-				case "bool", "boolean":
-					typeID = opcode.VMTypeBoolean
-				case "null": // If null is parsed as identifier (shouldn't happen if keyword)
-					typeID = opcode.VMTypeNull
-				case "err", "error":
-					typeID = opcode.VMTypeError
-				case "str", "string":
-					typeID = opcode.VMTypeString
-				case "builtin":
-					typeID = opcode.VMTypeBuiltin
-				case "array":
-					typeID = opcode.VMTypeArray
-				case "user":
-					typeID = opcode.VMTypeUser
-				case "tuple":
-					typeID = opcode.VMTypeTuple
-				default:
-					return fmt.Errorf("unknown type for 'is' operator: %s", ident.Value)
-				}
-			} else if _, ok := node.Right.(*ast.NullLiteral); ok {
-				typeID = opcode.VMTypeNull
-			} else {
-				return fmt.Errorf("expected identifier or null after 'is', got %T", node.Right)
+			// Stack: [left, left]
+			c.emit(opcode.OpDup)
+			jumpNullPos := c.emit(opcode.OpJumpNull, 9999)
+
+			// Left is not null, so it is the result
+			jumpToEndPos := c.emit(opcode.OpJump, 9999)
+
+			// Left is null: replace it with right
+			c.changeOperand(jumpNullPos, len(c.currentInstructions()))
+			c.emit(opcode.OpPop)
+
+			err = c.Compile(node.Right)
+			if err != nil {
+				return err
 			}
 
-			c.emit(opcode.OpIs, typeID)
+			c.changeOperand(jumpToEndPos, len(c.currentInstructions()))
+
 			return nil
 		}
 
+		if node.Operator == "is" {
+			err := c.Compile(node.Left)
+			if err != nil {
+				return err
+			}
+
+			return c.compileIsTest(node.Right)
+		}
+
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
@@ -251,6 +323,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(opcode.OpDiv)
 		case "%":
 			c.emit(opcode.OpMod)
+		case "&":
+			c.emit(opcode.OpBitAnd)
+		case "|":
+			c.emit(opcode.OpBitOr)
+		case "^":
+			c.emit(opcode.OpBitXor)
+		case "&^":
+			c.emit(opcode.OpBitAndNot)
+		case "<<":
+			c.emit(opcode.OpShiftLeft)
+		case ">>":
+			c.emit(opcode.OpShiftRight)
 		case ">":
 			c.emit(opcode.OpGreaterThan)
 		case "==":
@@ -281,6 +365,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.PrefixExpression:
 		c.lastLine = node.Token.Line
+		if value, named, ok := c.constantValue(node); ok && named {
+			c.emitConstantValue(value)
+			return nil
+		}
+
 		err := c.Compile(node.Right)
 		if err != nil {
 			return err
@@ -291,6 +380,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(opcode.OpBang)
 		case "-":
 			c.emit(opcode.OpMinus)
+		case "~":
+			c.emit(opcode.OpBitNot)
 		default:
 			return fmt.Errorf("unknown operator %s", node.Operator)
 		}
@@ -358,6 +449,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		declare := c.predeclareFunction(symbols, node.Value)
+
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
@@ -370,13 +463,61 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		// Assign in reverse order (stack is LIFO)
 		for i := len(symbols) - 1; i >= 0; i-- {
-			symbol := symbols[i]
-			if symbol.Scope == GlobalScope {
-				c.emit(opcode.OpSetGlobal, symbol.Index)
-			} else {
-				c.emit(opcode.OpSetLocal, symbol.Index)
+			c.emitSetSymbol(symbols[i], declare)
+		}
+
+	case *ast.ConstStatement:
+		c.lastLine = node.Token.Line
+		var symbol Symbol
+		if symbols, ok := c.symbolDefinitions[node]; ok {
+			symbol = symbols[0]
+		} else {
+			symbol = c.symbolTable.DefineConst(node.Name.Value)
+		}
+
+		// The value is still stored in the variable so that hosts can read
+		// it with GetGlobal, but references to a folded constant are inlined.
+		value, _, folded := c.constantValue(node.Value)
+		declare := true
+		if folded {
+			c.emitConstantValue(value)
+		} else {
+			declare = c.predeclareFunction([]Symbol{symbol}, node.Value)
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
 			}
 		}
+		c.emitSetSymbol(symbol, declare)
+
+		if folded {
+			c.symbolTable.SetConstantValue(node.Name.Value, value)
+		}
+
+	case *ast.TypeStatement:
+		c.lastLine = node.Token.Line
+		symbols, ok := c.symbolDefinitions[node]
+		if !ok {
+			symbols = []Symbol{c.defineStructType(node)}
+		}
+
+		// Stored like a folded const, so that hosts can read it with GetGlobal
+		c.emit(opcode.OpConstant, c.addConstant(symbols[0].Value))
+		c.emitSetSymbol(symbols[0], true)
+
+	case *ast.EnumStatement:
+		c.lastLine = node.Token.Line
+		symbols, ok := c.symbolDefinitions[node]
+		if !ok {
+			symbol, err := c.defineEnum(node)
+			if err != nil {
+				return err
+			}
+			symbols = []Symbol{symbol}
+		}
+
+		c.emit(opcode.OpConstant, c.addConstant(symbols[0].Value))
+		c.emitSetSymbol(symbols[0], true)
 
 	case *ast.ShortVarDeclaration:
 		c.lastLine = node.Token.Line
@@ -388,6 +529,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		declare := c.predeclareFunction(symbols, node.Value)
+
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
@@ -398,34 +541,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		for i := len(symbols) - 1; i >= 0; i-- {
-			symbol := symbols[i]
-			if symbol.Scope == GlobalScope {
-				c.emit(opcode.OpSetGlobal, symbol.Index)
-			} else {
-				c.emit(opcode.OpSetLocal, symbol.Index)
-			}
+			c.emitSetSymbol(symbols[i], declare)
 		}
 
 	case *ast.AssignExpression:
 		c.lastLine = node.Token.Line
-		symbol, ok := c.symbolTable.Resolve(node.Name.Value)
-		if !ok {
-			return fmt.Errorf("variable %s not defined", node.Name.Value)
+		symbol, err := c.symbolTable.ResolveAssignable(node.Name.Value)
+		if err != nil {
+			return err
 		}
 
-		err := c.Compile(node.Value)
+		err = c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(opcode.OpSetGlobal, symbol.Index)
-			c.emit(opcode.OpGetGlobal, symbol.Index)
-		} else if symbol.Scope == LocalScope {
-			c.emit(opcode.OpSetLocal, symbol.Index)
-			c.emit(opcode.OpGetLocal, symbol.Index)
-		} else {
-			return fmt.Errorf("assignment to %s not supported", symbol.Scope)
+		err = c.emitAssign(symbol)
+		if err != nil {
+			return err
 		}
 
 	case *ast.IndexAssignExpression:
@@ -447,6 +580,59 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(opcode.OpSetIndex)
 
+	case *ast.CompoundAssignExpression:
+		c.lastLine = node.Token.Line
+		err := c.compileCompoundAssign(node.Target, node.Operator, node.Value)
+		if err != nil {
+			return err
+		}
+
+	case *ast.IncDecExpression:
+		// As in Go, x++ is a statement: ExpressionStatement compiles it
+		return fmt.Errorf("%s is a statement and cannot be used as a value", node.String())
+
+	case *ast.MemberExpression:
+		c.lastLine = node.Token.Line
+		chain, outermost := c.beginChainLink()
+		// Enum members are folded to their values
+		if value, named, ok := c.constantValue(node); ok && named {
+			c.emitConstantValue(value)
+			return nil
+		}
+		err := c.checkEnumMember(node)
+		if err != nil {
+			return err
+		}
+
+		err = c.compileChainLeft(node.Left, chain)
+		if err != nil {
+			return err
+		}
+
+		name := &object.String{Value: node.Name.Value}
+		c.emit(opcode.OpGetAttr, c.addConstant(name))
+		c.endChain(chain, outermost)
+
+	case *ast.MemberAssignExpression:
+		c.lastLine = node.Token.Line
+		err := c.checkEnumAssign(node.Left)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Left.Left)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		name := &object.String{Value: node.Left.Name.Value}
+		c.emit(opcode.OpSetAttr, c.addConstant(name))
+
 	case *ast.Identifier:
 		c.lastLine = node.Token.Line
 		symbol, ok := c.symbolTable.Resolve(node.Value)
@@ -455,10 +641,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return fmt.Errorf("undefined variable %s", node.Value)
 		}
 
+		if symbol.Value != nil {
+			c.emitConstantValue(symbol.Value)
+			return nil
+		}
+
 		if symbol.Scope == GlobalScope {
 			c.emit(opcode.OpGetGlobal, symbol.Index)
 		} else if symbol.Scope == LocalScope {
-			c.emit(opcode.OpGetLocal, symbol.Index)
+			c.emitGetLocal(symbol.Index)
 		} else if symbol.Scope == BuiltinScope {
 			c.emit(opcode.OpGetBuiltin, symbol.Index)
 		} else if symbol.Scope == FreeScope {
@@ -517,21 +708,30 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.IndexExpression:
 		c.lastLine = node.Token.Line
-		err := c.Compile(node.Left)
+		chain, outermost := c.beginChainLink()
+		err := c.compileChainLeft(node.Left, chain)
 		if err != nil {
 			return err
 		}
 
+		// left?[index]: a null left is the result of the whole chain, and
+		// nothing after it is evaluated
+		if node.Optional {
+			c.emitChainJumpNull(chain)
+		}
+
 		err = c.Compile(node.Index)
 		if err != nil {
 			return err
 		}
 
 		c.emit(opcode.OpIndex)
+		c.endChain(chain, outermost)
 
 	case *ast.SliceExpression:
 		c.lastLine = node.Token.Line
-		err := c.Compile(node.Left)
+		chain, outermost := c.beginChainLink()
+		err := c.compileChainLeft(node.Left, chain)
 		if err != nil {
 			return err
 		}
@@ -555,16 +755,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		c.emit(opcode.OpSlice)
+		c.endChain(chain, outermost)
 
 	case *ast.FunctionLiteral:
 		c.lastLine = node.Token.Line
 		c.enterScope()
+		c.scopes[c.scopeIndex].function = true
 
 		for _, p := range node.Parameters {
 			c.symbolTable.Define(p.Value)
 		}
 
-		err := c.Compile(node.Body)
+		entries, err := c.compileDefaults(node)
+		if err != nil {
+			return err
+		}
+		numDefaults := 0
+		if entries != nil {
+			numDefaults = len(entries) - 1
+		}
+
+		err = c.Compile(node.Body)
 		if err != nil {
 			return err
 		}
@@ -578,14 +789,22 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		localNames := c.symbolTable.LocalNames()
+		generator := c.scopes[c.scopeIndex].generator
 		instructions, sourceMap := c.leaveScope()
+		c.lastLine = node.Token.Line
+
+		freeNames := make([]string, len(freeSymbols))
+		for i, s := range freeSymbols {
+			freeNames[i] = s.Name
+		}
 
 		for _, s := range freeSymbols {
-			// Emit code to load the free variables onto stack before creating closure
+			// Emit code to load the cells of the free variables onto stack before creating closure
 			if s.Scope == LocalScope {
-				c.emit(opcode.OpGetLocal, s.Index)
+				c.emit(opcode.OpLoadLocalCell, s.Index)
 			} else if s.Scope == FreeScope {
-				c.emit(opcode.OpGetFree, s.Index)
+				c.emit(opcode.OpLoadFreeCell, s.Index)
 			}
 		}
 
@@ -593,8 +812,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			NumDefaults:   numDefaults,
+			Variadic:      node.Variadic,
+			Generator:     generator,
+			Entries:       entries,
 			SourceMap:     sourceMap,
 			Name:          node.Name,
+			File:          c.fileName,
+			LocalNames:    localNames,
+			FreeNames:     freeNames,
 		}
 
 		c.emit(opcode.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
@@ -603,8 +829,31 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.lastLine = node.Token.Line
 		c.emit(opcode.OpNull)
 
+	case *ast.YieldExpression:
+		c.lastLine = node.Token.Line
+		scope := &c.scopes[c.scopeIndex]
+		if !scope.function {
+			return fmt.Errorf("yield outside of function")
+		}
+		scope.generator = true
+
+		if node.Value == nil {
+			c.emit(opcode.OpNull)
+		} else {
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+			c.lastLine = node.Token.Line
+		}
+		c.emit(opcode.OpYield)
+
 	case *ast.ReturnStatement:
 		c.lastLine = node.Token.Line
+		// A module's top level must finish to produce its namespace
+		if c.scopes[c.scopeIndex].module {
+			return fmt.Errorf("return outside of function")
+		}
 		if node.ReturnValue == nil {
 			c.emit(opcode.OpNull)
 		} else {
@@ -614,23 +863,65 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		// The return value stays on the stack while finally blocks run
+		err := c.exitTries(0)
+		if err != nil {
+			return err
+		}
+
 		c.emit(opcode.OpReturnValue)
 
+	case *ast.InterpolatedString:
+		// Refer to the builtin itself: a user variable named format must not
+		// change what "${x}" means. Each value is passed as its Inspect()
+		// string, so that "${x}" reads the same as print(x).
+		c.lastLine = node.Token.Line
+		c.emit(opcode.OpGetBuiltin, object.GetBuiltinIndex("format"))
+		c.emit(opcode.OpConstant, c.addConstant(&object.String{Value: node.Format}))
+		for _, v := range node.Values {
+			err := c.Compile(v)
+			if err != nil {
+				return err
+			}
+			c.emit(opcode.OpInspect)
+		}
+		c.emit(opcode.OpCall, len(node.Values)+1)
+
 	case *ast.CallExpression:
 		c.lastLine = node.Token.Line
-		err := c.Compile(node.Function)
+		chain, outermost := c.beginChainLink()
+		err := c.checkStructConstruction(node)
 		if err != nil {
 			return err
 		}
 
-		for _, a := range node.Arguments {
-			err := c.Compile(a)
+		err = c.compileChainLeft(node.Function, chain)
+		if err != nil {
+			return err
+		}
+
+		// f?.(args): a null function is the result of the whole chain, and
+		// nothing after it is evaluated
+		if node.Optional {
+			c.emitChainJumpNull(chain)
+		}
+
+		if hasSpread(node.Arguments) {
+			err = c.compileSpreadArguments(node.Arguments)
 			if err != nil {
 				return err
 			}
-		}
+		} else {
+			for _, a := range node.Arguments {
+				err := c.Compile(a)
+				if err != nil {
+					return err
+				}
+			}
 
-		c.emit(opcode.OpCall, len(node.Arguments))
+			c.emit(opcode.OpCall, len(node.Arguments))
+		}
+		c.endChain(chain, outermost)
 
 	case *ast.ForStatement:
 		c.lastLine = node.Token.Line
@@ -642,6 +933,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		// Like range loops, each iteration gets its own copy of the locals the
+		// init clause declared, so closures created in the body keep the value
+		// of their iteration.
+		perIteration := c.loopDeclaredLocals(node.Init)
+
 		startPos := len(c.currentInstructions())
 
 		var jumpNotTruthyPos int
@@ -655,11 +951,22 @@ func (c *Compiler) Compile(node ast.Node) error {
 			jumpNotTruthyPos = c.emit(opcode.OpJumpNotTruthy, 9999)
 		}
 
+		loop := c.enterLoop(node.Label)
+
 		err := c.Compile(node.Body)
 		if err != nil {
 			return err
 		}
 
+		// continue lands on Post so the loop variable still advances
+		postPos := len(c.currentInstructions())
+
+		// Post advances a fresh copy of the loop variables
+		for _, s := range perIteration {
+			c.emitGetLocal(s.Index)
+			c.emitSetLocal(s.Index, true)
+		}
+
 		// Post (run after body, before jumping back)
 		if node.Post != nil {
 			err := c.Compile(node.Post)
@@ -670,15 +977,515 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(opcode.OpJump, startPos)
 
+		afterBodyPos := len(c.currentInstructions())
 		if node.Condition != nil {
-			afterBodyPos := len(c.currentInstructions())
 			c.changeOperand(jumpNotTruthyPos, afterBodyPos)
 		}
+
+		c.leaveLoop(loop, postPos, afterBodyPos)
+
+	case *ast.RangeStatement:
+		c.lastLine = node.Token.Line
+		err := c.Compile(node.Iterable)
+		if err != nil {
+			return err
+		}
+
+		// The iterator lives in a hidden slot rather than on the stack so that
+		// break/continue can jump freely without unbalancing the stack.
+		iter := c.symbolTable.Define("$iter")
+		c.emit(opcode.OpIterInit)
+		c.emitSetSymbol(iter, true)
+
+		startPos := len(c.currentInstructions())
+		c.emitGetSymbol(iter)
+		iterNextPos := c.emit(opcode.OpIterNext, 9999)
+
+		// OpIterNext pushes key then value; store in reverse order
+		for _, ident := range []*ast.Identifier{node.Value, node.Key} {
+			if ident == nil || ident.Value == "_" {
+				c.emit(opcode.OpPop)
+				continue
+			}
+			c.emitSetSymbol(c.symbolTable.Define(ident.Value), true)
+		}
+
+		loop := c.enterLoop(node.Label)
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+
+		c.emit(opcode.OpJump, startPos)
+
+		afterBodyPos := len(c.currentInstructions())
+		c.changeOperand(iterNextPos, afterBodyPos)
+
+		c.leaveLoop(loop, startPos, afterBodyPos)
+
+	case *ast.TryStatement:
+		c.lastLine = node.Token.Line
+		err := c.compileTry(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.SwitchStatement:
+		c.lastLine = node.Token.Line
+		err := c.compileSwitch(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.BreakStatement:
+		c.lastLine = node.Token.Line
+		loop, err := c.resolveLoop("break", node.Label)
+		if err != nil {
+			return err
+		}
+		err = c.exitTries(loop.tryDepth)
+		if err != nil {
+			return err
+		}
+		loop.breakJumps = append(loop.breakJumps, c.emit(opcode.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		c.lastLine = node.Token.Line
+		loop, err := c.resolveLoop("continue", node.Label)
+		if err != nil {
+			return err
+		}
+		err = c.exitTries(loop.tryDepth)
+		if err != nil {
+			return err
+		}
+		loop.continueJumps = append(loop.continueJumps, c.emit(opcode.OpJump, 9999))
 	}
 
 	return nil
 }
 
+// emitAssign stores the value on top of the stack in an existing variable
+// and pushes it back, since assignment is an expression.
+func (c *Compiler) emitAssign(symbol Symbol) error {
+	if symbol.Scope == GlobalScope {
+		c.emit(opcode.OpSetGlobal, symbol.Index)
+		c.emit(opcode.OpGetGlobal, symbol.Index)
+	} else if symbol.Scope == LocalScope {
+		c.emitSetLocal(symbol.Index, false)
+		c.emitGetLocal(symbol.Index)
+	} else if symbol.Scope == FreeScope {
+		c.emit(opcode.OpSetFree, symbol.Index)
+		c.emit(opcode.OpGetFree, symbol.Index)
+	} else {
+		return fmt.Errorf("assignment to %s not supported", symbol.Scope)
+	}
+	return nil
+}
+
+// compileIncDec compiles the statement x++ or x-- as x += 1 or x -= 1.
+func (c *Compiler) compileIncDec(node *ast.IncDecExpression) error {
+	c.lastLine = node.Token.Line
+	one := &ast.IntegerLiteral{Token: node.Token, Value: 1}
+	operator := "+="
+	if node.Operator == "--" {
+		operator = "-="
+	}
+	return c.compileCompoundAssign(node.Target, operator, one)
+}
+
+var compoundOperators = map[string]opcode.Opcode{
+	"+=":  opcode.OpAdd,
+	"-=":  opcode.OpSub,
+	"*=":  opcode.OpMul,
+	"/=":  opcode.OpDiv,
+	"%=":  opcode.OpMod,
+	"&=":  opcode.OpBitAnd,
+	"|=":  opcode.OpBitOr,
+	"^=":  opcode.OpBitXor,
+	"&^=": opcode.OpBitAndNot,
+	"<<=": opcode.OpShiftLeft,
+	">>=": opcode.OpShiftRight,
+}
+
+// compileCompoundAssign compiles target op= value. The target is evaluated
+// only once: for index and member targets the container (and index) are
+// duplicated on the stack instead of being compiled a second time, so
+// arr[next()] += 1 calls next once.
+func (c *Compiler) compileCompoundAssign(target ast.Expression, operator string, value ast.Expression) error {
+	op, ok := compoundOperators[operator]
+	if !ok {
+		return fmt.Errorf("unknown operator %s", operator)
+	}
+	line := c.lastLine
+
+	switch target := target.(type) {
+	case *ast.Identifier:
+		symbol, err := c.symbolTable.ResolveAssignable(target.Value)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(target)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(value)
+		if err != nil {
+			return err
+		}
+
+		c.lastLine = line
+		c.emit(op)
+		return c.emitAssign(symbol)
+
+	case *ast.IndexExpression:
+		err := c.Compile(target.Left)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(target.Index)
+		if err != nil {
+			return err
+		}
+
+		// Stack: [left, index] -> [left, index, left[index]]
+		c.lastLine = line
+		c.emit(opcode.OpDup2)
+		c.emit(opcode.OpIndex)
+
+		err = c.Compile(value)
+		if err != nil {
+			return err
+		}
+
+		c.lastLine = line
+		c.emit(op)
+		c.emit(opcode.OpSetIndex)
+
+	case *ast.MemberExpression:
+		err := c.checkEnumAssign(target)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(target.Left)
+		if err != nil {
+			return err
+		}
+
+		// Stack: [left] -> [left, left.name]
+		name := c.addConstant(&object.String{Value: target.Name.Value})
+		c.lastLine = line
+		c.emit(opcode.OpDup)
+		c.emit(opcode.OpGetAttr, name)
+
+		err = c.Compile(value)
+		if err != nil {
+			return err
+		}
+
+		c.lastLine = line
+		c.emit(op)
+		c.emit(opcode.OpSetAttr, name)
+
+	default:
+		return fmt.Errorf("cannot assign to %s", target.String())
+	}
+
+	return nil
+}
+
+// emitSetSymbol stores the top of the stack into a global or local symbol.
+// declare is true when the store introduces the variable rather than
+// assigning to an existing one.
+func (c *Compiler) emitSetSymbol(s Symbol, declare bool) {
+	if s.Scope == GlobalScope {
+		c.emit(opcode.OpSetGlobal, s.Index)
+	} else {
+		c.emitSetLocal(s.Index, declare)
+	}
+}
+
+func (c *Compiler) emitGetSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(opcode.OpGetGlobal, s.Index)
+	} else {
+		c.emitGetLocal(s.Index)
+	}
+}
+
+func (c *Compiler) emitGetLocal(index int) {
+	pos := c.emit(opcode.OpGetLocal, index)
+	c.recordLocalSite(pos, index, false)
+}
+
+func (c *Compiler) emitSetLocal(index int, declare bool) {
+	pos := c.emit(opcode.OpSetLocal, index)
+	c.recordLocalSite(pos, index, declare)
+}
+
+func (c *Compiler) recordLocalSite(pos, index int, declare bool) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.localSites = append(scope.localSites, localSite{position: pos, index: index, declare: declare})
+}
+
+// promoteCapturedLocals rewrites every access to a local that an inner
+// function captured so that it goes through the variable's cell. Captures are
+// only known once the whole function body has been compiled, so this runs when
+// the scope is left. Cell opcodes have the same width as the plain ones.
+func (c *Compiler) promoteCapturedLocals() {
+	for _, site := range c.scopes[c.scopeIndex].localSites {
+		if !c.symbolTable.IsCaptured(site.index) {
+			continue
+		}
+
+		op := opcode.OpGetCell
+		if opcode.Opcode(c.currentInstructions()[site.position]) == opcode.OpSetLocal {
+			op = opcode.OpSetCell
+			if site.declare {
+				op = opcode.OpNewCell
+			}
+		}
+		c.replaceInstruction(site.position, opcode.Make(op, site.index))
+	}
+}
+
+// loopDeclaredLocals returns the local variables declared by a for loop's
+// init clause. Globals are shared by every closure regardless, so they are
+// left out.
+func (c *Compiler) loopDeclaredLocals(init ast.Statement) []Symbol {
+	var names []*ast.Identifier
+	switch init := init.(type) {
+	case *ast.ShortVarDeclaration:
+		names = init.Names
+	case *ast.LetStatement:
+		names = init.Names
+	}
+
+	var locals []Symbol
+	for _, name := range names {
+		s, ok := c.symbolTable.Resolve(name.Value)
+		if ok && s.Scope == LocalScope {
+			locals = append(locals, s)
+		}
+	}
+	return locals
+}
+
+// predeclareFunction binds a single local name to null before its function
+// literal is compiled, so that the function can capture itself for recursion.
+// It reports whether the caller's store still has to declare the variable.
+func (c *Compiler) predeclareFunction(symbols []Symbol, value ast.Expression) bool {
+	if len(symbols) != 1 || symbols[0].Scope != LocalScope {
+		return true
+	}
+	if _, ok := value.(*ast.FunctionLiteral); !ok {
+		return true
+	}
+
+	c.emit(opcode.OpNull)
+	c.emitSetLocal(symbols[0].Index, true)
+	return false
+}
+
+// compileTry lays out a try statement as:
+//
+//	OpTry handler; <try>; OpEndTry; OpJump done
+//	handler: <bind error>; OpTry rethrow; <catch>; OpEndTry; OpJump done
+//	rethrow: <store error>; <finally>; <load error>; OpThrow
+//	done:    <finally>
+//
+// The rethrow path exists only with a finally block; without a catch block the
+// first handler goes straight to it. Errors raised by the catch block are
+// therefore still followed by finally.
+func (c *Compiler) compileTry(node *ast.TryStatement) error {
+	tryPos := c.emit(opcode.OpTry, 9999)
+	err := c.compileGuarded(node.Block, node.Finally)
+	if err != nil {
+		return err
+	}
+	c.emit(opcode.OpEndTry)
+	doneJumps := []int{c.emit(opcode.OpJump, 9999)}
+
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+
+	if node.Catch != nil {
+		// The handler leaves the error value on the stack
+		if node.CatchParam != nil {
+			c.emitSetSymbol(c.symbolTable.Define(node.CatchParam.Value), true)
+		} else {
+			c.emit(opcode.OpPop)
+		}
+
+		if node.Finally == nil {
+			err := c.Compile(node.Catch)
+			if err != nil {
+				return err
+			}
+		} else {
+			catchTryPos := c.emit(opcode.OpTry, 9999)
+			err := c.compileGuarded(node.Catch, node.Finally)
+			if err != nil {
+				return err
+			}
+			c.emit(opcode.OpEndTry)
+			doneJumps = append(doneJumps, c.emit(opcode.OpJump, 9999))
+
+			c.changeOperand(catchTryPos, len(c.currentInstructions()))
+		}
+	}
+
+	if node.Finally != nil {
+		pending := c.symbolTable.Define("$err")
+		c.emitSetSymbol(pending, true)
+		err := c.Compile(node.Finally)
+		if err != nil {
+			return err
+		}
+		c.emitGetSymbol(pending)
+		c.emit(opcode.OpThrow)
+	}
+
+	donePos := len(c.currentInstructions())
+	for _, pos := range doneJumps {
+		c.changeOperand(pos, donePos)
+	}
+
+	if node.Finally != nil {
+		err := c.Compile(node.Finally)
+		if err != nil {
+			return err
+		}
+	}
+
+	// try is a statement: keep the value of its last inner expression from
+	// becoming an implicit return value, and give the jumps above a landing
+	// instruction when the statement ends a function.
+	c.emit(opcode.OpNull)
+	c.emit(opcode.OpPop)
+
+	return nil
+}
+
+// compileGuarded compiles a block that runs under an installed handler.
+func (c *Compiler) compileGuarded(block, finally *ast.BlockStatement) error {
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, &tryContext{finally: finally})
+	err := c.Compile(block)
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+	return err
+}
+
+// isTypeID maps a type name on the right side of 'is' (or in a type switch
+// case) to the type ID operand of OpIs.
+func isTypeID(node ast.Expression) (int, error) {
+	if _, ok := node.(*ast.NullLiteral); ok {
+		return opcode.VMTypeNull, nil
+	}
+
+	ident, ok := node.(*ast.Identifier)
+	if !ok {
+		return 0, fmt.Errorf("expected identifier or null after 'is', got %T", node)
+	}
+
+	switch ident.Value {
+	case "int", "integer":
+		return opcode.VMTypeInteger, nil
+	case "float":
+		return opcode.VMTypeFloat, nil
+	case "bool", "boolean":
+		return opcode.VMTypeBoolean, nil
+	case "null": // If null is parsed as identifier (shouldn't happen if keyword)
+		return opcode.VMTypeNull, nil
+	case "err", "error":
+		return opcode.VMTypeError, nil
+	case "str", "string":
+		return opcode.VMTypeString, nil
+	case "builtin":
+		return opcode.VMTypeBuiltin, nil
+	case "array":
+		return opcode.VMTypeArray, nil
+	case "user":
+		return opcode.VMTypeUser, nil
+	case "tuple":
+		return opcode.VMTypeTuple, nil
+	case "coroutine":
+		return opcode.VMTypeCoroutine, nil
+	default:
+		return 0, fmt.Errorf("unknown type for 'is' operator: %s", ident.Value)
+	}
+}
+
+// exitTries emits the cleanup for a jump that leaves every try region opened
+// beyond depth: innermost first, each handler is uninstalled and its finally
+// block run. Jumps inside such a finally block only see the outer regions.
+func (c *Compiler) exitTries(depth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emit(opcode.OpEndTry)
+		if tries[i].finally == nil {
+			continue
+		}
+
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		err := c.Compile(tries[i].finally)
+		c.scopes[c.scopeIndex].tries = tries
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) enterLoop(label string) *loopContext {
+	loop := &loopContext{label: label, tryDepth: len(c.scopes[c.scopeIndex].tries)}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, loop)
+	return loop
+}
+
+// leaveLoop patches every pending break/continue jump of loop and pops it off
+// the loop stack of the current scope.
+func (c *Compiler) leaveLoop(loop *loopContext, continuePos, breakPos int) {
+	for _, pos := range loop.continueJumps {
+		c.changeOperand(pos, continuePos)
+	}
+	for _, pos := range loop.breakJumps {
+		c.changeOperand(pos, breakPos)
+	}
+
+	loops := c.scopes[c.scopeIndex].loops
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+}
+
+// resolveLoop finds the loop targeted by a break/continue. Loops are tracked
+// per compilation scope, so a function literal cannot jump out of a loop in
+// its enclosing function.
+func (c *Compiler) resolveLoop(keyword string, label *ast.Identifier) (*loopContext, error) {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil, fmt.Errorf("%s statement outside of loop", keyword)
+	}
+
+	for i := len(loops) - 1; i >= 0; i-- {
+		if label == nil {
+			if keyword == "continue" && loops[i].breakOnly {
+				continue
+			}
+			return loops[i], nil
+		}
+		if loops[i].label == label.Value {
+			return loops[i], nil
+		}
+	}
+
+	if label == nil {
+		return nil, fmt.Errorf("%s statement outside of loop", keyword)
+	}
+	return nil, fmt.Errorf("%s label not defined: %s", keyword, label.Value)
+}
+
 func (c *Compiler) addConstant(obj object.Object) int {
 	c.constants = append(c.constants, obj)
 	return len(c.constants) - 1
@@ -768,6 +1575,8 @@ func (c *Compiler) enterScope() {
 }
 
 func (c *Compiler) leaveScope() ([]byte, map[int]int) {
+	c.promoteCapturedLocals()
+
 	instructions := c.currentInstructions()
 	sourceMap := c.scopes[c.scopeIndex].sourceMap
 
@@ -783,6 +1592,7 @@ type Bytecode struct {
 	Constants    []object.Object
 	SymbolTable  *SymbolTable
 	SourceMap    map[int]int
+	FileName     string
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
@@ -791,6 +1601,7 @@ func (c *Compiler) Bytecode() *Bytecode {
 		Constants:    c.constants,
 		SymbolTable:  c.symbolTable,
 		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		FileName:     c.fileName,
 	}
 }
 
@@ -813,6 +1624,22 @@ func (c *Compiler) scanSymbols(statements []ast.Statement) {
 				symbols[i] = c.symbolTable.Define(name.Value)
 			}
 			c.symbolDefinitions[s] = symbols
+		case *ast.ConstStatement:
+			c.symbolDefinitions[s] = []Symbol{c.symbolTable.DefineConst(s.Name.Value)}
+		case *ast.TypeStatement:
+			c.symbolDefinitions[s] = []Symbol{c.defineStructType(s)}
+		case *ast.EnumStatement:
+			symbol, err := c.defineEnum(s)
+			if err != nil {
+				continue // reported when the statement is compiled
+			}
+			c.symbolDefinitions[s] = []Symbol{symbol}
+		case *ast.ImportStatement:
+			name, err := importName(s)
+			if err != nil {
+				continue // reported when the statement is compiled
+			}
+			c.symbolDefinitions[s] = []Symbol{c.symbolTable.Define(name)}
 		}
 	}
 }
@@ -2,6 +2,7 @@ package compiler
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/iceisfun/icescript/ast"
@@ -147,6 +148,205 @@ func TestFunctionLiterals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestCapturedLocals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `func() { n := 0; return func() { n = n + 1 } }`,
+			expectedConstants: []any{
+				0,
+				1,
+				[]code{
+					{opcode.OpGetFree, []int{0}},
+					{opcode.OpConstant, []int{1}},
+					{opcode.OpAdd, []int{}},
+					{opcode.OpSetFree, []int{0}},
+					{opcode.OpGetFree, []int{0}},
+					{opcode.OpReturnValue, []int{}},
+				},
+				[]code{
+					{opcode.OpConstant, []int{0}},
+					{opcode.OpNewCell, []int{0}}, // n is captured, so it lives in a cell
+					{opcode.OpLoadLocalCell, []int{0}},
+					{opcode.OpClosure, []int{2, 1}},
+					{opcode.OpReturnValue, []int{}},
+				},
+			},
+			expectedInstructions: []code{
+				{opcode.OpClosure, []int{3, 0}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+		{
+			input: `func() { n := 0; return n }`,
+			expectedConstants: []any{
+				0,
+				[]code{
+					{opcode.OpConstant, []int{0}},
+					{opcode.OpSetLocal, []int{0}}, // not captured, stays a plain slot
+					{opcode.OpGetLocal, []int{0}},
+					{opcode.OpReturnValue, []int{}},
+				},
+			},
+			expectedInstructions: []code{
+				{opcode.OpClosure, []int{1, 0}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "var x = 1; x += 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpGetGlobal, []int{0}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpAdd, []int{}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpGetGlobal, []int{0}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+		{
+			input:             "var a = []; a[0]--",
+			expectedConstants: []any{0, 1},
+			expectedInstructions: []code{
+				{opcode.OpArray, []int{0}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpGetGlobal, []int{0}},
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpDup2, []int{}}, // a and 0 are evaluated once
+				{opcode.OpIndex, []int{}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpSub, []int{}},
+				{opcode.OpSetIndex, []int{}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+		{
+			input:             "var m = {}; m.hp *= 2",
+			expectedConstants: []any{"hp", 2},
+			expectedInstructions: []code{
+				{opcode.OpHash, []int{0}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpGetGlobal, []int{0}},
+				{opcode.OpDup, []int{}},
+				{opcode.OpGetAttr, []int{0}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpMul, []int{}},
+				{opcode.OpSetAttr, []int{0}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestConstants(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "const TICK = 16; var x = 2; x * TICK",
+			expectedConstants: []any{16, 2, 16},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpSetGlobal, []int{1}},
+				{opcode.OpGetGlobal, []int{1}},
+				{opcode.OpConstant, []int{2}}, // TICK is inlined
+				{opcode.OpMul, []int{}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+		{
+			input:             "const A = 2; const B = A * 8; -B + 1",
+			expectedConstants: []any{2, 16, -15},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpConstant, []int{1}}, // A * 8 folded
+				{opcode.OpSetGlobal, []int{1}},
+				{opcode.OpConstant, []int{2}}, // -B + 1 folded
+				{opcode.OpPop, []int{}},
+			},
+		},
+		{
+			input:             "const ON = true; ON",
+			expectedConstants: []any{},
+			expectedInstructions: []code{
+				{opcode.OpTrue, []int{}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpTrue, []int{}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+		{
+			input:             "const Z = 0; 1 / Z",
+			expectedConstants: []any{0, 1, 0},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpConstant, []int{2}},
+				{opcode.OpDiv, []int{}}, // division by zero is left to the VM
+				{opcode.OpPop, []int{}},
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestConstantAssignmentErrors(t *testing.T) {
+	tests := []string{
+		"const X = 1; X = 2",
+		"const X = 1; X += 2",
+		"const X = 1; X++",
+		"func f() { X = 2 }; const X = 1",
+		"func f() { const n = len(\"ab\"); n-- }",
+		"func f() { const n = 1; return func() { n = 2 } }",
+	}
+
+	for _, input := range tests {
+		err := New().Compile(parse(input))
+		if err == nil {
+			t.Fatalf("expected compile error for %q, got nil", input)
+		}
+		if !strings.Contains(err.Error(), "cannot assign to constant") {
+			t.Errorf("wrong error for %q: %q", input, err.Error())
+		}
+	}
+}
+
+func TestIncDecIsAStatement(t *testing.T) {
+	tests := []string{
+		"var i = 0; var y = i++",
+		"var i = 0; var a = [1, 2]; a[i++] += 1",
+		"var i = 0; print(i--)",
+		"func f(i) { return i++ }",
+	}
+
+	for _, input := range tests {
+		err := New().Compile(parse(input))
+		if err == nil {
+			t.Fatalf("expected compile error for %q, got nil", input)
+		}
+		if !strings.Contains(err.Error(), "is a statement and cannot be used as a value") {
+			t.Errorf("wrong error for %q: %q", input, err.Error())
+		}
+	}
+
+	for _, input := range []string{"var i = 0; i++", "for i := 0; i < 3; i-- {}", "func f() { n := 1; n++ }"} {
+		if err := New().Compile(parse(input)); err != nil {
+			t.Errorf("unexpected compile error for %q: %s", input, err)
+		}
+	}
+}
+
 func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 	t.Helper()
 
@@ -259,3 +459,54 @@ func testIntegerObject(expected int64, actual object.Object) error {
 
 	return nil
 }
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 & 2; 1 | 2; 1 ^ 2; 1 &^ 2; 1 << 2; 1 >> 2; ~1",
+			expectedConstants: []any{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpBitAnd, []int{}},
+				{opcode.OpPop, []int{}},
+				{opcode.OpConstant, []int{2}},
+				{opcode.OpConstant, []int{3}},
+				{opcode.OpBitOr, []int{}},
+				{opcode.OpPop, []int{}},
+				{opcode.OpConstant, []int{4}},
+				{opcode.OpConstant, []int{5}},
+				{opcode.OpBitXor, []int{}},
+				{opcode.OpPop, []int{}},
+				{opcode.OpConstant, []int{6}},
+				{opcode.OpConstant, []int{7}},
+				{opcode.OpBitAndNot, []int{}},
+				{opcode.OpPop, []int{}},
+				{opcode.OpConstant, []int{8}},
+				{opcode.OpConstant, []int{9}},
+				{opcode.OpShiftLeft, []int{}},
+				{opcode.OpPop, []int{}},
+				{opcode.OpConstant, []int{10}},
+				{opcode.OpConstant, []int{11}},
+				{opcode.OpShiftRight, []int{}},
+				{opcode.OpPop, []int{}},
+				{opcode.OpConstant, []int{12}},
+				{opcode.OpBitNot, []int{}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+		{
+			// Constant flags fold like arithmetic
+			input:             "const A = 1 << 3; A | 1",
+			expectedConstants: []any{8, 9},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},
+				{opcode.OpSetGlobal, []int{0}},
+				{opcode.OpConstant, []int{1}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
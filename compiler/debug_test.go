@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+)
+
+func TestFunctionVariableNames(t *testing.T) {
+	input := `
+func outer(a, b) {
+	var total = 0
+	for _, v := range [a, b] {
+		var total = v
+	}
+	return func(x) { return x + total + a }
+}
+`
+	comp := New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var fns []*object.CompiledFunction
+	for _, constant := range comp.Bytecode().Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fns = append(fns, fn)
+		}
+	}
+	if len(fns) != 2 {
+		t.Fatalf("wrong number of functions. want=2, got=%d", len(fns))
+	}
+	inner, outer := fns[0], fns[1]
+
+	// Every slot is named, shadowed variables and compiler temporaries included
+	if got := strings.Join(outer.LocalNames, ","); got != "a,b,total,$iter,v,total" {
+		t.Errorf("wrong outer locals. got=%q", got)
+	}
+	if len(outer.LocalNames) != outer.NumLocals {
+		t.Errorf("%d names for %d locals", len(outer.LocalNames), outer.NumLocals)
+	}
+	if len(outer.FreeNames) != 0 {
+		t.Errorf("outer should have no free variables. got=%v", outer.FreeNames)
+	}
+
+	if got := strings.Join(inner.LocalNames, ","); got != "x" {
+		t.Errorf("wrong inner locals. got=%q", got)
+	}
+	if got := strings.Join(inner.FreeNames, ","); got != "total,a" {
+		t.Errorf("wrong inner free variables. got=%q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package compiler
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/object"
+)
+
+// defineEnum binds the enum declared by node as a constant. Members without
+// an explicit value take the value after the previous member, starting at 0.
+// Explicit values must be integer constants built from literals, so the enum
+// is complete as soon as it is declared; references to its members are
+// folded to integers.
+func (c *Compiler) defineEnum(node *ast.EnumStatement) (Symbol, error) {
+	members := make([]string, len(node.Members))
+	values := make([]int64, len(node.Members))
+
+	next := int64(0)
+	for i, member := range node.Members {
+		if slices.Contains(object.EnumMethods, member.Value) {
+			return Symbol{}, fmt.Errorf("enum %s cannot have a member named %s", node.Name.Value, member.Value)
+		}
+
+		if node.Values[i] != nil {
+			value, _, ok := c.constantValue(node.Values[i])
+			integer, isInt := value.(*object.Integer)
+			if !ok || !isInt {
+				return Symbol{}, fmt.Errorf("value of %s.%s must be a constant integer, got %s",
+					node.Name.Value, member.Value, node.Values[i].String())
+			}
+			next = integer.Value
+		}
+
+		members[i] = member.Value
+		values[i] = next
+		next++
+	}
+
+	symbol := c.symbolTable.DefineConst(node.Name.Value)
+	symbol.Value = object.NewEnum(node.Name.Value, members, values)
+	c.symbolTable.SetConstantValue(node.Name.Value, symbol.Value)
+	return symbol, nil
+}
+
+// checkEnumAssign rejects an assignment to a member of an enum known at
+// compile time; its members are folded into the code that reads them.
+func (c *Compiler) checkEnumAssign(node *ast.MemberExpression) error {
+	err := c.checkEnumMember(node)
+	if err != nil {
+		return err
+	}
+	if left, _, ok := c.constantValue(node.Left); ok {
+		if enum, ok := left.(*object.Enum); ok {
+			return enum.SetAttr(node.Name.Value, nil)
+		}
+	}
+	return nil
+}
+
+// checkEnumMember rejects an access to a member the enum does not declare,
+// when the enum is known at compile time.
+func (c *Compiler) checkEnumMember(node *ast.MemberExpression) error {
+	left, _, ok := c.constantValue(node.Left)
+	if !ok {
+		return nil
+	}
+	enum, ok := left.(*object.Enum)
+	if !ok {
+		return nil
+	}
+	if _, ok := enum.GetAttr(node.Name.Value); !ok {
+		return fmt.Errorf("enum %s has no member %s", enum.Name, node.Name.Value)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+func TestEnumStatement(t *testing.T) {
+	comp := New()
+	if err := comp.Compile(parse("enum State { Idle, Walk = 5, Attack }; State.Attack")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	state, ok := bytecode.Constants[0].(*object.Enum)
+	if !ok {
+		t.Fatalf("constant 0 is %T, want *object.Enum", bytecode.Constants[0])
+	}
+	if state.Name != "State" || strings.Join(state.Members, ",") != "Idle,Walk,Attack" {
+		t.Errorf("wrong enum. got=%s", state.Inspect())
+	}
+	for i, want := range []int64{0, 5, 6} {
+		if state.Values[i] != want {
+			t.Errorf("wrong value for %s. want=%d, got=%d", state.Members[i], want, state.Values[i])
+		}
+	}
+
+	// The member access is folded to its value; nothing is looked up at runtime
+	err := testInstructions([]code{
+		{opcode.OpConstant, []int{0}},
+		{opcode.OpSetGlobal, []int{0}},
+		{opcode.OpConstant, []int{1}},
+		{opcode.OpPop, []int{}},
+	}, bytecode.Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+	if err := testIntegerObject(6, bytecode.Constants[1]); err != nil {
+		t.Errorf("wrong folded constant: %s", err)
+	}
+}
+
+func TestEnumFolding(t *testing.T) {
+	tests := []struct {
+		input   string
+		getAttr bool
+	}{
+		{"enum State { Idle, Walk }; State.Walk", false},
+		{"enum State { Idle, Walk }; State.Walk + 1", false},
+		{"enum Flags { A = 1, B = 2 }; Flags.A | Flags.B", false},
+		{"enum State { Idle, Walk }; func f() { return State.Idle }", false},
+		{"func f() { return State.Idle }; enum State { Idle, Walk }", false},
+		{"enum State { Idle, Walk }; State.name(1)", true},
+		{"enum State { Idle, Walk }; var S = State; S.Walk", true},
+	}
+
+	for _, tt := range tests {
+		comp := New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+		if got := containsOpcode(comp.Bytecode().Instructions, opcode.OpGetAttr); got != tt.getAttr {
+			t.Errorf("%q: OpGetAttr emitted=%t, want %t", tt.input, got, tt.getAttr)
+		}
+	}
+}
+
+func TestEnumSwitchUsesJumpTable(t *testing.T) {
+	input := `
+enum State { Idle, Walk, Attack }
+var s = State.Walk
+switch s {
+case State.Idle: 1
+case State.Walk: 2
+case State.Attack: 3
+}
+`
+	comp := New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	if !containsOpcode(comp.Bytecode().Instructions, opcode.OpJumpTable) {
+		t.Errorf("switch over enum members does not use a jump table")
+	}
+}
+
+func TestEnumCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum State { Idle }; State.Run", "enum State has no member Run"},
+		{"enum State { Idle }; State.Run = 1", "enum State has no member Run"},
+		{`enum State { Idle = "a" }`, "value of State.Idle must be a constant integer"},
+		{"var n = 1; enum State { Idle = n }", "value of State.Idle must be a constant integer"},
+		{"enum State { Idle = 1.5 }", "value of State.Idle must be a constant integer"},
+		{"enum State { name }", "enum State cannot have a member named name"},
+		{"enum State { Idle }; State.Idle = 1", "cannot assign to State.Idle: enum members are constant"},
+		{"enum State { Idle }; State.Idle++", "cannot assign to State.Idle: enum members are constant"},
+		{"enum State { Idle }; State = 1", "cannot assign to constant State"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compile error for %q, got nil", tt.input)
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
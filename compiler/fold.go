@@ -0,0 +1,170 @@
+package compiler
+
+import (
+	"math"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+// constantValue evaluates node at compile time if it is built only from
+// literals and constants with a known value. named reports whether a named
+// constant was involved. Operations the VM would reject or that depend on
+// runtime state (division by zero, mixed types) are left to the VM so that
+// errors keep their usual location.
+func (c *Compiler) constantValue(node ast.Expression) (value object.Object, named bool, ok bool) {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}, false, true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}, false, true
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}, false, true
+	case *ast.Boolean:
+		return object.NativeBoolToBooleanObject(node.Value), false, true
+	case *ast.NullLiteral:
+		return object.NullObj, false, true
+
+	case *ast.Identifier:
+		symbol, found := c.symbolTable.Resolve(node.Value)
+		if !found || symbol.Value == nil {
+			return nil, false, false
+		}
+		return symbol.Value, true, true
+
+	case *ast.MemberExpression:
+		left, _, ok := c.constantValue(node.Left)
+		if !ok {
+			return nil, false, false
+		}
+		enum, ok := left.(*object.Enum)
+		if !ok {
+			return nil, false, false
+		}
+		value, ok := enum.Value(node.Name.Value)
+		if !ok {
+			return nil, false, false
+		}
+		return &object.Integer{Value: value}, true, true
+
+	case *ast.PrefixExpression:
+		right, named, ok := c.constantValue(node.Right)
+		if !ok {
+			return nil, false, false
+		}
+		switch {
+		case node.Operator == "-" && right.Type() == object.INTEGER_OBJ:
+			return &object.Integer{Value: -right.(*object.Integer).Value}, named, true
+		case node.Operator == "-" && right.Type() == object.FLOAT_OBJ:
+			return &object.Float{Value: -right.(*object.Float).Value}, named, true
+		case node.Operator == "~" && right.Type() == object.INTEGER_OBJ:
+			return &object.Integer{Value: ^right.(*object.Integer).Value}, named, true
+		case node.Operator == "!" && right.Type() == object.BOOLEAN_OBJ:
+			return object.NativeBoolToBooleanObject(!right.(*object.Boolean).Value), named, true
+		}
+		return nil, false, false
+
+	case *ast.InfixExpression:
+		left, leftNamed, ok := c.constantValue(node.Left)
+		if !ok {
+			return nil, false, false
+		}
+		right, rightNamed, ok := c.constantValue(node.Right)
+		if !ok {
+			return nil, false, false
+		}
+		value, ok := foldInfix(node.Operator, left, right)
+		return value, leftNamed || rightNamed, ok
+	}
+
+	return nil, false, false
+}
+
+// foldInfix applies an arithmetic operator with the same semantics as the
+// VM's binary operations.
+func foldInfix(operator string, left, right object.Object) (object.Object, bool) {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		l := left.(*object.Integer).Value
+		r := right.(*object.Integer).Value
+		switch operator {
+		case "+":
+			return &object.Integer{Value: l + r}, true
+		case "-":
+			return &object.Integer{Value: l - r}, true
+		case "*":
+			return &object.Integer{Value: l * r}, true
+		case "/":
+			if r != 0 {
+				return &object.Integer{Value: l / r}, true
+			}
+		case "%":
+			if r != 0 {
+				return &object.Integer{Value: l % r}, true
+			}
+		case "&":
+			return &object.Integer{Value: l & r}, true
+		case "|":
+			return &object.Integer{Value: l | r}, true
+		case "^":
+			return &object.Integer{Value: l ^ r}, true
+		case "&^":
+			return &object.Integer{Value: l &^ r}, true
+		case "<<":
+			if r >= 0 {
+				return &object.Integer{Value: l << r}, true
+			}
+		case ">>":
+			if r >= 0 {
+				return &object.Integer{Value: l >> r}, true
+			}
+		}
+
+	case isNumber(left) && isNumber(right):
+		l, _ := left.AsFloat()
+		r, _ := right.AsFloat()
+		switch operator {
+		case "+":
+			return &object.Float{Value: l + r}, true
+		case "-":
+			return &object.Float{Value: l - r}, true
+		case "*":
+			return &object.Float{Value: l * r}, true
+		case "/":
+			if r != 0 {
+				return &object.Float{Value: l / r}, true
+			}
+		case "%":
+			if r != 0 {
+				return &object.Float{Value: math.Mod(l, r)}, true
+			}
+		}
+
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		if operator == "+" {
+			return &object.String{Value: left.(*object.String).Value + right.(*object.String).Value}, true
+		}
+	}
+
+	return nil, false
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// emitConstantValue pushes a folded value. Booleans and null use their
+// dedicated opcodes so that the VM keeps seeing its shared instances.
+func (c *Compiler) emitConstantValue(value object.Object) {
+	switch value {
+	case object.True:
+		c.emit(opcode.OpTrue)
+	case object.False:
+		c.emit(opcode.OpFalse)
+	case object.NullObj:
+		c.emit(opcode.OpNull)
+	default:
+		c.emit(opcode.OpConstant, c.addConstant(value))
+	}
+}
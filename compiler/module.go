@@ -0,0 +1,205 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+	"github.com/iceisfun/icescript/parser"
+	"github.com/iceisfun/icescript/token"
+)
+
+// ModuleLoader resolves an import path to the source code of a module.
+type ModuleLoader interface {
+	Load(path string) (string, error)
+}
+
+// ModuleLoaderFunc adapts a function to the ModuleLoader interface.
+type ModuleLoaderFunc func(path string) (string, error)
+
+func (f ModuleLoaderFunc) Load(path string) (string, error) {
+	return f(path)
+}
+
+// compileFile compiles the top-level statements of the main script or of a
+// module. Import statements are only valid here.
+func (c *Compiler) compileFile(statements []ast.Statement) error {
+	c.scanSymbols(statements)
+	for _, s := range statements {
+		var err error
+		if imp, ok := s.(*ast.ImportStatement); ok {
+			err = c.compileImport(imp)
+		} else {
+			err = c.Compile(s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileImport binds the namespace of a module. The first import of a path
+// compiles the module and runs its initializer at that point; later imports
+// of the same path, from any file, reuse the initialized module. Because
+// imports are top-level statements, the first import to be compiled is also
+// the first to run.
+func (c *Compiler) compileImport(node *ast.ImportStatement) error {
+	c.lastLine = node.Token.Line
+	name, err := importName(node)
+	if err != nil {
+		return err
+	}
+
+	module, ok := c.modules[node.Path.Value]
+	if !ok {
+		module, err = c.compileModule(node.Path.Value)
+		if err != nil {
+			return err
+		}
+		c.lastLine = node.Token.Line
+	}
+
+	var symbol Symbol
+	if symbols, ok := c.symbolDefinitions[node]; ok {
+		symbol = symbols[0]
+	} else {
+		symbol = c.symbolTable.Define(name)
+	}
+
+	c.emitGetSymbol(module)
+	c.emitSetSymbol(symbol, true)
+	return nil
+}
+
+// compileModule compiles a module into an initializer function and emits the
+// code that runs it and stores the resulting namespace in a hidden global.
+// The module gets its own global symbol table, so its names do not leak into
+// the importing file; only exported (capitalized) names reach the namespace.
+func (c *Compiler) compileModule(path string) (Symbol, error) {
+	for i, p := range c.importing {
+		if p == path {
+			cycle := append(append([]string{}, c.importing[i:]...), path)
+			return Symbol{}, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if c.loader == nil {
+		return Symbol{}, fmt.Errorf("cannot import %q: no module loader configured", path)
+	}
+
+	source, err := c.loader.Load(path)
+	if err != nil {
+		return Symbol{}, fmt.Errorf("cannot import %q: %w", path, err)
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return Symbol{}, fmt.Errorf("module %s: %s", path, strings.Join(errs, "\n"))
+	}
+
+	c.importing = append(c.importing, path)
+	defer func() { c.importing = c.importing[:len(c.importing)-1] }()
+
+	programTable := c.symbolTable
+	fileName := c.fileName
+
+	moduleTable := NewModuleSymbolTable(programTable)
+	for i, v := range object.Builtins {
+		moduleTable.DefineBuiltin(i, v.Name)
+	}
+
+	c.enterScope()
+	c.scopes[c.scopeIndex].module = true
+	c.symbolTable = moduleTable
+	c.fileName = path
+
+	err = c.compileFile(program.Statements)
+	if err != nil {
+		c.leaveScope()
+		c.symbolTable = programTable
+		c.fileName = fileName
+		return Symbol{}, fmt.Errorf("module %s: %w", path, err)
+	}
+
+	exports := moduleTable.exportedSymbols()
+	for _, sym := range exports {
+		c.emit(opcode.OpConstant, c.addConstant(&object.String{Value: sym.Name}))
+		c.emitGetSymbol(sym)
+	}
+	c.emit(opcode.OpHash, len(exports)*2)
+	c.emit(opcode.OpReturnValue)
+
+	instructions, sourceMap := c.leaveScope()
+	c.symbolTable = programTable
+	c.fileName = fileName
+
+	init := &object.CompiledFunction{
+		Instructions: instructions,
+		SourceMap:    sourceMap,
+		Name:         path + ".init",
+		File:         path,
+	}
+
+	root := programTable
+	if root.root != nil {
+		root = root.root
+	}
+	module := root.Define("$module:" + path)
+	c.modules[path] = module
+
+	c.emit(opcode.OpClosure, c.addConstant(init), 0)
+	c.emit(opcode.OpCall, 0)
+	c.emit(opcode.OpModule, c.addConstant(&object.String{Value: path}))
+	c.emitSetSymbol(module, true)
+
+	return module, nil
+}
+
+// exportedSymbols returns the module globals visible to importers, sorted by
+// name. As in Go, a name is exported if it starts with an upper-case letter.
+func (s *SymbolTable) exportedSymbols() []Symbol {
+	var exports []Symbol
+	for name, sym := range s.store {
+		r, _ := utf8.DecodeRuneInString(name)
+		if sym.Scope == GlobalScope && unicode.IsUpper(r) {
+			exports = append(exports, sym)
+		}
+	}
+	sort.Slice(exports, func(i, j int) bool { return exports[i].Name < exports[j].Name })
+	return exports
+}
+
+// importName returns the name an import binds: the alias, or else the last
+// element of the path without its .ice extension.
+func importName(node *ast.ImportStatement) (string, error) {
+	if node.Alias != nil {
+		return node.Alias.Value, nil
+	}
+
+	path := node.Path.Value
+	name := strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".ice")
+	if !isIdentifier(name) {
+		return "", fmt.Errorf("cannot derive a name from import path %q, use: import %q as name", path, path)
+	}
+	return name, nil
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || token.LookupIdent(name) != token.IDENT {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestImportErrors(t *testing.T) {
+	modules := map[string]string{
+		"a":       `import "b"`,
+		"b":       `import "a"`,
+		"self":    `import "self"`,
+		"undef":   `func F() { return missing }`,
+		"broken":  `func F( {`,
+		"returns": `var X = 1; if X > 0 { return X }`,
+	}
+	loader := ModuleLoaderFunc(func(path string) (string, error) {
+		source, ok := modules[path]
+		if !ok {
+			return "", fmt.Errorf("not found")
+		}
+		return source, nil
+	})
+
+	tests := []struct {
+		input       string
+		loader      ModuleLoader
+		errContains string
+	}{
+		{`import "a"`, loader, "import cycle: a -> b -> a"},
+		{`import "self"`, loader, "import cycle: self -> self"},
+		{`import "missing"`, loader, `cannot import "missing": not found`},
+		{`import "a"`, nil, `cannot import "a": no module loader configured`},
+		{`import "undef"`, loader, "module undef: undefined variable missing"},
+		{`import "broken"`, loader, "module broken:"},
+		{`import "returns"`, loader, "module returns: return outside of function"},
+		{`import "my-lib"`, loader, `cannot derive a name from import path "my-lib"`},
+		{`func f() { import "a" }`, loader, "import is only allowed at the top level of a file"},
+	}
+
+	for _, tt := range tests {
+		var opts []Option
+		if tt.loader != nil {
+			opts = append(opts, WithModuleLoader(tt.loader))
+		}
+
+		err := New(opts...).Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compile error for %q, got nil", tt.input)
+		}
+		if !strings.Contains(err.Error(), tt.errContains) {
+			t.Errorf("error for %q should contain %q, got %q", tt.input, tt.errContains, err.Error())
+		}
+	}
+}
+
+func TestImportCompilesModuleOnce(t *testing.T) {
+	loads := map[string]int{}
+	loader := ModuleLoaderFunc(func(path string) (string, error) {
+		loads[path]++
+		if path == "shared" {
+			return `var Value = 1`, nil
+		}
+		return `import "shared"`, nil
+	})
+
+	input := `import "shared"; import "user"; import "shared" as s`
+	if err := New(WithModuleLoader(loader)).Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	if loads["shared"] != 1 || loads["user"] != 1 {
+		t.Errorf("each module should be loaded once, got %v", loads)
+	}
+}
@@ -0,0 +1,66 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/opcode"
+)
+
+func TestNullSafeOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 ?? 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code{
+				{opcode.OpConstant, []int{0}},  // 0000
+				{opcode.OpDup, []int{}},        // 0003
+				{opcode.OpJumpNull, []int{10}}, // 0004
+				{opcode.OpJump, []int{14}},     // 0007
+				{opcode.OpPop, []int{}},        // 0010
+				{opcode.OpConstant, []int{1}},  // 0011
+				{opcode.OpPop, []int{}},        // 0014
+			},
+		},
+		{
+			input:             "null?[1]",
+			expectedConstants: []any{1},
+			expectedInstructions: []code{
+				{opcode.OpNull, []int{}},      // 0000
+				{opcode.OpDup, []int{}},       // 0001
+				{opcode.OpJumpNull, []int{9}}, // 0002
+				{opcode.OpConstant, []int{0}}, // 0005
+				{opcode.OpIndex, []int{}},     // 0008
+				{opcode.OpPop, []int{}},       // 0009
+			},
+		},
+		{
+			input:             "null?.(1)",
+			expectedConstants: []any{1},
+			expectedInstructions: []code{
+				{opcode.OpNull, []int{}},       // 0000
+				{opcode.OpDup, []int{}},        // 0001
+				{opcode.OpJumpNull, []int{10}}, // 0002
+				{opcode.OpConstant, []int{0}},  // 0005
+				{opcode.OpCall, []int{1}},      // 0008
+				{opcode.OpPop, []int{}},        // 0010
+			},
+		},
+		{
+			// Both links are skipped by the null jump
+			input:             "null?[1][2]",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code{
+				{opcode.OpNull, []int{}},       // 0000
+				{opcode.OpDup, []int{}},        // 0001
+				{opcode.OpJumpNull, []int{13}}, // 0002
+				{opcode.OpConstant, []int{0}},  // 0005
+				{opcode.OpIndex, []int{}},      // 0008
+				{opcode.OpConstant, []int{1}},  // 0009
+				{opcode.OpIndex, []int{}},      // 0012
+				{opcode.OpPop, []int{}},        // 0013
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
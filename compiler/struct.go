@@ -0,0 +1,77 @@
+package compiler
+
+import (
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+// defineStructType binds the struct type declared by node as a constant.
+// The type is complete as soon as it is declared, so its value is known at
+// compile time and references to it are inlined, even from code that
+// precedes the declaration.
+func (c *Compiler) defineStructType(node *ast.TypeStatement) Symbol {
+	fields := make([]string, len(node.Fields))
+	for i, field := range node.Fields {
+		fields[i] = field.Value
+	}
+
+	symbol := c.symbolTable.DefineConst(node.Name.Value)
+	symbol.Value = object.NewStructType(node.Name.Value, fields)
+	c.symbolTable.SetConstantValue(node.Name.Value, symbol.Value)
+	return symbol
+}
+
+// compileIsTest tests the value on top of the stack against the type on the
+// right side of 'is' (or in a type switch case). Builtin type names compile
+// to OpIs; anything else is loaded as a value and compared by OpIsType.
+func (c *Compiler) compileIsTest(node ast.Expression) error {
+	typeID, err := isTypeID(node)
+	if err == nil {
+		c.emit(opcode.OpIs, typeID)
+		return nil
+	}
+	if !c.isTypeValue(node) {
+		return err
+	}
+
+	err = c.Compile(node)
+	if err != nil {
+		return err
+	}
+	c.emit(opcode.OpIsType)
+	return nil
+}
+
+// isTypeValue reports whether node names a type held in a variable, such as
+// a struct type, rather than a builtin type name.
+func (c *Compiler) isTypeValue(node ast.Expression) bool {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		return ok && symbol.Scope != BuiltinScope
+	case *ast.MemberExpression:
+		return true // a type exported by a module: mod.Vec
+	default:
+		return false
+	}
+}
+
+// checkStructConstruction rejects a call that does not pass one value per
+// field of the struct type it constructs, when the type is known at compile
+// time.
+func (c *Compiler) checkStructConstruction(node *ast.CallExpression) error {
+	ident, ok := node.Function.(*ast.Identifier)
+	if !ok || hasSpread(node.Arguments) {
+		return nil
+	}
+	symbol, ok := c.symbolTable.Resolve(ident.Value)
+	if !ok {
+		return nil
+	}
+	structType, ok := symbol.Value.(*object.StructType)
+	if !ok {
+		return nil
+	}
+	return structType.CheckArity(len(node.Arguments))
+}
@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+func TestTypeStatement(t *testing.T) {
+	comp := New()
+	if err := comp.Compile(parse("type Vec = struct { x, y }; Vec(1, 2)")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	vec, ok := bytecode.Constants[0].(*object.StructType)
+	if !ok {
+		t.Fatalf("constant 0 is %T, want *object.StructType", bytecode.Constants[0])
+	}
+	if vec.Name != "Vec" || strings.Join(vec.Fields, ",") != "x,y" {
+		t.Errorf("wrong struct type. got=%s", vec.Inspect())
+	}
+	if i, ok := vec.FieldIndex("y"); !ok || i != 1 {
+		t.Errorf("wrong slot for y. got=%d, %t", i, ok)
+	}
+
+	// The reference to Vec is inlined rather than read from its global
+	err := testInstructions([]code{
+		{opcode.OpConstant, []int{0}},
+		{opcode.OpSetGlobal, []int{0}},
+		{opcode.OpConstant, []int{1}},
+		{opcode.OpConstant, []int{2}},
+		{opcode.OpConstant, []int{3}},
+		{opcode.OpCall, []int{2}},
+		{opcode.OpPop, []int{}},
+	}, bytecode.Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+	if bytecode.Constants[1] != vec {
+		t.Errorf("reference to Vec does not use the declared type")
+	}
+}
+
+func TestIsStructType(t *testing.T) {
+	tests := []struct {
+		input  string
+		isType bool
+	}{
+		{"1 is int", false},
+		{"type Vec = struct { x }; 1 is Vec", true},
+		{"var T = null; 1 is T", true},
+		{"switch 1 is { case int: 1 }", false},
+		{"type Vec = struct { x }; switch 1 is { case Vec: 1 }", true},
+	}
+
+	for _, tt := range tests {
+		comp := New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+		if got := containsOpcode(comp.Bytecode().Instructions, opcode.OpIsType); got != tt.isType {
+			t.Errorf("%q: OpIsType emitted=%t, want %t", tt.input, got, tt.isType)
+		}
+	}
+}
+
+func TestStructCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"type Vec = struct { x, y }; Vec(1, 2, 3)", "wrong number of values for struct Vec: want=2, got=3"},
+		{"type Vec = struct { x, y }; Vec(1)", "wrong number of values for struct Vec: want=2, got=1"},
+		{"type Vec = struct { x, y }; Vec()", "wrong number of values for struct Vec: want=2, got=0"},
+		{"type Vec = struct { x }; Vec = 1", "cannot assign to constant Vec"},
+		{"1 is Vec", "unknown type for 'is' operator: Vec"},
+		{"type Vec = struct { x }; switch 1 is { case Vec: 1 case Vec: 2 }", "duplicate case Vec in switch"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compile error for %q, got nil", tt.input)
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
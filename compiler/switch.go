@@ -0,0 +1,235 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+// compileSwitch compiles a switch statement. Cases are tested in order and
+// only the body of the first match runs; there is no fallthrough. A tagged
+// switch keeps its subject on the stack while the cases are tested and pops
+// it on entry to the chosen body. When every case value is a constant
+// integer, a single OpJumpTable replaces the tests.
+func (c *Compiler) compileSwitch(node *ast.SwitchStatement) error {
+	err := c.checkDuplicateCases(node)
+	if err != nil {
+		return err
+	}
+
+	if keys, ok := c.switchTableKeys(node); ok {
+		return c.compileSwitchTable(node, keys)
+	}
+
+	tagged := node.Subject != nil
+	if tagged {
+		err := c.Compile(node.Subject)
+		if err != nil {
+			return err
+		}
+	}
+
+	bodyJumps := make([][]int, len(node.Cases))
+	for i, clause := range node.Cases {
+		for _, value := range clause.Values {
+			c.lastLine = clause.Token.Line
+			err := c.compileCaseTest(node, value)
+			if err != nil {
+				return err
+			}
+			bodyJumps[i] = append(bodyJumps[i], c.emit(opcode.OpJumpNotTruthy, 9999))
+		}
+	}
+
+	c.lastLine = node.Token.Line
+	defaultJump := c.emit(opcode.OpJump, 9999)
+
+	return c.compileSwitchBodies(node, tagged,
+		func(i, pos int) {
+			for _, jump := range bodyJumps[i] {
+				c.changeOperand(jump, pos)
+			}
+		},
+		func(pos int) {
+			c.changeOperand(defaultJump, pos)
+		},
+	)
+}
+
+// compileCaseTest leaves a value on the stack that is falsy when value
+// matches, so that a single OpJumpNotTruthy enters the case body.
+func (c *Compiler) compileCaseTest(node *ast.SwitchStatement, value ast.Expression) error {
+	switch {
+	case node.TypeSwitch:
+		c.emit(opcode.OpDup)
+		err := c.compileIsTest(value)
+		if err != nil {
+			return err
+		}
+		c.emit(opcode.OpBang)
+
+	case node.Subject != nil:
+		c.emit(opcode.OpDup)
+		err := c.Compile(value)
+		if err != nil {
+			return err
+		}
+		c.emit(opcode.OpNotEqual)
+
+	default:
+		// switch { case cond: ... }
+		err := c.Compile(value)
+		if err != nil {
+			return err
+		}
+		c.emit(opcode.OpBang)
+	}
+	return nil
+}
+
+// compileSwitchTable compiles a switch whose case values are all constant
+// integers: OpJumpTable pops the subject and jumps straight to the body.
+func (c *Compiler) compileSwitchTable(node *ast.SwitchStatement, keys [][]int64) error {
+	err := c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+
+	table := &object.JumpTable{Targets: make(map[int64]int)}
+	c.lastLine = node.Token.Line
+	c.emit(opcode.OpJumpTable, c.addConstant(table))
+
+	return c.compileSwitchBodies(node, false,
+		func(i, pos int) {
+			for _, key := range keys[i] {
+				table.Targets[key] = pos
+			}
+		},
+		func(pos int) {
+			table.Default = pos
+		},
+	)
+}
+
+// compileSwitchBodies emits each case body followed by the default body,
+// reporting their offsets through caseStart and defaultStart. The switch is
+// a break target for the statements in its bodies.
+func (c *Compiler) compileSwitchBodies(
+	node *ast.SwitchStatement,
+	popSubject bool,
+	caseStart func(i, pos int),
+	defaultStart func(pos int),
+) error {
+	loop := c.enterLoop("")
+	loop.breakOnly = true
+
+	endJumps := []int{}
+	for i, clause := range node.Cases {
+		caseStart(i, len(c.currentInstructions()))
+		c.lastLine = clause.Token.Line
+		if popSubject {
+			c.emit(opcode.OpPop)
+		}
+
+		err := c.Compile(clause.Body)
+		if err != nil {
+			return err
+		}
+		endJumps = append(endJumps, c.emit(opcode.OpJump, 9999))
+	}
+
+	defaultStart(len(c.currentInstructions()))
+	c.lastLine = node.Token.Line
+	if popSubject {
+		c.emit(opcode.OpPop)
+	}
+	if node.Default != nil {
+		err := c.Compile(node.Default)
+		if err != nil {
+			return err
+		}
+	}
+
+	end := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		c.changeOperand(pos, end)
+	}
+	c.leaveLoop(loop, end, end)
+
+	return nil
+}
+
+// switchTableKeys returns the integer values of every case when a jump table
+// can be used for node.
+func (c *Compiler) switchTableKeys(node *ast.SwitchStatement) ([][]int64, bool) {
+	if node.Subject == nil || node.TypeSwitch || len(node.Cases) == 0 {
+		return nil, false
+	}
+
+	keys := make([][]int64, len(node.Cases))
+	for i, clause := range node.Cases {
+		for _, value := range clause.Values {
+			constant, _, ok := c.constantValue(value)
+			if !ok {
+				return nil, false
+			}
+			integer, ok := constant.(*object.Integer)
+			if !ok {
+				return nil, false
+			}
+			keys[i] = append(keys[i], integer.Value)
+		}
+	}
+	return keys, true
+}
+
+// checkDuplicateCases rejects a constant case value (or a type, in a type
+// switch) that appears more than once, since the later case could never run.
+func (c *Compiler) checkDuplicateCases(node *ast.SwitchStatement) error {
+	if node.Subject == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, clause := range node.Cases {
+		for _, value := range clause.Values {
+			var key string
+			if node.TypeSwitch {
+				typeID, err := isTypeID(value)
+				switch {
+				case err == nil:
+					key = fmt.Sprintf("type:%d", typeID)
+				case c.isTypeValue(value):
+					key = "value:" + value.String()
+				default:
+					return err
+				}
+			} else if constant, _, ok := c.constantValue(value); ok {
+				key = constantKey(constant)
+			} else {
+				continue
+			}
+
+			if seen[key] {
+				return fmt.Errorf("duplicate case %s in switch", value.String())
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+func constantKey(value object.Object) string {
+	switch value := value.(type) {
+	case *object.Integer:
+		return fmt.Sprintf("int:%d", value.Value)
+	case *object.Float:
+		return fmt.Sprintf("float:%v", value.Value)
+	case *object.String:
+		return "string:" + value.Value
+	default:
+		return string(value.Type()) + ":" + value.Inspect()
+	}
+}
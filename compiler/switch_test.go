@@ -0,0 +1,113 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+func TestSwitchJumpTable(t *testing.T) {
+	tests := []struct {
+		input     string
+		jumpTable bool
+	}{
+		{"switch 1 { case 1: 10 case 2, 3: 20 }", true},
+		{"const A = 4; switch 1 { case A: 10 case A + 1: 20 default: 30 }", true},
+		{"switch 1 { case 1: 10 case 2.0: 20 }", false},
+		{`switch 1 { case 1: 10 case "a": 20 }`, false},
+		{"var x = 1; switch 1 { case x: 10 }", false},
+		{"switch { case true: 10 }", false},
+		{"switch 1 is { case int: 10 }", false},
+	}
+
+	for _, tt := range tests {
+		comp := New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+
+		bytecode := comp.Bytecode()
+		found := false
+		for _, constant := range bytecode.Constants {
+			if _, ok := constant.(*object.JumpTable); ok {
+				found = true
+			}
+		}
+		emitted := containsOpcode(bytecode.Instructions, opcode.OpJumpTable)
+		if found != tt.jumpTable || emitted != tt.jumpTable {
+			t.Errorf("%q: expected jump table=%t, got constant=%t opcode=%t",
+				tt.input, tt.jumpTable, found, emitted)
+		}
+	}
+}
+
+// containsOpcode walks ins instruction by instruction looking for op.
+func containsOpcode(ins []byte, op opcode.Opcode) bool {
+	for i := 0; i < len(ins); {
+		def, err := opcode.Lookup(ins[i])
+		if err != nil {
+			return false
+		}
+		if opcode.Opcode(ins[i]) == op {
+			return true
+		}
+		_, read := opcode.ReadOperands(def, ins[i+1:])
+		i += 1 + read
+	}
+	return false
+}
+
+func TestSwitchJumpTableTargets(t *testing.T) {
+	comp := New()
+	if err := comp.Compile(parse("switch 5 { case 1, 2: 10 default: 30 }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := comp.Bytecode()
+	var table *object.JumpTable
+	for _, constant := range bytecode.Constants {
+		if jt, ok := constant.(*object.JumpTable); ok {
+			table = jt
+		}
+	}
+	if table == nil {
+		t.Fatalf("no jump table in constants")
+	}
+
+	if table.Targets[1] != table.Targets[2] {
+		t.Errorf("case 1 and 2 should share a target. got=%d, %d", table.Targets[1], table.Targets[2])
+	}
+	if table.Default <= table.Targets[1] {
+		t.Errorf("default should follow the case body. got default=%d case=%d", table.Default, table.Targets[1])
+	}
+	if op := opcode.Opcode(bytecode.Instructions[table.Default]); op != opcode.OpConstant {
+		t.Errorf("default target is not the default body. got=%d", op)
+	}
+}
+
+func TestSwitchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"switch 1 { case 1: 10 case 1: 20 }", "duplicate case 1 in switch"},
+		{`switch "a" { case "a", "b": 10 case "b": 20 }`, "duplicate case b in switch"},
+		{"const A = 1; switch 1 { case A: 10 case 1: 20 }", "duplicate case 1 in switch"},
+		{"switch 1 is { case int: 10 case integer: 20 }", "duplicate case integer in switch"},
+		{"switch 1 is { case widget: 10 }", "unknown type for 'is' operator: widget"},
+		{"switch 1 { case 1: continue }", "continue statement outside of loop"},
+		{"switch 1 { case 1: break nowhere }", "label not defined"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compile error for %q, got nil", tt.input)
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package compiler
 
+import (
+	"fmt"
+
+	"github.com/iceisfun/icescript/object"
+)
+
 type SymbolScope string
 
 const (
@@ -14,6 +20,9 @@ type Symbol struct {
 	Name  string
 	Scope SymbolScope
 	Index int
+
+	Constant bool          // declared with const; assignments are compile errors
+	Value    object.Object // folded value of a constant, or nil if only known at runtime
 }
 
 type SymbolTable struct {
@@ -21,10 +30,18 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 	FreeSymbols    []Symbol
+
+	captured   map[int]bool // local indexes captured by an inner function
+	root       *SymbolTable // allocates global indexes for module tables
+	localNames []string     // the name defined for each local index
 }
 
 func NewSymbolTable() *SymbolTable {
-	s := &SymbolTable{store: make(map[string]Symbol), FreeSymbols: []Symbol{}}
+	s := &SymbolTable{
+		store:       make(map[string]Symbol),
+		FreeSymbols: []Symbol{},
+		captured:    make(map[int]bool),
+	}
 	return s
 }
 
@@ -34,12 +51,29 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// NewModuleSymbolTable creates the global table of an imported module. Its
+// names are private to the module, but its global slots are allocated from the
+// program's table so that they never collide with other globals.
+func NewModuleSymbolTable(program *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.root = program
+	if program.root != nil {
+		s.root = program.root
+	}
+	return s
+}
+
 func (s *SymbolTable) Define(name string) Symbol {
 	symbol := Symbol{Name: name, Index: s.numDefinitions}
 	if s.Outer == nil {
 		symbol.Scope = GlobalScope
+		if s.root != nil {
+			symbol.Index = s.root.numDefinitions
+			s.root.numDefinitions++
+		}
 	} else {
 		symbol.Scope = LocalScope
+		s.localNames = append(s.localNames, name)
 	}
 
 	s.store[name] = symbol
@@ -47,6 +81,38 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// DefineConst defines an immutable name. Its folded value, if any, is
+// recorded with SetConstantValue once the initializer has been compiled.
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	symbol := s.Define(name)
+	symbol.Constant = true
+	s.store[name] = symbol
+	return symbol
+}
+
+// SetConstantValue records the compile-time value of the constant name, so
+// that references to it can be inlined.
+func (s *SymbolTable) SetConstantValue(name string, value object.Object) {
+	symbol, ok := s.store[name]
+	if !ok || !symbol.Constant {
+		return
+	}
+	symbol.Value = value
+	s.store[name] = symbol
+}
+
+// ResolveAssignable resolves name as the target of an assignment.
+func (s *SymbolTable) ResolveAssignable(name string) (Symbol, error) {
+	symbol, ok := s.Resolve(name)
+	if !ok {
+		return symbol, fmt.Errorf("variable %s not defined", name)
+	}
+	if symbol.Constant {
+		return symbol, fmt.Errorf("cannot assign to constant %s", name)
+	}
+	return symbol, nil
+}
+
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
 	if !ok && s.Outer != nil {
@@ -59,16 +125,40 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 			return obj, ok
 		}
 
+		if obj.Scope == LocalScope {
+			// A local of the enclosing function escapes into this one; it must
+			// live in a shared cell so both sides observe each other's writes.
+			s.Outer.captured[obj.Index] = true
+		}
+
 		free := s.defineFree(obj)
 		return free, true
 	}
 	return obj, ok
 }
 
+// LocalNames returns the name of each local slot, indexed like the slots.
+// Unlike Resolve, it keeps names that a later definition has shadowed.
+func (s *SymbolTable) LocalNames() []string {
+	return s.localNames
+}
+
+// IsCaptured reports whether the local with the given index is referenced by
+// an inner function.
+func (s *SymbolTable) IsCaptured(index int) bool {
+	return s.captured[index]
+}
+
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 
-	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	symbol := Symbol{
+		Name:     original.Name,
+		Index:    len(s.FreeSymbols) - 1,
+		Scope:    FreeScope,
+		Constant: original.Constant,
+		Value:    original.Value,
+	}
 	s.store[original.Name] = symbol
 	return symbol
 }
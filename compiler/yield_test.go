@@ -0,0 +1,77 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+func TestYield(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `func() { var x = yield 1; yield }`,
+			expectedConstants: []any{
+				1,
+				[]code{
+					{opcode.OpConstant, []int{0}},
+					{opcode.OpYield, []int{}},
+					{opcode.OpSetLocal, []int{0}},
+					{opcode.OpNull, []int{}},
+					{opcode.OpYield, []int{}},
+					{opcode.OpReturnValue, []int{}},
+				},
+			},
+			expectedInstructions: []code{
+				{opcode.OpClosure, []int{1, 0}},
+				{opcode.OpPop, []int{}},
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestGeneratorFunctions(t *testing.T) {
+	tests := []struct {
+		input      string
+		generators []bool // per compiled function constant, in order
+	}{
+		{"func() { return 1 }", []bool{false}},
+		{"func() { yield 1 }", []bool{true}},
+		{"func() { if true { for { yield } } }", []bool{true}},
+		// A nested function is a generator of its own
+		{"func() { return func() { yield 1 } }", []bool{true, false}},
+		{"func() { yield func() { return 1 } }", []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		comp := New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		var generators []bool
+		for _, constant := range comp.Bytecode().Constants {
+			if fn, ok := constant.(*object.CompiledFunction); ok {
+				generators = append(generators, fn.Generator)
+			}
+		}
+		if len(generators) != len(tt.generators) {
+			t.Fatalf("%q: wrong number of functions. want=%d, got=%d", tt.input, len(tt.generators), len(generators))
+		}
+		for i, want := range tt.generators {
+			if generators[i] != want {
+				t.Errorf("%q: function %d Generator wrong. want=%t, got=%t", tt.input, i, want, generators[i])
+			}
+		}
+	}
+}
+
+func TestYieldOutsideFunction(t *testing.T) {
+	comp := New()
+	err := comp.Compile(parse("yield 1"))
+	if err == nil || !strings.Contains(err.Error(), "yield outside of function") {
+		t.Fatalf("expected yield outside of function error, got %v", err)
+	}
+}
@@ -1,6 +1,12 @@
 package lexer
 
-import "github.com/iceisfun/icescript/token"
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/iceisfun/icescript/token"
+)
 
 type Lexer struct {
 	input        string
@@ -9,7 +15,11 @@ type Lexer struct {
 	ch           byte // current char under examination
 	line         int
 	col          int
-	parenCount   int // track nesting level of ( ) and [ ]
+	parenCount   int   // track nesting level of ( ) and [ ]
+	interpDepth  []int // brace depth within each open ${ } of an interpolated string
+
+	badEscape     string // first malformed escape of the string being read
+	badEscapeLine int
 }
 
 func New(input string) *Lexer {
@@ -36,6 +46,24 @@ func (l *Lexer) peekChar() byte {
 	return l.input[l.readPosition]
 }
 
+// peekCharAt returns the character n places after the next one.
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.readPosition+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.readPosition+n]
+}
+
+// operator returns a token of type t for the n characters starting at the
+// current one, leaving the last of them as the current character.
+func (l *Lexer) operator(t token.TokenType, n int) token.Token {
+	start := l.position
+	for i := 1; i < n; i++ {
+		l.readChar()
+	}
+	return token.Token{Type: t, Literal: l.input[start : l.position+1]}
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
@@ -64,9 +92,29 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '+' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.INCREMENT, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '-' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.DECREMENT, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -83,14 +131,35 @@ func (l *Lexer) NextToken() token.Token {
 		} else if l.peekChar() == '*' {
 			l.skipMultiLineComment()
 			return l.NextToken()
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SLASH_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.SLASH, l.ch)
 		}
-		tok = newToken(token.SLASH, l.ch)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ASTERISK_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
 	case '%':
-		tok = newToken(token.MOD, l.ch)
-	case '<':
 		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.MOD_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.MOD, l.ch)
+		}
+	case '<':
+		if l.peekChar() == '<' && l.peekCharAt(1) == '=' {
+			tok = l.operator(token.SHL_ASSIGN, 3)
+		} else if l.peekChar() == '<' {
+			tok = l.operator(token.SHL, 2)
+		} else if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.LTE, Literal: string(ch) + string(l.ch)}
@@ -98,7 +167,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.LT, l.ch)
 		}
 	case '>':
-		if l.peekChar() == '=' {
+		if l.peekChar() == '>' && l.peekCharAt(1) == '=' {
+			tok = l.operator(token.SHR_ASSIGN, 3)
+		} else if l.peekChar() == '>' {
+			tok = l.operator(token.SHR, 2)
+		} else if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.GTE, Literal: string(ch) + string(l.ch)}
@@ -125,7 +198,25 @@ func (l *Lexer) NextToken() token.Token {
 		l.parenCount--
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
+		if n := len(l.interpDepth); n > 0 {
+			l.interpDepth[n-1]++
+		}
 	case '}':
+		n := len(l.interpDepth)
+		if n > 0 && l.interpDepth[n-1] == 0 {
+			// The } closing ${ resumes the string
+			l.interpDepth = l.interpDepth[:n-1]
+			l.parenCount--
+			tok.Literal = l.readString()
+			tok.Type = token.INTERP_END
+			if l.ch == '{' {
+				tok.Type = token.INTERP_MIDDLE
+			}
+			break
+		}
+		if n > 0 {
+			l.interpDepth[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
@@ -134,23 +225,60 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 		l.parenCount--
 	case '.':
-		tok = newToken(token.DOT, l.ch)
+		if l.peekChar() == '.' && l.peekCharAt(1) == '.' {
+			tok = l.operator(token.ELLIPSIS, 3)
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
+		if l.ch == '{' {
+			tok.Type = token.INTERP_BEGIN
+		}
+	case '`':
+		tok.Type = token.STRING
+		tok.Literal = l.readRawString()
 	case '&':
 		if l.peekChar() == '&' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '^' && l.peekCharAt(1) == '=' {
+			tok = l.operator(token.AND_NOT_ASSIGN, 3)
+		} else if l.peekChar() == '^' {
+			tok = l.operator(token.AND_NOT, 2)
+		} else if l.peekChar() == '=' {
+			tok = l.operator(token.AND_ASSIGN, 2)
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = newToken(token.BIT_AND, l.ch)
 		}
 	case '|':
 		if l.peekChar() == '|' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.OR, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			tok = l.operator(token.OR_ASSIGN, 2)
+		} else {
+			tok = newToken(token.BIT_OR, l.ch)
+		}
+	case '^':
+		if l.peekChar() == '=' {
+			tok = l.operator(token.XOR_ASSIGN, 2)
+		} else {
+			tok = newToken(token.BIT_XOR, l.ch)
+		}
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
+	case '?':
+		if l.peekChar() == '?' {
+			tok = l.operator(token.NULLISH, 2)
+		} else if l.peekChar() == '[' {
+			tok = l.operator(token.QUESTION_LBRACKET, 2)
+			l.parenCount++
+		} else if l.peekChar() == '.' {
+			tok = l.operator(token.QUESTION_DOT, 2)
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
@@ -180,6 +308,14 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
+	if l.badEscape != "" {
+		// A string with a malformed escape is reported as an ILLEGAL token
+		// holding the escape, on the line where the escape is
+		tok = token.Token{Type: token.ILLEGAL, Literal: l.badEscape}
+		startLine = l.badEscapeLine
+		l.badEscape = ""
+	}
+
 	tok.Line = startLine
 	tok.Col = startCol
 	l.readChar()
@@ -241,30 +377,64 @@ func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
+// readNumber reads an integer or float literal: decimal, with an optional
+// fraction and exponent, or an integer with a 0x, 0b or 0o prefix. Digits may
+// be separated by underscores. The literal is validated by the parser.
 func (l *Lexer) readNumber() (string, bool) {
 	position := l.position
 	isFloat := false
-	for isDigit(l.ch) {
+
+	if l.ch == '0' && strings.IndexByte("xXbBoO", l.peekChar()) >= 0 {
 		l.readChar()
+		l.readChar()
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return l.input[position:l.position], false
 	}
+
+	l.readDigits()
 	if l.ch == '.' && isDigit(l.peekChar()) {
 		isFloat = true
 		l.readChar()
-		for isDigit(l.ch) {
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekCharAt(1))) {
+			isFloat = true
 			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
 		}
 	}
 	return l.input[position:l.position], isFloat
 }
 
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// readString reads the characters of a double-quoted string up to its closing
+// quote, which is left as the current character. When it reaches ${ instead,
+// the { is left as the current character and the lexer enters the
+// interpolated expression; the matching } continues the string.
 func (l *Lexer) readString() string {
 	var out []byte
 	for {
@@ -273,29 +443,114 @@ func (l *Lexer) readString() string {
 			break
 		}
 
+		if l.ch == '$' && l.peekChar() == '{' {
+			l.readChar()
+			l.interpDepth = append(l.interpDepth, 0)
+			// Newlines inside ${ } do not end statements
+			l.parenCount++
+			break
+		}
+
 		if l.ch == '\\' {
-			switch l.peekChar() {
-			case 'n':
-				l.readChar()
-				out = append(out, '\n')
-			case 't':
-				l.readChar()
-				out = append(out, '\t')
-			case 'r':
-				l.readChar()
-				out = append(out, '\r')
-			case '"':
-				l.readChar()
-				out = append(out, '"')
-			case '\\':
-				l.readChar()
-				out = append(out, '\\')
-			default:
-				out = append(out, '\\')
-			}
-		} else {
-			out = append(out, l.ch)
+			out = l.readEscape(out)
+			continue
+		}
+
+		if l.ch == '\n' {
+			l.line++
+			l.col = 0
+		}
+		out = append(out, l.ch)
+	}
+	return string(out)
+}
+
+// readEscape appends the escape sequence starting at the current backslash to
+// out. Unknown escapes are kept as written.
+func (l *Lexer) readEscape(out []byte) []byte {
+	switch l.peekChar() {
+	case 'n':
+		l.readChar()
+		return append(out, '\n')
+	case 't':
+		l.readChar()
+		return append(out, '\t')
+	case 'r':
+		l.readChar()
+		return append(out, '\r')
+	case '0':
+		l.readChar()
+		return append(out, 0)
+	case '"', '\\', '$':
+		l.readChar()
+		return append(out, l.ch)
+	case 'x':
+		if value, ok := l.readHexEscape(2); ok {
+			out = append(out, byte(value))
+		}
+		return out
+	case 'u':
+		if value, ok := l.readHexEscape(4); ok {
+			out = utf8.AppendRune(out, rune(value))
+		}
+		return out
+	case 'U':
+		if value, ok := l.readHexEscape(8); ok {
+			out = utf8.AppendRune(out, rune(value))
+		}
+		return out
+	}
+	return append(out, '\\')
+}
+
+// readHexEscape reads a \x escape of 2 hex digits, or a \u or \U escape of n
+// hex digits naming a Unicode code point, leaving its last character as the
+// current one. A malformed escape is recorded for NextToken to report.
+func (l *Lexer) readHexEscape(n int) (uint64, bool) {
+	start := l.position
+	l.readChar()
+	kind := l.ch
+	for i := 0; i < n && isEscapeDigit(l.peekChar()); i++ {
+		l.readChar()
+	}
+
+	digits := l.input[start+2 : l.position+1]
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) < n || kind != 'x' && !utf8.ValidRune(rune(value)) {
+		if l.badEscape == "" {
+			l.badEscape = l.input[start : l.position+1]
+			l.badEscapeLine = l.line
+		}
+		return 0, false
+	}
+	return value, true
+}
+
+// isEscapeDigit reports whether ch may be part of the digits of a hex escape,
+// valid or not: anything up to the end of the string, the next escape or a
+// non-ASCII character.
+func isEscapeDigit(ch byte) bool {
+	return ch > ' ' && ch < utf8.RuneSelf && ch != '"' && ch != '\\' && ch != '$'
+}
+
+// readRawString reads a backtick string, which has no escapes and may span
+// lines. Carriage returns are dropped so that the value does not depend on
+// the line endings of the source file.
+func (l *Lexer) readRawString() string {
+	var out []byte
+	for {
+		l.readChar()
+		if l.ch == '`' || l.ch == 0 {
+			break
+		}
+		if l.ch == '\r' {
+			continue
+		}
+		if l.ch == '\n' {
+			l.line++
+			l.col = 0
 		}
+		out = append(out, l.ch)
 	}
 	return string(out)
 }
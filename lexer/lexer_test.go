@@ -230,3 +230,449 @@ func TestStringEscapeSequences(t *testing.T) {
 		}
 	}
 }
+
+func TestCompoundAssignmentOperators(t *testing.T) {
+	input := `x += 1; x -= 2; x *= 3; x /= 4; x %= 5; x++; x--; a - -b; a / b`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MOD_ASSIGN, "%="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.INCREMENT, "++"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.DECREMENT, "--"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.IDENT, "b"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.SLASH, "/"},
+		{token.IDENT, "b"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	input := `func f(a, ...rest) { f(...xs); m.x; 1.5 }`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "func"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "m"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "1.5"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb\tc\rd"`, "a\nb\tc\rd"},
+		{`"quote \" backslash \\"`, `quote " backslash \`},
+		{`"café"`, "café"},
+		{`"\x41\x42"`, "AB"},
+		{`"\U0001F600"`, "😀"},
+		{`"nul\0"`, "nul\x00"},
+		{`"\${x}"`, "${x}"},
+		{`"$x {y} $"`, "$x {y} $"},
+		{`"\q"`, `\q`},
+		{`"\u00e9\U0010FFFF"`, "é\U0010FFFF"},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != token.STRING {
+			t.Fatalf("%s: token type wrong. expected=%q, got=%q", tt.input, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("%s: literal wrong. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+	}
+}
+
+func TestInvalidStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		line     int
+	}{
+		{`"\xZZ"`, `\xZZ`, 1},
+		{`"\x4"`, `\x4`, 1},
+		{`"\u12"`, `\u12`, 1},
+		{`"\u12G4 ok"`, `\u12G4`, 1},
+		{`"\ud800"`, `\ud800`, 1},
+		{`"\U00110000"`, `\U00110000`, 1},
+		{`"\U0000D800"`, `\U0000D800`, 1},
+		{`"\x\n"`, `\x`, 1},
+		{`"\u12${x}"`, `\u12`, 1},
+		{"\"first\nsecond \\x1 \\xZZ\"", `\x1`, 2},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("%s: token type wrong. expected=%q, got=%q", tt.input, token.ILLEGAL, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("%s: literal wrong. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+		if tok.Line != tt.line {
+			t.Errorf("%s: line wrong. expected=%d, got=%d", tt.input, tt.line, tok.Line)
+		}
+	}
+
+	// The lexer carries on after the malformed string
+	l := New(`"\xZZ" + "ok"`)
+	for _, want := range []token.TokenType{token.ILLEGAL, token.PLUS, token.STRING, token.EOF} {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("token type wrong. expected=%q, got=%q", want, tok.Type)
+		}
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	input := "x = `raw \\n ${y}\r\nsecond \"line\"`\nz"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.IDENT, "x", 1},
+		{token.ASSIGN, "=", 1},
+		{token.STRING, "raw \\n ${y}\nsecond \"line\"", 1},
+		{token.SEMICOLON, ";", 2},
+		{token.IDENT, "z", 3},
+		{token.EOF, "", 3},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Line)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := "\"hp: ${e[\"hp\"]}/${\n  max\n} {ok}\"\nnext \"${ {\"a\": 1}[\"a\"] }${\"in ${x}\"}\""
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.INTERP_BEGIN, "hp: ", 1},
+		{token.IDENT, "e", 1},
+		{token.LBRACKET, "[", 1},
+		{token.STRING, "hp", 1},
+		{token.RBRACKET, "]", 1},
+		{token.INTERP_MIDDLE, "/", 1},
+		{token.IDENT, "max", 2},
+		{token.INTERP_END, " {ok}", 3},
+		{token.SEMICOLON, ";", 3},
+		{token.IDENT, "next", 4},
+		{token.INTERP_BEGIN, "", 4},
+		{token.LBRACE, "{", 4},
+		{token.STRING, "a", 4},
+		{token.COLON, ":", 4},
+		{token.INT, "1", 4},
+		{token.RBRACE, "}", 4},
+		{token.LBRACKET, "[", 4},
+		{token.STRING, "a", 4},
+		{token.RBRACKET, "]", 4},
+		{token.INTERP_MIDDLE, "", 4},
+		{token.INTERP_BEGIN, "in ", 4},
+		{token.IDENT, "x", 4},
+		{token.INTERP_END, "", 4},
+		{token.INTERP_END, "", 4},
+		{token.EOF, "", 4},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Line)
+		}
+	}
+}
+
+func TestMultiLineStringLineTracking(t *testing.T) {
+	l := New("\"a\nb\nc\"\nx")
+
+	tok := l.NextToken()
+	if tok.Type != token.STRING || tok.Literal != "a\nb\nc" {
+		t.Fatalf("unexpected token %q %q", tok.Type, tok.Literal)
+	}
+	l.NextToken() // newline
+	tok = l.NextToken()
+	if tok.Literal != "x" || tok.Line != 4 {
+		t.Fatalf("expected x on line 4, got %q on line %d", tok.Literal, tok.Line)
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	input := `a & b | c ^ d &^ e << f >> g ~h && i || j
+x &= 1; x |= 2; x ^= 3; x &^= 4; x <<= 5; x >>= 6; a<b; a<=b; a>=b`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "b"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "c"},
+		{token.BIT_XOR, "^"},
+		{token.IDENT, "d"},
+		{token.AND_NOT, "&^"},
+		{token.IDENT, "e"},
+		{token.SHL, "<<"},
+		{token.IDENT, "f"},
+		{token.SHR, ">>"},
+		{token.IDENT, "g"},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "h"},
+		{token.AND, "&&"},
+		{token.IDENT, "i"},
+		{token.OR, "||"},
+		{token.IDENT, "j"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.AND_ASSIGN, "&="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.OR_ASSIGN, "|="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.XOR_ASSIGN, "^="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.AND_NOT_ASSIGN, "&^="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SHL_ASSIGN, "<<="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SHR_ASSIGN, ">>="},
+		{token.INT, "6"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.LT, "<"},
+		{token.IDENT, "b"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.LTE, "<="},
+		{token.IDENT, "b"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.GTE, ">="},
+		{token.IDENT, "b"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumericLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"0xFF", token.INT, "0xFF"},
+		{"0Xab_cd", token.INT, "0Xab_cd"},
+		{"0b1010", token.INT, "0b1010"},
+		{"0o17", token.INT, "0o17"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"1e-3", token.FLOAT, "1e-3"},
+		{"2.5E+2", token.FLOAT, "2.5E+2"},
+		{"6e23", token.FLOAT, "6e23"},
+		{"1_000.25", token.FLOAT, "1_000.25"},
+		{"42", token.INT, "42"},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("%s: expected %s %q, got %s %q",
+				tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	// An e that does not start an exponent ends the number
+	l := New("3else")
+	if tok := l.NextToken(); tok.Type != token.INT || tok.Literal != "3" {
+		t.Errorf("3else: expected INT 3, got %s %q", tok.Type, tok.Literal)
+	}
+}
+
+func TestNullSafeOperators(t *testing.T) {
+	input := `a ?? b; m?["k"
+]; f?.(x)
+c ? d`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.NULLISH, "??"},
+		{token.IDENT, "b"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "m"},
+		{token.QUESTION_LBRACKET, "?["},
+		{token.STRING, "k"},
+		// The newline inside ?[ ] is not a statement break
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "f"},
+		{token.QUESTION_DOT, "?."},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "?"},
+		{token.IDENT, "d"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"unicode/utf8"
 )
 
 var Builtins = []struct {
@@ -19,7 +20,7 @@ var Builtins = []struct {
 
 			switch arg := args[0].(type) {
 			case *String:
-				return &Integer{Value: int64(len(arg.Value))}
+				return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *Array:
 				return &Integer{Value: int64(len(arg.Elements))}
 			default:
@@ -80,6 +81,9 @@ var Builtins = []struct {
 				}
 			}
 
+			if err := allocArray(ctx, int64(len(arr.Elements))+count, count); err != nil {
+				return &Critical{Message: err.Error()}
+			}
 			for i := int64(0); i < count; i++ {
 				arr.Elements = append(arr.Elements, args[1])
 			}
@@ -111,7 +115,13 @@ var Builtins = []struct {
 				if !ok {
 					return &Critical{Message: fmt.Sprintf("unusable as hash key: %s", args[1].Type())}
 				}
-				container.Pairs[key.HashKey()] = HashPair{Key: args[1], Value: args[2]}
+				hashKey := key.HashKey()
+				if _, exists := container.Pairs[hashKey]; !exists {
+					if err := allocHash(ctx, int64(len(container.Pairs))+1, 1); err != nil {
+						return &Critical{Message: err.Error()}
+					}
+				}
+				container.Pairs[hashKey] = HashPair{Key: args[1], Value: args[2]}
 				return args[2]
 			default:
 				return &Critical{Message: fmt.Sprintf("argument to `set` not supported, got %s", args[0].Type())}
@@ -130,6 +140,9 @@ var Builtins = []struct {
 				return &Critical{Message: fmt.Sprintf("argument to `keys` must be HASH, got %s", args[0].Type())}
 			}
 
+			if err := allocArray(ctx, int64(len(hash.Pairs)), int64(len(hash.Pairs))); err != nil {
+				return &Critical{Message: err.Error()}
+			}
 			elements := []Object{}
 			for _, pair := range hash.Pairs {
 				elements = append(elements, pair.Key)
@@ -403,6 +416,9 @@ var Builtins = []struct {
 			if len(args) != 1 {
 				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
 			}
+			if st, ok := args[0].(*Struct); ok {
+				return &String{Value: st.Def.Name}
+			}
 			t := args[0].Type()
 			var s string
 			switch t {
@@ -426,12 +442,139 @@ var Builtins = []struct {
 				s = "user"
 			case TUPLE_OBJ:
 				s = "tuple"
+			case MODULE_OBJ:
+				s = "module"
+			case COROUTINE_OBJ:
+				s = "coroutine"
+			case STRUCT_TYPE_OBJ:
+				s = "type"
+			case ENUM_OBJ:
+				s = "enum"
 			default:
 				s = string(t)
 			}
 			return &String{Value: s}
 		}},
 	},
+	{
+		"int",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) != 1 {
+				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+			}
+			switch v := args[0].(type) {
+			case *Integer:
+				return v
+			case *Float:
+				return floatToInteger("int", v.Value)
+			case *Boolean:
+				i, _ := v.AsInt()
+				return &Integer{Value: i}
+			case *String:
+				if i, ok := v.AsInt(); ok {
+					return &Integer{Value: i}
+				}
+				if f, ok := v.AsFloat(); ok {
+					return floatToInteger("int", f)
+				}
+				return &Critical{Message: fmt.Sprintf("cannot convert %q to INTEGER", v.Value)}
+			default:
+				return &Critical{Message: fmt.Sprintf("argument to `int` not supported, got %s", args[0].Type())}
+			}
+		}},
+	},
+	{
+		"float",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) != 1 {
+				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+			}
+			switch v := args[0].(type) {
+			case *Float:
+				return v
+			case *Integer, *Boolean:
+				f, _ := v.AsFloat()
+				return &Float{Value: f}
+			case *String:
+				if f, ok := v.AsFloat(); ok {
+					return &Float{Value: f}
+				}
+				return &Critical{Message: fmt.Sprintf("cannot convert %q to FLOAT", v.Value)}
+			default:
+				return &Critical{Message: fmt.Sprintf("argument to `float` not supported, got %s", args[0].Type())}
+			}
+		}},
+	},
+	{
+		"round",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			return roundNumber("round", math.Round, args)
+		}},
+	},
+	{
+		"floor",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			return roundNumber("floor", math.Floor, args)
+		}},
+	},
+	{
+		"ceil",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			return roundNumber("ceil", math.Ceil, args)
+		}},
+	},
+	{
+		"done",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) != 1 {
+				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+			}
+			co, ok := args[0].(*Coroutine)
+			if !ok {
+				return &Critical{Message: fmt.Sprintf("argument to `done` must be coroutine, got %s", args[0].Type())}
+			}
+			if co.Done() {
+				return True
+			}
+			return False
+		}},
+	},
+}
+
+// roundNumber applies fn to a numeric argument and returns the result as an INTEGER.
+func roundNumber(name string, fn func(float64) float64, args []Object) Object {
+	if len(args) != 1 {
+		return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+	}
+	switch v := args[0].(type) {
+	case *Integer:
+		return v
+	case *Float:
+		return floatToInteger(name, fn(v.Value))
+	default:
+		return &Critical{Message: fmt.Sprintf("argument to `%s` must be number, got %s", name, args[0].Type())}
+	}
+}
+
+// floatToInteger truncates f to an INTEGER. NaN, the infinities and values
+// outside the int64 range are errors rather than whatever the platform's
+// conversion makes of them.
+func floatToInteger(name string, f float64) Object {
+	// -2^63 is exact as a float64; 2^63 is the first value past MaxInt64
+	if !(f >= math.MinInt64 && f < math.MaxInt64) {
+		return &Critical{Message: fmt.Sprintf("argument to `%s` out of INTEGER range, got %v", name, f)}
+	}
+	return &Integer{Value: int64(f)}
+}
+
+func init() {
+	Builtins = append(Builtins, stringBuiltins...)
+	for _, def := range Builtins {
+		// An alias keeps the name of its first registration
+		if def.Builtin.Name == "" {
+			def.Builtin.Name = def.Name
+		}
+	}
 }
 
 var NullObj = &Null{}
@@ -446,3 +589,13 @@ func GetBuiltinByName(name string) *Builtin {
 	}
 	return nil
 }
+
+// GetBuiltinIndex returns the position of the named builtin in Builtins, or -1.
+func GetBuiltinIndex(name string) int {
+	for i, def := range Builtins {
+		if def.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,67 @@
+package object
+
+import "fmt"
+
+// CoroutineStatus is the lifecycle state of a Coroutine.
+type CoroutineStatus int
+
+const (
+	CoroutineSuspended CoroutineStatus = iota // created, or stopped at a yield
+	CoroutineRunning                          // its frame is on the VM's frame stack
+	CoroutineDead                             // returned, or failed with an error
+)
+
+func (s CoroutineStatus) String() string {
+	switch s {
+	case CoroutineSuspended:
+		return "suspended"
+	case CoroutineRunning:
+		return "running"
+	case CoroutineDead:
+		return "dead"
+	default:
+		return fmt.Sprintf("CoroutineStatus(%d)", int(s))
+	}
+}
+
+// Coroutine is a call of a generator function, a function containing yield.
+// Calling a generator binds its arguments and returns a suspended coroutine
+// without running the body; each resume runs it up to the next yield or to
+// its return.
+//
+// While suspended, the coroutine owns the part of the VM stack its frame
+// used: the locals and temporaries from the frame's base pointer up, and the
+// try handlers it had installed. The VM copies them back on top of the
+// resumer's stack when the coroutine is resumed.
+type Coroutine struct {
+	Closure *Closure
+	Status  CoroutineStatus
+	Started bool // the body has run; a resume value becomes the result of yield
+
+	IP       int                // offset of the last executed instruction
+	Stack    []Object           // saved stack slots, relative to the base pointer
+	Handlers []CoroutineHandler // saved try handlers, innermost last
+}
+
+// CoroutineHandler is a try handler installed by a suspended coroutine.
+type CoroutineHandler struct {
+	SP     int // stack depth to unwind to, relative to the base pointer
+	Target int
+}
+
+// Done reports whether the coroutine has finished and cannot be resumed.
+func (co *Coroutine) Done() bool { return co.Status == CoroutineDead }
+
+func (co *Coroutine) Inspect() string {
+	name := co.Closure.Fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return fmt.Sprintf("Coroutine[%s, %s]", name, co.Status)
+}
+func (co *Coroutine) Type() ObjectType { return COROUTINE_OBJ }
+
+func (co *Coroutine) AsFloat() (float64, bool) { return 0, false }
+func (co *Coroutine) AsInt() (int64, bool)     { return 0, false }
+func (co *Coroutine) AsString() (string, bool) { return "", false }
+func (co *Coroutine) AsBool() (bool, bool)     { return false, false }
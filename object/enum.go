@@ -0,0 +1,105 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnumMethods are the attributes of every Enum besides its members, so they
+// cannot be used as member names.
+var EnumMethods = []string{"name", "values"}
+
+// Enum is a group of named integer constants declared by
+// `enum State { Idle, Walk }`. The compiler inlines references to members,
+// so the Enum itself is only consulted for reflection: State.name(v) and
+// State.values().
+type Enum struct {
+	Name    string
+	Members []string // in declaration order
+	Values  []int64  // Values[i] is the value of Members[i]
+	index   map[string]int
+	methods map[string]*Builtin
+}
+
+// NewEnum creates the enum name with the given members and their values.
+func NewEnum(name string, members []string, values []int64) *Enum {
+	e := &Enum{Name: name, Members: members, Values: values, index: make(map[string]int, len(members))}
+	for i, member := range members {
+		e.index[member] = i
+	}
+
+	e.methods = map[string]*Builtin{
+		"name": {Name: name + ".name", Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) != 1 {
+				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+			}
+			value, ok := args[0].(*Integer)
+			if !ok {
+				return &Critical{Message: fmt.Sprintf("argument to `%s.name` must be INTEGER, got %s", name, args[0].Type())}
+			}
+			member, ok := e.NameOf(value.Value)
+			if !ok {
+				return NullObj
+			}
+			return &String{Value: member}
+		}},
+		"values": {Name: name + ".values", Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) != 0 {
+				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=0", len(args))}
+			}
+			if err := allocArray(ctx, int64(len(e.Values)), int64(len(e.Values))); err != nil {
+				return &Critical{Message: err.Error()}
+			}
+			elements := make([]Object, len(e.Values))
+			for i, v := range e.Values {
+				elements[i] = &Integer{Value: v}
+			}
+			return &Array{Elements: elements}
+		}},
+	}
+	return e
+}
+
+// Value returns the value of the named member.
+func (e *Enum) Value(member string) (int64, bool) {
+	i, ok := e.index[member]
+	if !ok {
+		return 0, false
+	}
+	return e.Values[i], true
+}
+
+// NameOf returns the first member with the given value.
+func (e *Enum) NameOf(value int64) (string, bool) {
+	for i, v := range e.Values {
+		if v == value {
+			return e.Members[i], true
+		}
+	}
+	return "", false
+}
+
+func (e *Enum) Inspect() string {
+	return fmt.Sprintf("enum %s { %s }", e.Name, strings.Join(e.Members, ", "))
+}
+func (e *Enum) Type() ObjectType { return ENUM_OBJ }
+
+func (e *Enum) AsFloat() (float64, bool) { return 0, false }
+func (e *Enum) AsInt() (int64, bool)     { return 0, false }
+func (e *Enum) AsString() (string, bool) { return "", false }
+func (e *Enum) AsBool() (bool, bool)     { return false, false }
+
+// GetAttr returns a member's value or one of the reflection methods.
+func (e *Enum) GetAttr(name string) (Object, bool) {
+	if value, ok := e.Value(name); ok {
+		return &Integer{Value: value}, true
+	}
+	if method, ok := e.methods[name]; ok {
+		return method, true
+	}
+	return nil, false
+}
+
+func (e *Enum) SetAttr(name string, value Object) error {
+	return fmt.Errorf("cannot assign to %s.%s: enum members are constant", e.Name, name)
+}
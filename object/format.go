@@ -0,0 +1,237 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// annotationBound covers one of fmt's error annotations, such as
+// %!d(float64=...) for a mismatched verb or %!(BADWIDTH), not counting the
+// value inside.
+const annotationBound = int64(len("%!(float64=)") + utf8.UTFMax)
+
+// maxFormatNumber is the largest width or precision fmt accepts from a '*'.
+// It stops reading a number in the format once it passes this, and gives up
+// on the rest of the format.
+const maxFormatNumber = 1_000_000
+
+// formatBound returns an upper bound on the length of
+// fmt.Sprintf(format, args...), so that format can be charged against the
+// string limits before the result is built. It does not predict fmt's
+// output: each verb is allowed its width, plus the longest form its argument
+// can take for that verb, plus room for an error annotation when the argument
+// is missing or does not suit the verb. From the first explicit index such as
+// %[2]d on, every verb is allowed the largest of the arguments.
+func formatBound(format string, args []any) int64 {
+	var bound int64
+	reordered := false
+	argNum := 0
+
+	// nextArg returns the argument the next verb or '*' consumes
+	nextArg := func() (any, bool) {
+		if argNum >= len(args) {
+			return nil, false
+		}
+		argNum++
+		return args[argNum-1], true
+	}
+
+	for i := 0; i < len(format); {
+		if format[i] != '%' {
+			bound++
+			i++
+			continue
+		}
+		start := i
+		i++
+
+		flags := i
+		for i < len(format) && strings.IndexByte("#0+- ", format[i]) >= 0 {
+			i++
+		}
+		sharp := strings.IndexByte(format[flags:i], '#') >= 0
+
+		// [index][width or *][.[index][precision or *]][index]verb, where
+		// an index right before the verb is not read twice
+		var afterIndex bool
+		i, afterIndex = skipFormatIndex(format, i, &reordered)
+		var wid int64
+		if i < len(format) && format[i] == '*' {
+			i++
+			wid = starBound(args, reordered, nextArg)
+			bound += annotationBound
+			afterIndex = false
+		} else {
+			wid, i = parseFormatNumber(format, i)
+		}
+		if wid < 0 {
+			bound += tooLargeBound(format, start)
+			break
+		}
+		prec := int64(-1)
+		if i+1 < len(format) && format[i] == '.' {
+			i, afterIndex = skipFormatIndex(format, i+1, &reordered)
+			if i < len(format) && format[i] == '*' {
+				i++
+				prec = starBound(args, reordered, nextArg)
+				bound += annotationBound
+				afterIndex = false
+			} else {
+				prec, i = parseFormatNumber(format, i)
+			}
+			if prec < 0 {
+				bound += tooLargeBound(format, start)
+				break
+			}
+		}
+		if !afterIndex {
+			i, _ = skipFormatIndex(format, i, &reordered)
+		}
+
+		// The spec is counted in case fmt echoes a malformed one
+		bound += int64(i - start - 1)
+		if i >= len(format) {
+			bound += annotationBound
+			break
+		}
+
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size
+		if verb == '%' {
+			bound++
+			continue
+		}
+
+		bound += wid
+		if reordered {
+			bound += widestBound(verb, sharp, prec, args) + annotationBound
+		} else if arg, ok := nextArg(); ok {
+			bound += verbBound(verb, sharp, prec, arg)
+		} else {
+			bound += annotationBound
+		}
+	}
+
+	// Unused arguments are listed in %!(EXTRA type=value, ...)
+	if !reordered && argNum < len(args) {
+		bound += int64(len("%!(EXTRA )"))
+		for _, arg := range args[argNum:] {
+			bound += annotationBound + verbBound('v', false, -1, arg)
+		}
+	}
+
+	return bound
+}
+
+// skipFormatIndex skips an argument index such as [2] at format[i:] and
+// reports whether there was one. An unclosed bracket skips only itself.
+func skipFormatIndex(format string, i int, reordered *bool) (int, bool) {
+	if i >= len(format) || format[i] != '[' {
+		return i, false
+	}
+	*reordered = true
+	if end := strings.IndexByte(format[i:], ']'); end >= 0 {
+		return i + end + 1, true
+	}
+	return i + 1, true
+}
+
+// parseFormatNumber reads the decimal width or precision at format[i:]. It
+// returns -1 for a number too large for fmt.
+func parseFormatNumber(format string, i int) (int64, int) {
+	var n int64
+	for ; i < len(format) && '0' <= format[i] && format[i] <= '9'; i++ {
+		if n > maxFormatNumber {
+			return -1, i
+		}
+		n = n*10 + int64(format[i]-'0')
+	}
+	return n, i
+}
+
+// tooLargeBound covers the rest of the format from start when it holds a
+// number too large for fmt, which then gives up on the format.
+func tooLargeBound(format string, start int) int64 {
+	return int64(len(format)-start) + annotationBound
+}
+
+// starBound returns the largest width or precision a '*' can take from args.
+func starBound(args []any, reordered bool, nextArg func() (any, bool)) int64 {
+	candidates := args
+	if !reordered {
+		arg, ok := nextArg()
+		if !ok {
+			return 0
+		}
+		candidates = []any{arg}
+	}
+	var n int64
+	for _, arg := range candidates {
+		if v, ok := arg.(int64); ok {
+			if v < 0 {
+				v = -v
+			}
+			n = max(n, min(v, maxFormatNumber))
+		}
+	}
+	return n
+}
+
+// widestBound returns the largest verbBound of any of args.
+func widestBound(verb rune, sharp bool, prec int64, args []any) int64 {
+	var widest int64
+	for _, arg := range args {
+		widest = max(widest, verbBound(verb, sharp, prec, arg))
+	}
+	return widest
+}
+
+// verbBound returns an upper bound on the length of one argument formatted
+// by verb, not counting padding to a width. prec is -1 when absent.
+func verbBound(verb rune, sharp bool, prec int64, arg any) int64 {
+	switch arg := arg.(type) {
+	case int64:
+		switch verb {
+		case 'v', 'd':
+			// One more for a sign from the + or space flag
+			return max(int64(len(strconv.FormatInt(arg, 10))), prec) + 1
+		case 'b', 'o', 'O', 'x', 'X', 'c', 'q', 'U':
+			// %#b of the smallest int64 is the longest form
+			return 68 + max(prec, 0)
+		}
+	case float64:
+		if prec < 0 {
+			prec = 6
+		}
+		switch verb {
+		case 'f', 'F':
+			// Up to 309 digits before the point
+			return 310 + prec
+		case 'v', 'g', 'G', 'e', 'E', 'x', 'X', 'b':
+			return 25 + prec
+		}
+	case string:
+		n := int64(len(arg))
+		switch {
+		case verb == 'q' || verb == 'v' && sharp:
+			// An escape such as \x00 is four bytes for one
+			return 4*n + 2
+		case verb == 's' || verb == 'v':
+			return n
+		case verb == 'x' || verb == 'X':
+			// "% #x" writes 0x61 and a space for each byte
+			return 5 * n
+		}
+	case bool:
+		if verb == 't' || verb == 'v' {
+			return int64(len("false"))
+		}
+	}
+
+	// A verb that does not suit the argument prints %!verb(type=value)
+	if verb == 'v' {
+		return annotationBound
+	}
+	return annotationBound + verbBound('v', sharp, prec, arg)
+}
@@ -0,0 +1,69 @@
+package object
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFormatBound(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []any
+	}{
+		{"", nil},
+		{"plain text", nil},
+		{"%v", []any{int64(42)}},
+		{"%s héllo %s", []any{"wörld", "x"}},
+		{"%d %5d %-5d %05d %+d % d", []any{int64(7), int64(7), int64(7), int64(-7), int64(7), int64(7)}},
+		{"%x %X %o %O %b %#x %08b %#b", []any{int64(255), int64(255), int64(8), int64(8), int64(5), int64(255), int64(5), int64(-1) << 63}},
+		{"%.3d %8.3d %.30d", []any{int64(5), int64(-5), int64(5)}},
+		{"%f %.2f %8.3f %08.2f %+.1e %g %G %x %b", []any{3.14159, 3.14159, -3.14159, -2.5, 12345.678, 1e21, 1e-7, -1e-300, 1e300}},
+		{"%f %.40f %#g %.1000g %#v", []any{-1e308, 1.0 / 3, 2.0, 1e308, 2.0}},
+		{"%5s|%-5s|%.2s|%05s|%10s", []any{"ab", "ab", "abcdef", "ab", "日本語"}},
+		{"%q %+q %#q %#v % x % #X", []any{"a\"b\x00", "日本 ", "raw", "\xff", "hi", "hi"}},
+		{"%t %6t", []any{true, false}},
+		{"%c %U %#U %q %q", []any{int64(0x65e5), int64(-1), int64(0x10ffff), int64(0x10ffff), int64(-4)}},
+		{"100%% %d%5%", []any{int64(5)}},
+		{"%d %s %t %f", []any{"str", int64(5), 2.5, true}},
+		{"%#d %+s", []any{"héllo", 1.5}},
+		{"%z %é", []any{int64(1), int64(2)}},
+		{"%d %d", []any{int64(1)}},
+		{"%d", []any{int64(1), "extra", 2.5, true}},
+		{"%", nil},
+		{"%5", []any{int64(1)}},
+		{"%[2]d %[1]d", []any{int64(1), int64(2)}},
+		{"%[1]s%[1]s%[1]s", []any{"ab"}},
+		{"%[3]d %d %[0]d %[x]d %[1", []any{int64(1), int64(2)}},
+		{"%[2]*[1]d|%-*d|%*d", []any{int64(12), int64(6), int64(4), int64(5), int64(-6), int64(7)}},
+		{"%.*f|%*.*f|%.*d", []any{int64(2), 3.14159, int64(10), int64(3), 2.71828, int64(-1), int64(5)}},
+		{"%*d %.*d %*d", []any{"w", int64(5), 2.5, int64(5), int64(2000000), int64(5)}},
+		{"%3[1]d %.2[1]d %5.3.2d", []any{int64(5)}},
+		{"%99999999999d %1000d", []any{int64(5), int64(5)}},
+		{"%v \xff %\xff", []any{int64(1), int64(2)}},
+	}
+
+	for _, tt := range tests {
+		want := len(fmt.Sprintf(tt.format, tt.args...))
+		if got := formatBound(tt.format, tt.args); got < int64(want) {
+			t.Errorf("formatBound(%q, %v) = %d, want at least %d", tt.format, tt.args, got, want)
+		}
+	}
+}
+
+func TestFormatBoundPlainVerbs(t *testing.T) {
+	tests := []struct {
+		format   string
+		args     []any
+		expected int64
+	}{
+		{"hp: %v/%v", []any{"10", "20"}, 9},
+		{"%s and %s", []any{"héllo", "x"}, 12},
+		{"%v %v %v", []any{int64(4), int64(-4), "xy"}, 9},
+	}
+
+	for _, tt := range tests {
+		if got := formatBound(tt.format, tt.args); got != tt.expected {
+			t.Errorf("formatBound(%q, %v) = %d, want %d", tt.format, tt.args, got, tt.expected)
+		}
+	}
+}
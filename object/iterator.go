@@ -0,0 +1,150 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+const ITERATOR_OBJ = "ITERATOR"
+
+// Iterator walks an iterable value on behalf of a range loop.
+// Arrays, tuples and strings are walked in place without copying. As in Go,
+// the length of an array is taken once, so elements appended by the loop body
+// are not visited; hashes likewise snapshot their keys once, which also keeps
+// the iteration order deterministic. Coroutines are advanced by the VM, which
+// resumes them for each element.
+type Iterator struct {
+	source Object
+	keys   []HashKey // hash keys in iteration order (hashes only)
+	length int       // number of elements when the loop started (arrays and tuples only)
+	pos    int       // next element index, or byte offset for strings
+}
+
+// NewIterator creates an iterator over obj. It returns false if obj cannot be ranged over.
+func NewIterator(obj Object) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return &Iterator{source: obj, length: len(obj.Elements)}, true
+	case *Tuple:
+		return &Iterator{source: obj, length: len(obj.Elements)}, true
+	case *String, *Coroutine:
+		return &Iterator{source: obj}, true
+	case *Hash:
+		return &Iterator{source: obj, keys: obj.SortedKeys()}, true
+	default:
+		return nil, false
+	}
+}
+
+// Next returns the next key/value pair, or false once the iterator is exhausted.
+//
+//	Array, Tuple: index, element
+//	Hash:         key, value
+//	String:       byte offset, rune (as a one-character string)
+//
+// A coroutine iterator always reports itself exhausted here; see Coroutine.
+func (it *Iterator) Next() (Object, Object, bool) {
+	switch src := it.source.(type) {
+	case *Array:
+		// Host code may have shrunk the array since the loop started
+		if it.pos >= it.length || it.pos >= len(src.Elements) {
+			return nil, nil, false
+		}
+		i := it.pos
+		it.pos++
+		return &Integer{Value: int64(i)}, src.Elements[i], true
+	case *Tuple:
+		if it.pos >= it.length {
+			return nil, nil, false
+		}
+		i := it.pos
+		it.pos++
+		return &Integer{Value: int64(i)}, src.Elements[i], true
+	case *String:
+		if it.pos >= len(src.Value) {
+			return nil, nil, false
+		}
+		offset := it.pos
+		r, size := utf8.DecodeRuneInString(src.Value[offset:])
+		it.pos += size
+		return &Integer{Value: int64(offset)}, &String{Value: string(r)}, true
+	case *Hash:
+		for it.pos < len(it.keys) {
+			pair, ok := src.Pairs[it.keys[it.pos]]
+			it.pos++
+			if ok { // skip keys removed during iteration
+				return pair.Key, pair.Value, true
+			}
+		}
+		return nil, nil, false
+	default:
+		return nil, nil, false
+	}
+}
+
+// Coroutine returns the coroutine being ranged over, if any. Producing its
+// elements requires running script code, so the VM resumes it itself and
+// numbers the yielded values with Advance.
+func (it *Iterator) Coroutine() (*Coroutine, bool) {
+	co, ok := it.source.(*Coroutine)
+	return co, ok
+}
+
+// Advance returns the index of the next element and moves past it.
+func (it *Iterator) Advance() int64 {
+	i := it.pos
+	it.pos++
+	return int64(i)
+}
+
+// State returns the value being walked, the snapshot of hash keys, the
+// length of an array or tuple and the position reached, so that the iterator
+// can be saved and restored with SetState.
+func (it *Iterator) State() (Object, []HashKey, int, int) {
+	return it.source, it.keys, it.length, it.pos
+}
+
+// SetState makes the iterator continue from a state returned by State.
+func (it *Iterator) SetState(source Object, keys []HashKey, length, pos int) {
+	it.source, it.keys, it.length, it.pos = source, keys, length, pos
+}
+
+func (it *Iterator) Inspect() string  { return fmt.Sprintf("Iterator[%s]", it.source.Type()) }
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+
+func (it *Iterator) AsFloat() (float64, bool) { return 0, false }
+func (it *Iterator) AsInt() (int64, bool)     { return 0, false }
+func (it *Iterator) AsString() (string, bool) { return "", false }
+func (it *Iterator) AsBool() (bool, bool)     { return false, false }
+
+// SortedKeys returns the hash keys in a deterministic order: keys of the same
+// type are ordered by value, keys of different types are grouped by type name.
+func (h *Hash) SortedKeys() []HashKey {
+	keys := make([]HashKey, 0, len(h.Pairs))
+	for k := range h.Pairs {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a := h.Pairs[keys[i]].Key
+		b := h.Pairs[keys[j]].Key
+		if a.Type() != b.Type() {
+			return a.Type() < b.Type()
+		}
+		switch a := a.(type) {
+		case *Integer:
+			return a.Value < b.(*Integer).Value
+		case *Float:
+			return a.Value < b.(*Float).Value
+		case *String:
+			return a.Value < b.(*String).Value
+		case *Boolean:
+			return !a.Value && b.(*Boolean).Value
+		default:
+			return keys[i].Value < keys[j].Value
+		}
+	})
+
+	return keys
+}
@@ -0,0 +1,44 @@
+package object
+
+// Limiter is implemented by a BuiltinContext that bounds how much memory
+// scripts may allocate. Builtins that create or grow arrays, hashes and
+// strings report the new size first, and fail with the returned error.
+type Limiter interface {
+	// AllocArray reports an array growing to length elements, added of which
+	// are new.
+	AllocArray(length, added int64) error
+	// AllocHash reports a hash growing to size pairs, added of which are new.
+	AllocHash(size, added int64) error
+	// AllocString reports a new string of length bytes.
+	AllocString(length int64) error
+}
+
+func allocArray(ctx BuiltinContext, length, added int64) error {
+	if limiter, ok := ctx.(Limiter); ok {
+		return limiter.AllocArray(length, added)
+	}
+	return nil
+}
+
+func allocHash(ctx BuiltinContext, size, added int64) error {
+	if limiter, ok := ctx.(Limiter); ok {
+		return limiter.AllocHash(size, added)
+	}
+	return nil
+}
+
+func allocString(ctx BuiltinContext, length int64) error {
+	if limiter, ok := ctx.(Limiter); ok {
+		return limiter.AllocString(length)
+	}
+	return nil
+}
+
+// limitedString returns value as a String, or a Critical if the context does
+// not allow a string that long.
+func limitedString(ctx BuiltinContext, value string) Object {
+	if err := allocString(ctx, int64(len(value))); err != nil {
+		return &Critical{Message: err.Error()}
+	}
+	return &String{Value: value}
+}
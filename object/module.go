@@ -0,0 +1,28 @@
+package object
+
+import "fmt"
+
+// Module is the namespace bound by an import statement. It holds the values
+// of the module's exported globals as they were when the module finished
+// initializing. Members are read through dot notation and cannot be assigned.
+type Module struct {
+	Name    string
+	Members map[string]Object
+}
+
+func (m *Module) Inspect() string  { return fmt.Sprintf("<module %s>", m.Name) }
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+
+func (m *Module) GetAttr(name string) (Object, bool) {
+	val, ok := m.Members[name]
+	return val, ok
+}
+
+func (m *Module) SetAttr(name string, value Object) error {
+	return fmt.Errorf("cannot assign to %s.%s: module members are read-only", m.Name, name)
+}
+
+func (m *Module) AsFloat() (float64, bool) { return 0, false }
+func (m *Module) AsInt() (int64, bool)     { return 0, false }
+func (m *Module) AsString() (string, bool) { return "", false }
+func (m *Module) AsBool() (bool, bool)     { return false, false }
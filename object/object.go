@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 
@@ -42,6 +43,13 @@ const (
 	USER_OBJ              = "USER_OBJ"
 	TUPLE_OBJ             = "TUPLE"
 	CRITICAL_OBJ          = "CRITICAL"
+	CELL_OBJ              = "CELL"
+	MODULE_OBJ            = "MODULE"
+	JUMP_TABLE_OBJ        = "JUMP_TABLE"
+	COROUTINE_OBJ         = "COROUTINE"
+	STRUCT_TYPE_OBJ       = "STRUCT_TYPE"
+	STRUCT_OBJ            = "STRUCT"
+	ENUM_OBJ              = "ENUM"
 )
 
 type Object interface {
@@ -198,7 +206,8 @@ func (s *String) AsBool() (bool, bool)     { return s.Value == "true", true }
 type BuiltinFunction func(ctx BuiltinContext, args ...Object) Object
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn   BuiltinFunction
+	Name string // Optional, used in error messages
 }
 
 func (b *Builtin) Inspect() string  { return "builtin function" }
@@ -272,9 +281,30 @@ func (h *Hash) AsBool() (bool, bool)     { return false, false }
 type CompiledFunction struct {
 	Instructions  []byte
 	NumLocals     int
-	NumParameters int
-	SourceMap     map[int]int
-	Name          string
+	NumParameters int // Including optional and variadic parameters
+	NumDefaults   int // Optional parameters, which precede the variadic one
+	Variadic      bool
+	Generator     bool // Contains yield: a call creates a Coroutine instead of running the body
+	// Entries[i] is the instruction offset at which a call that passed i of
+	// the optional parameters starts: the code from there on assigns the
+	// default values of the remaining ones and falls through into the body.
+	Entries   []int
+	SourceMap map[int]int
+	Name      string
+	File      string // Source file or module path, empty for the main script
+	// LocalNames[i] is the name of local slot i, for debuggers. Names of
+	// compiler temporaries start with $.
+	LocalNames []string
+	FreeNames  []string // names of the captured variables, in Closure.Free order
+}
+
+// NumRequired returns the number of arguments a call must pass.
+func (cf *CompiledFunction) NumRequired() int {
+	n := cf.NumParameters - cf.NumDefaults
+	if cf.Variadic {
+		n--
+	}
+	return n
 }
 
 func (cf *CompiledFunction) Inspect() string  { return fmt.Sprintf("CompiledFunction[%p]", cf) }
@@ -299,7 +329,7 @@ func (p *Panic) AsBool() (bool, bool)     { return false, false }
 
 type Closure struct {
 	Fn   *CompiledFunction
-	Free []Object
+	Free []Object // *Cell per captured variable
 }
 
 func (c *Closure) Inspect() string  { return fmt.Sprintf("Closure[%p]", c) }
@@ -310,6 +340,58 @@ func (c *Closure) AsInt() (int64, bool)     { return 0, false }
 func (c *Closure) AsString() (string, bool) { return "", false }
 func (c *Closure) AsBool() (bool, bool)     { return false, false }
 
+// Cell boxes a local variable captured by a closure. The declaring frame and
+// every closure capturing the variable share the same cell, so writes from
+// either side are visible to all of them.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Inspect() string  { return fmt.Sprintf("Cell[%s]", c.Value.Inspect()) }
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+
+func (c *Cell) AsFloat() (float64, bool) { return 0, false }
+func (c *Cell) AsInt() (int64, bool)     { return 0, false }
+func (c *Cell) AsString() (string, bool) { return "", false }
+func (c *Cell) AsBool() (bool, bool)     { return false, false }
+
+// JumpTable maps integer switch case values to instruction offsets. It lives
+// in the constant pool and is used by OpJumpTable.
+type JumpTable struct {
+	Targets map[int64]int
+	Default int
+}
+
+func (jt *JumpTable) Inspect() string  { return fmt.Sprintf("JumpTable[%d cases]", len(jt.Targets)) }
+func (jt *JumpTable) Type() ObjectType { return JUMP_TABLE_OBJ }
+
+func (jt *JumpTable) AsFloat() (float64, bool) { return 0, false }
+func (jt *JumpTable) AsInt() (int64, bool)     { return 0, false }
+func (jt *JumpTable) AsString() (string, bool) { return "", false }
+func (jt *JumpTable) AsBool() (bool, bool)     { return false, false }
+
+// Target returns the offset to jump to for key. Floats with an integral value
+// match the equal integer case, as they would with ==.
+func (jt *JumpTable) Target(key Object) int {
+	var value int64
+	switch key := key.(type) {
+	case *Integer:
+		value = key.Value
+	case *Float:
+		if key.Value != math.Trunc(key.Value) || math.Abs(key.Value) >= math.MaxInt64 {
+			return jt.Default
+		}
+		value = int64(key.Value)
+	default:
+		return jt.Default
+	}
+
+	if target, ok := jt.Targets[value]; ok {
+		return target
+	}
+	return jt.Default
+}
+
 // Helpers
 func NativeBoolToBooleanObject(input bool) *Boolean {
 	if input {
@@ -0,0 +1,288 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringBuiltins is the string library. It is appended to Builtins at init,
+// so these functions are global builtins like len and contains. Positions and
+// lengths are counted in runes, matching s[i] and s[a:b].
+var stringBuiltins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"split",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			values, err := stringArgs("split", args, 2)
+			if err != nil {
+				return err
+			}
+			parts := strings.Split(values[0], values[1])
+			if err := allocArray(ctx, int64(len(parts)), int64(len(parts))); err != nil {
+				return &Critical{Message: err.Error()}
+			}
+			elements := make([]Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &String{Value: part}
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"join",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) != 2 {
+				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=2", len(args))}
+			}
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return &Critical{Message: fmt.Sprintf("first argument to `join` must be ARRAY, got %s", args[0].Type())}
+			}
+			sep, ok := args[1].(*String)
+			if !ok {
+				return &Critical{Message: fmt.Sprintf("second argument to `join` must be STRING, got %s", args[1].Type())}
+			}
+			parts := make([]string, len(arr.Elements))
+			var length int64
+			for i, el := range arr.Elements {
+				s, ok := el.(*String)
+				if !ok {
+					return &Critical{Message: fmt.Sprintf("`join` element %d must be STRING, got %s", i, el.Type())}
+				}
+				parts[i] = s.Value
+				length += int64(len(s.Value))
+			}
+			if len(parts) > 1 {
+				length = addLength(length, scaledLength(int64(len(sep.Value)), int64(len(parts)-1)))
+			}
+			if err := allocString(ctx, length); err != nil {
+				return &Critical{Message: err.Error()}
+			}
+			return &String{Value: strings.Join(parts, sep.Value)}
+		}},
+	},
+	{
+		"trim",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) == 1 {
+				values, err := stringArgs("trim", args, 1)
+				if err != nil {
+					return err
+				}
+				return limitedString(ctx, strings.TrimSpace(values[0]))
+			}
+			values, err := stringArgs("trim", args, 2)
+			if err != nil {
+				return err
+			}
+			return limitedString(ctx, strings.Trim(values[0], values[1]))
+		}},
+	},
+	{
+		"upper",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			values, err := stringArgs("upper", args, 1)
+			if err != nil {
+				return err
+			}
+			return limitedString(ctx, strings.ToUpper(values[0]))
+		}},
+	},
+	{
+		"lower",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			values, err := stringArgs("lower", args, 1)
+			if err != nil {
+				return err
+			}
+			return limitedString(ctx, strings.ToLower(values[0]))
+		}},
+	},
+	{
+		"replace",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			n := int64(-1)
+			if len(args) == 4 {
+				count, ok := args[3].(*Integer)
+				if !ok {
+					return &Critical{Message: fmt.Sprintf("count argument to `replace` must be INTEGER, got %s", args[3].Type())}
+				}
+				n = count.Value
+				args = args[:3]
+			}
+			values, err := stringArgs("replace", args, 3)
+			if err != nil {
+				return err
+			}
+			s, from, to := values[0], values[1], values[2]
+
+			// As in strings.Replace, an empty pattern matches before every
+			// rune and at the end
+			matches := int64(strings.Count(s, from))
+			if n >= 0 && n < matches {
+				matches = n
+			}
+			length := int64(len(s)) - matches*int64(len(from))
+			length = addLength(length, scaledLength(int64(len(to)), matches))
+			if err := allocString(ctx, length); err != nil {
+				return &Critical{Message: err.Error()}
+			}
+			return &String{Value: strings.Replace(s, from, to, int(n))}
+		}},
+	},
+	{
+		"hasPrefix",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			values, err := stringArgs("hasPrefix", args, 2)
+			if err != nil {
+				return err
+			}
+			return NativeBoolToBooleanObject(strings.HasPrefix(values[0], values[1]))
+		}},
+	},
+	{
+		"hasSuffix",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			values, err := stringArgs("hasSuffix", args, 2)
+			if err != nil {
+				return err
+			}
+			return NativeBoolToBooleanObject(strings.HasSuffix(values[0], values[1]))
+		}},
+	},
+	{
+		"indexOf",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			values, err := stringArgs("indexOf", args, 2)
+			if err != nil {
+				return err
+			}
+			i := strings.Index(values[0], values[1])
+			if i < 0 {
+				return &Integer{Value: -1}
+			}
+			return &Integer{Value: int64(utf8.RuneCountInString(values[0][:i]))}
+		}},
+	},
+	{
+		"repeat",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			if len(args) != 2 {
+				return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=2", len(args))}
+			}
+			s, ok := args[0].(*String)
+			if !ok {
+				return &Critical{Message: fmt.Sprintf("first argument to `repeat` must be STRING, got %s", args[0].Type())}
+			}
+			n, ok := args[1].(*Integer)
+			if !ok {
+				return &Critical{Message: fmt.Sprintf("second argument to `repeat` must be INTEGER, got %s", args[1].Type())}
+			}
+			if n.Value < 0 {
+				return &Critical{Message: fmt.Sprintf("argument to `repeat` count must be positive, got %d", n.Value)}
+			}
+			if err := allocString(ctx, scaledLength(int64(len(s.Value)), n.Value)); err != nil {
+				return &Critical{Message: err.Error()}
+			}
+			return &String{Value: strings.Repeat(s.Value, int(n.Value))}
+		}},
+	},
+	{
+		"runes",
+		&Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+			values, err := stringArgs("runes", args, 1)
+			if err != nil {
+				return err
+			}
+			count := int64(utf8.RuneCountInString(values[0]))
+			if err := allocArray(ctx, count, count); err != nil {
+				return &Critical{Message: err.Error()}
+			}
+			elements := make([]Object, 0, count)
+			for _, r := range values[0] {
+				elements = append(elements, &String{Value: string(r)})
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{"format", formatBuiltin},
+	{"sprintf", formatBuiltin},
+}
+
+// formatBuiltin implements format(fmt, args...); sprintf is another name for
+// it.
+var formatBuiltin = &Builtin{Fn: func(ctx BuiltinContext, args ...Object) Object {
+	return formatString(ctx, args)
+}}
+
+// scaledLength returns size*n, or math.MaxInt64 if that overflows, so that an
+// impossible length is still rejected by the limits.
+func scaledLength(size, n int64) int64 {
+	if n > 0 && size > math.MaxInt64/n {
+		return math.MaxInt64
+	}
+	return size * n
+}
+
+// addLength returns a+b for non-negative lengths, saturating at math.MaxInt64.
+func addLength(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// stringArgs checks that args holds exactly n STRINGs and returns their values.
+func stringArgs(name string, args []Object, n int) ([]string, Object) {
+	if len(args) != n {
+		return nil, &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), n)}
+	}
+	values := make([]string, n)
+	for i, arg := range args {
+		s, ok := arg.(*String)
+		if !ok {
+			return nil, &Critical{Message: fmt.Sprintf("arguments to `%s` must be STRINGs, got %s", name, arg.Type())}
+		}
+		values[i] = s.Value
+	}
+	return values, nil
+}
+
+// formatString implements format(fmt, args...) with Go's fmt verbs. Numbers,
+// strings and booleans are passed as their Go values so that verbs like %d,
+// %.2f and %q work; anything else is formatted through Inspect. An upper bound on the length
+// of the result is charged to the context before it is built.
+func formatString(ctx BuiltinContext, args []Object) Object {
+	if len(args) < 1 {
+		return &Critical{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least 1", len(args))}
+	}
+	format, ok := args[0].(*String)
+	if !ok {
+		return &Critical{Message: fmt.Sprintf("first argument to `format` must be STRING, got %s", args[0].Type())}
+	}
+
+	values := make([]any, len(args)-1)
+	for i, arg := range args[1:] {
+		switch arg := arg.(type) {
+		case *Integer:
+			values[i] = arg.Value
+		case *Float:
+			values[i] = arg.Value
+		case *String:
+			values[i] = arg.Value
+		case *Boolean:
+			values[i] = arg.Value
+		default:
+			values[i] = arg.Inspect()
+		}
+	}
+
+	if err := allocString(ctx, formatBound(format.Value, values)); err != nil {
+		return &Critical{Message: err.Error()}
+	}
+	return &String{Value: fmt.Sprintf(format.Value, values...)}
+}
@@ -0,0 +1,124 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// StructType is a record type declared by `type Name = struct { a, b }`.
+// Calling it constructs a Struct; each field has a fixed slot, in declaration
+// order.
+type StructType struct {
+	Name   string
+	Fields []string
+	slots  map[string]int
+}
+
+// NewStructType creates the struct type name with the given field names,
+// which must be distinct.
+func NewStructType(name string, fields []string) *StructType {
+	slots := make(map[string]int, len(fields))
+	for i, field := range fields {
+		slots[field] = i
+	}
+	return &StructType{Name: name, Fields: fields, slots: slots}
+}
+
+// FieldIndex returns the slot of the named field.
+func (t *StructType) FieldIndex(name string) (int, bool) {
+	i, ok := t.slots[name]
+	return i, ok
+}
+
+func (t *StructType) unknownField(name string) error {
+	return fmt.Errorf("struct %s has no field %s", t.Name, name)
+}
+
+// New constructs an instance from one value per field, in declaration order.
+func (t *StructType) New(values []Object) (*Struct, error) {
+	if err := t.CheckArity(len(values)); err != nil {
+		return nil, err
+	}
+
+	fields := make([]Object, len(t.Fields))
+	copy(fields, values)
+	return &Struct{Def: t, Fields: fields}, nil
+}
+
+// CheckArity reports an error unless n values, one per field, are given.
+func (t *StructType) CheckArity(n int) error {
+	if n != len(t.Fields) {
+		return fmt.Errorf("wrong number of values for struct %s: want=%d, got=%d", t.Name, len(t.Fields), n)
+	}
+	return nil
+}
+
+func (t *StructType) Inspect() string {
+	return fmt.Sprintf("struct %s { %s }", t.Name, strings.Join(t.Fields, ", "))
+}
+func (t *StructType) Type() ObjectType { return STRUCT_TYPE_OBJ }
+
+func (t *StructType) AsFloat() (float64, bool) { return 0, false }
+func (t *StructType) AsInt() (int64, bool)     { return 0, false }
+func (t *StructType) AsString() (string, bool) { return "", false }
+func (t *StructType) AsBool() (bool, bool)     { return false, false }
+
+// Struct is an instance of a StructType. Its fields are read and assigned
+// through dot notation; unlike hash keys, a field that is not part of the
+// type is an error rather than a new entry.
+type Struct struct {
+	Def    *StructType
+	Fields []Object // indexed by StructType.FieldIndex
+}
+
+func (s *Struct) Inspect() string {
+	var out bytes.Buffer
+
+	fields := make([]string, len(s.Fields))
+	for i, value := range s.Fields {
+		fields[i] = fmt.Sprintf("%s: %s", s.Def.Fields[i], value.Inspect())
+	}
+
+	out.WriteString(s.Def.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+
+func (s *Struct) AsFloat() (float64, bool) { return 0, false }
+func (s *Struct) AsInt() (int64, bool)     { return 0, false }
+func (s *Struct) AsString() (string, bool) { return "", false }
+func (s *Struct) AsBool() (bool, bool)     { return false, false }
+
+// GetAttr returns the value of the named field.
+func (s *Struct) GetAttr(name string) (Object, bool) {
+	i, ok := s.Def.FieldIndex(name)
+	if !ok {
+		return nil, false
+	}
+	return s.Fields[i], true
+}
+
+// SetAttr assigns the named field.
+func (s *Struct) SetAttr(name string, value Object) error {
+	i, ok := s.Def.FieldIndex(name)
+	if !ok {
+		return s.Def.unknownField(name)
+	}
+	s.Fields[i] = value
+	return nil
+}
+
+// Field returns the value of the named field, or an error naming the type if
+// there is no such field.
+func (s *Struct) Field(name string) (Object, error) {
+	value, ok := s.GetAttr(name)
+	if !ok {
+		return nil, s.Def.unknownField(name)
+	}
+	return value, nil
+}
@@ -6,6 +6,19 @@ type User struct {
 	Value interface{}
 }
 
+// AttrGetter is implemented by host values that expose attributes to scripts
+// through dot notation (obj.name). Methods are exposed by returning a *Builtin,
+// which the script then calls: obj.method(args).
+type AttrGetter interface {
+	GetAttr(name string) (Object, bool)
+}
+
+// AttrSetter is implemented by host values that accept attribute assignment
+// (obj.name = value). A returned error becomes a script runtime error.
+type AttrSetter interface {
+	SetAttr(name string, value Object) error
+}
+
 func (u *User) Inspect() string {
 	if stringer, ok := u.Value.(fmt.Stringer); ok {
 		return stringer.String()
@@ -47,6 +60,22 @@ func (u *User) Equal(other Object) (bool, error) {
 	return false, fmt.Errorf("equality not supported for type: %s", u.Type())
 }
 
+// GetAttr delegates to the wrapped value if it implements AttrGetter.
+func (u *User) GetAttr(name string) (Object, bool) {
+	if getter, ok := u.Value.(AttrGetter); ok {
+		return getter.GetAttr(name)
+	}
+	return nil, false
+}
+
+// SetAttr delegates to the wrapped value if it implements AttrSetter.
+func (u *User) SetAttr(name string, value Object) error {
+	if setter, ok := u.Value.(AttrSetter); ok {
+		return setter.SetAttr(name, value)
+	}
+	return fmt.Errorf("cannot set attribute %s on %s", name, u.Inspect())
+}
+
 // User objects are NOT hashable by default to prevent complex key behavior
 // func (u *User) HashKey() HashKey { ... }
 
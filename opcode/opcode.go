@@ -53,6 +53,34 @@ const (
 	OpIs
 	OpSetIndex
 	OpTuple
+	OpIterInit
+	OpIterNext
+	OpSetFree
+	OpGetCell
+	OpSetCell
+	OpNewCell
+	OpLoadLocalCell
+	OpLoadFreeCell
+	OpTry
+	OpEndTry
+	OpThrow
+	OpGetAttr
+	OpSetAttr
+	OpModule
+	OpDup2
+	OpJumpTable
+	OpCallSpread
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpBitAndNot
+	OpShiftLeft
+	OpShiftRight
+	OpBitNot
+	OpYield
+	OpIsType
+	OpJumpNull
+	OpInspect
 )
 
 type Definition struct {
@@ -98,6 +126,34 @@ var definitions = map[Opcode]*Definition{
 	OpIs:             {"OpIs", []int{1}}, // Type ID
 	OpSetIndex:       {"OpSetIndex", []int{}},
 	OpTuple:          {"OpTuple", []int{2}}, // Number of elements
+	OpIterInit:       {"OpIterInit", []int{}},
+	OpIterNext:       {"OpIterNext", []int{2}}, // Jump target when exhausted
+	OpSetFree:        {"OpSetFree", []int{1}},
+	OpGetCell:        {"OpGetCell", []int{1}},       // Read a captured local through its cell
+	OpSetCell:        {"OpSetCell", []int{1}},       // Assign a captured local through its cell
+	OpNewCell:        {"OpNewCell", []int{1}},       // Declare a captured local in a fresh cell
+	OpLoadLocalCell:  {"OpLoadLocalCell", []int{1}}, // Push a local's cell for OpClosure
+	OpLoadFreeCell:   {"OpLoadFreeCell", []int{1}},  // Push a free variable's cell for OpClosure
+	OpTry:            {"OpTry", []int{2}},           // Handler address
+	OpEndTry:         {"OpEndTry", []int{}},
+	OpThrow:          {"OpThrow", []int{}},
+	OpGetAttr:        {"OpGetAttr", []int{2}},    // Const index of attribute name
+	OpSetAttr:        {"OpSetAttr", []int{2}},    // Const index of attribute name
+	OpModule:         {"OpModule", []int{2}},     // Const index of module name; wraps the exports hash
+	OpDup2:           {"OpDup2", []int{}},        // Duplicate the top two stack elements
+	OpJumpTable:      {"OpJumpTable", []int{2}},  // Const index of an object.JumpTable; pops the switch subject
+	OpCallSpread:     {"OpCallSpread", []int{2}}, // Number of argument arrays to flatten into the call
+	OpBitAnd:         {"OpBitAnd", []int{}},
+	OpBitOr:          {"OpBitOr", []int{}},
+	OpBitXor:         {"OpBitXor", []int{}},
+	OpBitAndNot:      {"OpBitAndNot", []int{}},
+	OpShiftLeft:      {"OpShiftLeft", []int{}},
+	OpShiftRight:     {"OpShiftRight", []int{}}, // Arithmetic shift
+	OpBitNot:         {"OpBitNot", []int{}},
+	OpYield:          {"OpYield", []int{}},     // Suspends the current coroutine, handing it the top of stack
+	OpIsType:         {"OpIsType", []int{}},    // Pops a type value and a value, pushes whether the value is an instance
+	OpJumpNull:       {"OpJumpNull", []int{2}}, // Pops a value, jumps if it is null
+	OpInspect:        {"OpInspect", []int{}},   // Replaces a non-string top of stack with its Inspect() string
 }
 
 const (
@@ -114,6 +170,7 @@ const (
 	VMTypeUser
 	VMTypeTuple
 	VMTypeCritical
+	VMTypeCoroutine
 )
 
 func Lookup(op byte) (*Definition, error) {
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestCompoundAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x += 1", "x += 1"},
+		{"x -= y * 2", "x -= (y * 2)"},
+		{"arr[i] *= 3", "(arr[i]) *= 3"},
+		{`m["hp"] -= dmg`, "(m[hp]) -= dmg"},
+		{"p.hp /= 2", "(p.hp) /= 2"},
+		{"x %= a + b", "x %= (a + b)"},
+		{"x++", "x++"},
+		{"arr[i]--", "(arr[i])--"},
+		{"p.stats.hp++", "((p.stats).hp)++"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestIncDecInForPost(t *testing.T) {
+	inputs := []string{
+		`for i := 0; i < 10; i++ { }`,
+		`for var i = 10; i > 0; i -= 2 { }`,
+		`for i = 0; i < 10; i += 1 { }`,
+	}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		post, ok := stmt.Post.(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("stmt.Post is not ast.ExpressionStatement. got=%T", stmt.Post)
+		}
+
+		switch post.Expression.(type) {
+		case *ast.IncDecExpression, *ast.CompoundAssignExpression:
+		default:
+			t.Errorf("post is not a compound assignment. got=%T", post.Expression)
+		}
+	}
+}
+
+func TestCompoundAssignErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 += 2", "expected identifier, index or member expression on left side of +=, got 1"},
+		{"f() += 1", "expected identifier, index or member expression on left side of +=, got f()"},
+		{"(a + b)++", "expected identifier, index or member expression on left side of ++, got (a + b)"},
+		{"(a = 1)++", "expected identifier, index or member expression on left side of ++, got a = 1"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+		if !strings.Contains(errors[0], tt.expected) {
+			t.Errorf("unexpected error for %q: %q", tt.input, errors[0])
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestConstStatement(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      any
+	}{
+		{"const TICK = 16;", "TICK", 16},
+		{`const VERSION = "2.0"`, "VERSION", "2.0"},
+		{"const debug = true", "debug", true},
+		{"const RATE = TICK * 2", "RATE", nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ConstStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.ConstStatement. got=%T", program.Statements[0])
+		}
+
+		if !testIdentifier(t, stmt.Name, tt.expectedIdentifier) {
+			return
+		}
+
+		if tt.expectedValue == nil {
+			if _, ok := stmt.Value.(*ast.InfixExpression); !ok {
+				t.Errorf("stmt.Value is not ast.InfixExpression. got=%T", stmt.Value)
+			}
+			continue
+		}
+		if str, ok := tt.expectedValue.(string); ok {
+			lit, ok := stmt.Value.(*ast.StringLiteral)
+			if !ok || lit.Value != str {
+				t.Errorf("stmt.Value is not %q. got=%s", str, stmt.Value)
+			}
+			continue
+		}
+		if !testLiteralExpression(t, stmt.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestConstStatementErrors(t *testing.T) {
+	tests := []string{
+		"const = 5",
+		"const X 5",
+		"const X, Y = 1, 2",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
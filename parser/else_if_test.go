@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestElseIfChain(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	outer, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if !testInfixExpression(t, outer.Condition, "x", "<", "y") {
+		return
+	}
+
+	if outer.Alternative == nil || len(outer.Alternative.Statements) != 1 {
+		t.Fatalf("outer.Alternative should hold exactly the nested if. got=%+v", outer.Alternative)
+	}
+
+	nestedStmt, ok := outer.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("alternative statement is not ast.ExpressionStatement. got=%T", outer.Alternative.Statements[0])
+	}
+
+	nested, ok := nestedStmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression. got=%T", nestedStmt.Expression)
+	}
+
+	if !testInfixExpression(t, nested.Condition, "x", ">", "y") {
+		return
+	}
+
+	if nested.Alternative == nil {
+		t.Fatal("nested.Alternative is nil")
+	}
+	if !testIdentifier(t, nested.Alternative.Statements[0].(*ast.ExpressionStatement).Expression, "z") {
+		return
+	}
+}
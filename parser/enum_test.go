@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestEnumStatement(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedName    string
+		expectedMembers []string
+		explicit        []bool
+	}{
+		{"enum State { Idle, Walk, Attack }", "State", []string{"Idle", "Walk", "Attack"}, []bool{false, false, false}},
+		{"enum State { Idle, Walk, }", "State", []string{"Idle", "Walk"}, []bool{false, false}},
+		{"enum Empty {}", "Empty", nil, nil},
+		{"enum Flags { A = 1, B = 1 << 1, C }", "Flags", []string{"A", "B", "C"}, []bool{true, true, false}},
+		{"enum State {\n\tIdle\n\tWalk = 5,\n\tAttack\n};", "State", []string{"Idle", "Walk", "Attack"}, []bool{false, true, false}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement, got=%d", tt.input, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.EnumStatement)
+		if !ok {
+			t.Fatalf("%q: statement is not *ast.EnumStatement. got=%T", tt.input, program.Statements[0])
+		}
+
+		testIdentifier(t, stmt.Name, tt.expectedName)
+		if len(stmt.Members) != len(tt.expectedMembers) || len(stmt.Values) != len(tt.expectedMembers) {
+			t.Fatalf("%q: wrong number of members. want=%d, got=%d (values=%d)",
+				tt.input, len(tt.expectedMembers), len(stmt.Members), len(stmt.Values))
+		}
+		for i, member := range tt.expectedMembers {
+			testIdentifier(t, stmt.Members[i], member)
+			if (stmt.Values[i] != nil) != tt.explicit[i] {
+				t.Errorf("%q: member %s has explicit value=%t, want %t", tt.input, member, stmt.Values[i] != nil, tt.explicit[i])
+			}
+		}
+	}
+}
+
+func TestEnumStatementString(t *testing.T) {
+	p := New(lexer.New("enum State { Idle, Walk = 5, Attack }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if got := program.String(); got != "enum State { Idle, Walk = 5, Attack }" {
+		t.Errorf("wrong String(). got=%q", got)
+	}
+}
+
+func TestEnumStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum State { Idle, Idle }", "duplicate member Idle in enum State"},
+		{"enum State { Idle Walk }", "expected next token to be ,"},
+		{"enum State { 1 }", "expected enum member name, got INT"},
+		{"enum State { Idle", "got EOF instead"},
+		{"enum { Idle }", "expected next token to be IDENT"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if !strings.Contains(errors[0], tt.expected) {
+			t.Errorf("%q: expected error containing %q, got %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPath  string
+		expectedAlias string
+	}{
+		{`import "utils"`, "utils", ""},
+		{`import "lib/utils" as u`, "lib/utils", "u"},
+		{`import "math.ice";`, "math.ice", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.ImportStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.Path.Value != tt.expectedPath {
+			t.Errorf("stmt.Path.Value not %q. got=%q", tt.expectedPath, stmt.Path.Value)
+		}
+
+		if tt.expectedAlias == "" {
+			if stmt.Alias != nil {
+				t.Errorf("stmt.Alias should be nil. got=%q", stmt.Alias.Value)
+			}
+			continue
+		}
+		if !testIdentifier(t, stmt.Alias, tt.expectedAlias) {
+			return
+		}
+	}
+}
+
+func TestImportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		errContains string
+	}{
+		{`import utils`, "expected next token to be STRING"},
+		{`import "utils" as`, "expected next token to be IDENT"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+		if !strings.Contains(strings.Join(errors, "\n"), tt.errContains) {
+			t.Errorf("errors for %q should contain %q, got %q", tt.input, tt.errContains, errors)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hp: ${e["hp"]}/${max}"`, `format(hp: %v/%v, (e[hp]), max)`},
+		{`"${x}"`, `format(%v, x)`},
+		{`"100% of ${a + b}!"`, `format(100%% of %v!, (a + b))`},
+		{`"outer ${"inner ${x}"}"`, `format(outer %v, format(inner %v, x))`},
+		{`"${f(1, 2)} and ${ {"k": 1}["k"] }"`, `format(%v and %v, f(1, 2), ({k:1}[k]))`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("%s: expression is not ast.InterpolatedString. got=%T", tt.input, stmt.Expression)
+		}
+
+		if str.String() != tt.expected {
+			t.Errorf("%s: wrong lowering. want=%q, got=%q", tt.input, tt.expected, str.String())
+		}
+	}
+}
+
+func TestInterpolationLineTracking(t *testing.T) {
+	input := "var x = \"a ${\n\n  b + c\n}\""
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	str := stmt.Value.(*ast.InterpolatedString)
+	infix := str.Values[0].(*ast.InfixExpression)
+	if line := infix.Left.(*ast.Identifier).Token.Line; line != 3 {
+		t.Errorf("embedded expression on wrong line. want=3, got=%d", line)
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	tests := []string{
+		`"${}"`,
+		`"a ${b c}"`,
+		`"a ${b`,
+		`"${x} ${}"`,
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
+
+func TestInvalidEscapeError(t *testing.T) {
+	p := New(lexer.New("var a = 1\nvar s = \"x=${a} \\u12\""))
+	p.ParseProgram()
+
+	errors := p.StructuredErrors()
+	if len(errors) == 0 {
+		t.Fatalf("expected a parser error")
+	}
+	if errors[0].Message != `invalid escape sequence \u12 in string` || errors[0].Line != 2 {
+		t.Errorf("wrong error. got=%q on line %d", errors[0].Message, errors[0].Line)
+	}
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestLabeledLoopParsing(t *testing.T) {
+	input := `
+	outer: for i := 0; i < 3; i = i + 1 {
+		for {
+			break outer
+			continue
+		}
+	}
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	loop, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if loop.Label != "outer" {
+		t.Errorf("loop.Label not %q. got=%q", "outer", loop.Label)
+	}
+
+	inner, ok := loop.Body.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("loop body is not ast.ForStatement. got=%T", loop.Body.Statements[0])
+	}
+
+	if len(inner.Body.Statements) != 2 {
+		t.Fatalf("inner body does not contain 2 statements. got=%d", len(inner.Body.Statements))
+	}
+
+	brk, ok := inner.Body.Statements[0].(*ast.BreakStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.BreakStatement. got=%T", inner.Body.Statements[0])
+	}
+	if brk.Label == nil || brk.Label.Value != "outer" {
+		t.Errorf("break label not %q. got=%v", "outer", brk.Label)
+	}
+
+	cont, ok := inner.Body.Statements[1].(*ast.ContinueStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ContinueStatement. got=%T", inner.Body.Statements[1])
+	}
+	if cont.Label != nil {
+		t.Errorf("continue label should be nil. got=%s", cont.Label)
+	}
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestMemberExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.b", "(a.b)"},
+		{"a.b.c", "((a.b).c)"},
+		{"a.b(1)", "(a.b)(1)"},
+		{"a.b[0]", "((a.b)[0])"},
+		{"a[0].b", "((a[0]).b)"},
+		{"-a.b", "(-(a.b))"},
+		{"a.b + c.d * 2", "((a.b) + ((c.d) * 2))"},
+		{"e.type", "(e.type)"},
+		{"opts.default.case", "((opts.default).case)"},
+		{"m.import(x.yield)", "(m.import)((x.yield))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestMemberAssignExpression(t *testing.T) {
+	l := lexer.New(`player.stats.hp = 10`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assign, ok := stmt.Expression.(*ast.MemberAssignExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.MemberAssignExpression. got=%T", stmt.Expression)
+	}
+
+	if assign.Left.Name.Value != "hp" {
+		t.Errorf("assign.Left.Name is not 'hp'. got=%s", assign.Left.Name.Value)
+	}
+
+	inner, ok := assign.Left.Left.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("assign.Left.Left is not ast.MemberExpression. got=%T", assign.Left.Left)
+	}
+	if !testIdentifier(t, inner.Left, "player") {
+		return
+	}
+	if inner.Name.Value != "stats" {
+		t.Errorf("inner.Name is not 'stats'. got=%s", inner.Name.Value)
+	}
+
+	if !testLiteralExpression(t, assign.Value, 10) {
+		return
+	}
+}
+
+func TestMemberExpressionRequiresName(t *testing.T) {
+	l := lexer.New(`a.1`)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser error for missing attribute name")
+	}
+}
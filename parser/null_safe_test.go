@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestNullSafePrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a ?? b", "(a ?? b)"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b + c", "(a ?? (b + c))"},
+		{"a || b ?? c", "((a || b) ?? c)"},
+		{"a ?? b || c", "(a ?? (b || c))"},
+		{"a == null ?? b", "((a == null) ?? b)"},
+		{"m?[k]", "(m?[k])"},
+		{`m?["a"]?["b"] ?? 0`, "(((m?[a])?[b]) ?? 0)"},
+		{"m[1]?[2]", "((m[1])?[2])"},
+		{"f?.(1, 2)", "f?.(1, 2)"},
+		{"f?.(x)?.(y)", "f?.(x)?.(y)"},
+		{"o.handler?.(x) ?? -1", "((o.handler)?.(x) ?? (-1))"},
+		{"x = a ?? b", "x = (a ?? b)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOptionalExpressions(t *testing.T) {
+	p := New(lexer.New(`m?["k"]; f?.(1, ...xs); m["k"]; f(1)`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got=%d", len(program.Statements))
+	}
+
+	optional := []bool{true, true, false, false}
+	for i, stmt := range program.Statements {
+		exp := stmt.(*ast.ExpressionStatement).Expression
+		var got bool
+		switch exp := exp.(type) {
+		case *ast.IndexExpression:
+			got = exp.Optional
+		case *ast.CallExpression:
+			got = exp.Optional
+			if len(exp.Arguments) != 2 && i == 1 {
+				t.Errorf("statement %d: expected 2 arguments, got=%d", i, len(exp.Arguments))
+			}
+		default:
+			t.Fatalf("statement %d: unexpected %T", i, exp)
+		}
+		if got != optional[i] {
+			t.Errorf("statement %d: Optional=%t, want %t", i, got, optional[i])
+		}
+	}
+}
+
+func TestNullSafeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"o?.name", "expected next token to be (, got IDENT instead"},
+		{`m?["k"] = 1`, "cannot assign to an optional index expression"},
+		{`m?["k"] += 1`, "cannot assign to an optional index expression"},
+		{`m?["k"]++`, "cannot assign to an optional index expression"},
+		{"xs?[1:2]", "cannot slice with ?["},
+		{"a ? b", "no prefix parse function for ILLEGAL"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if !strings.Contains(errors[0], tt.expected) {
+			t.Errorf("%q: expected error containing %q, got %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestNumericLiteralValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"0xFF", 255},
+		{"0b1010", 10},
+		{"0o17", 15},
+		{"1_000_000", 1000000},
+		{"0x_7fff_ffff", 2147483647},
+		{"1e3", 1000.0},
+		{"1e-3", 0.001},
+		{"2.5E2", 250.0},
+		{"1_000.5", 1000.5},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch expected := tt.expected.(type) {
+		case int:
+			lit, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok || lit.Value != int64(expected) {
+				t.Errorf("%s: expected integer %d, got %s", tt.input, expected, stmt.Expression)
+			}
+		case float64:
+			lit, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok || lit.Value != expected {
+				t.Errorf("%s: expected float %g, got %s", tt.input, expected, stmt.Expression)
+			}
+		}
+	}
+}
+
+func TestNumericLiteralErrors(t *testing.T) {
+	tests := []string{
+		"0b102",
+		"0o8",
+		"0x",
+		"1__0",
+		"1_",
+		"0xFFFFFFFFFFFFFFFFFF",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestFunctionParameterDefaultsAndVariadic(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults []any // nil for a required parameter
+		expectedVariadic bool
+		expectedString   string
+	}{
+		{"func(x, y) {}", []string{"x", "y"}, nil, false, "func(x, y) "},
+		{"func(kind, count = 1) {}", []string{"kind", "count"}, []any{nil, 1}, false, "func(kind, count = 1) "},
+		{"func(a = true, b = 2) {}", []string{"a", "b"}, []any{true, 2}, false, "func(a = true, b = 2) "},
+		{"func(level, ...parts) {}", []string{"level", "parts"}, nil, true, "func(level, ...parts) "},
+		{"func(...all) {}", []string{"all"}, nil, true, "func(...all) "},
+		{"func(a, b = 1, ...rest) {}", []string{"a", "b", "rest"}, []any{nil, 1}, true, "func(a, b = 1, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("%q: wrong number of parameters. want=%d, got=%d",
+				tt.input, len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Fatalf("%q: wrong number of defaults. want=%d, got=%d",
+				tt.input, len(tt.expectedDefaults), len(function.Defaults))
+		}
+		for i, expected := range tt.expectedDefaults {
+			if expected == nil {
+				if function.Defaults[i] != nil {
+					t.Errorf("%q: parameter %d should not have a default. got=%s", tt.input, i, function.Defaults[i])
+				}
+				continue
+			}
+			testLiteralExpression(t, function.Defaults[i], expected)
+		}
+
+		if function.Variadic != tt.expectedVariadic {
+			t.Errorf("%q: Variadic wrong. want=%t, got=%t", tt.input, tt.expectedVariadic, function.Variadic)
+		}
+		if function.String() != tt.expectedString {
+			t.Errorf("%q: String() wrong. want=%q, got=%q", tt.input, tt.expectedString, function.String())
+		}
+	}
+}
+
+func TestFunctionDeclarationWithDefaults(t *testing.T) {
+	p := New(lexer.New("func spawn(kind, count = n + 1) { return count }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	function := stmt.Value.(*ast.FunctionLiteral)
+	testInfixExpression(t, function.Defaults[1], "n", "+", 1)
+}
+
+func TestSpreadArguments(t *testing.T) {
+	p := New(lexer.New("f(a, ...b, ...[1, 2], c)"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+	if len(call.Arguments) != 4 {
+		t.Fatalf("wrong number of arguments. got=%d", len(call.Arguments))
+	}
+
+	testIdentifier(t, call.Arguments[0], "a")
+	spread, ok := call.Arguments[1].(*ast.SpreadExpression)
+	if !ok {
+		t.Fatalf("argument 1 is not ast.SpreadExpression. got=%T", call.Arguments[1])
+	}
+	testIdentifier(t, spread.Value, "b")
+	if _, ok := call.Arguments[2].(*ast.SpreadExpression); !ok {
+		t.Fatalf("argument 2 is not ast.SpreadExpression. got=%T", call.Arguments[2])
+	}
+	testIdentifier(t, call.Arguments[3], "c")
+
+	if call.String() != "f(a, ...b, ...[1, 2], c)" {
+		t.Errorf("call.String() wrong. got=%q", call.String())
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []string{
+		"func(a = 1, b) {}",
+		"func(...rest, a) {}",
+		"func(...rest = 1) {}",
+		"func(1) {}",
+		"func(a, ) {}",
+		"func(a = ) {}",
+		"func(... ) {}",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/iceisfun/icescript/ast"
 	"github.com/iceisfun/icescript/lexer"
@@ -13,13 +14,14 @@ const (
 	_ int = iota
 	LOWEST
 	ASSIGN      // =
+	NULLISH     // ??
 	LOGICAL_OR  // ||
 	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X or !X
+	SUM         // + - | ^
+	PRODUCT     // * / % & &^ << >>
+	PREFIX      // -X, !X or ~X
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
@@ -36,12 +38,37 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.MOD:      PRODUCT,
+	token.BIT_OR:   SUM,
+	token.BIT_XOR:  SUM,
+	token.BIT_AND:  PRODUCT,
+	token.AND_NOT:  PRODUCT,
+	token.SHL:      PRODUCT,
+	token.SHR:      PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 	token.ASSIGN:   ASSIGN,
 	token.AND:      LOGICAL_AND,
 	token.OR:       LOGICAL_OR,
 	token.IS:       EQUALS,
+	token.NULLISH:  NULLISH,
+
+	token.QUESTION_DOT:      CALL,
+	token.QUESTION_LBRACKET: INDEX,
+
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.MOD_ASSIGN:      ASSIGN,
+	token.AND_ASSIGN:      ASSIGN,
+	token.OR_ASSIGN:       ASSIGN,
+	token.XOR_ASSIGN:      ASSIGN,
+	token.AND_NOT_ASSIGN:  ASSIGN,
+	token.SHL_ASSIGN:      ASSIGN,
+	token.SHR_ASSIGN:      ASSIGN,
+	token.INCREMENT:       ASSIGN,
+	token.DECREMENT:       ASSIGN,
 }
 
 type (
@@ -53,8 +80,9 @@ type Parser struct {
 	l      *lexer.Lexer
 	errors []token.ScriptError // Use structured errors
 
-	curToken  token.Token
-	peekToken token.Token
+	curToken   token.Token
+	peekToken  token.Token
+	aheadToken token.Token // the token after peekToken
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -71,8 +99,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_BEGIN, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
@@ -81,6 +111,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACE, p.parseMapLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.NULL, p.parseNullLiteral)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -88,6 +119,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.MOD, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.AND_NOT, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
@@ -97,12 +134,30 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.MOD_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.AND_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.OR_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.XOR_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.AND_NOT_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.SHL_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.SHR_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.INCREMENT, p.parseIncDecExpression)
+	p.registerInfix(token.DECREMENT, p.parseIncDecExpression)
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.IS, p.parseInfixExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
+	p.registerInfix(token.QUESTION_DOT, p.parseOptionalCallExpression)
+	p.registerInfix(token.QUESTION_LBRACKET, p.parseOptionalIndexExpression)
 
-	// Read two tokens, so curToken and peekToken are both set
+	// Read three tokens, so curToken, peekToken and aheadToken are all set
+	p.nextToken()
 	p.nextToken()
 	p.nextToken()
 
@@ -111,7 +166,14 @@ func New(l *lexer.Lexer) *Parser {
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = p.aheadToken
+	p.aheadToken = p.l.NextToken()
+
+	// 'type' only starts a declaration when a name follows it; anywhere else
+	// it is an ordinary identifier, as it was before struct types existed.
+	if p.peekToken.Type == token.TYPE && p.aheadToken.Type != token.IDENT {
+		p.peekToken.Type = token.IDENT
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -146,10 +208,26 @@ func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.VAR:
 		return p.parseLetStatement()
+	case token.CONST:
+		return p.parseConstStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
 	case token.FOR:
 		return p.parseForStatement() // Placeholder for loop parsing
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.TRY:
+		return p.parseTryStatement()
+	case token.SWITCH:
+		return p.parseSwitchStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.TYPE:
+		return p.parseTypeStatement()
+	case token.ENUM:
+		return p.parseEnumStatement()
 	case token.FUNCTION:
 		// Check for function declaration: func name() {}
 		if p.peekTokenIs(token.IDENT) {
@@ -163,6 +241,9 @@ func (p *Parser) parseStatement() ast.Statement {
 		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN_DECLARE) {
 			return p.parseShortVarDeclaration()
 		}
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			return p.parseLabeledStatement()
+		}
 		return p.parseExpressionStatement()
 	}
 }
@@ -200,6 +281,142 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+func (p *Parser) parseConstStatement() *ast.ConstStatement {
+	stmt := &ast.ConstStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseTypeStatement parses a struct type declaration:
+//
+//	type Name = struct { field, field, ... }
+func (p *Parser) parseTypeStatement() ast.Statement {
+	stmt := &ast.TypeStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) || !p.expectPeek(token.STRUCT) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// Fields are separated by commas or newlines, as in map literals
+	seen := make(map[string]bool)
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		if p.curTokenIs(token.SEMICOLON) {
+			continue
+		}
+		if !p.curTokenIs(token.IDENT) && !token.IsKeyword(p.curToken.Type) {
+			p.curError(fmt.Sprintf("expected field name, got %s", p.curToken.Type))
+			return nil
+		}
+
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			p.curError(fmt.Sprintf("duplicate field %s in struct %s", field.Value, stmt.Name.Value))
+			return nil
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+
+		if !p.peekTokenIs(token.RBRACE) {
+			if !p.peekTokenIs(token.COMMA) && !p.peekTokenIs(token.SEMICOLON) {
+				p.peekError(token.COMMA)
+				return nil
+			}
+			p.nextToken()
+		}
+	}
+	p.nextToken() // consume }
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseEnumStatement parses an enum declaration:
+//
+//	enum Name { Member, Member = value, ... }
+func (p *Parser) parseEnumStatement() ast.Statement {
+	stmt := &ast.EnumStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// Members are separated by commas or newlines, as in map literals
+	seen := make(map[string]bool)
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		if p.curTokenIs(token.SEMICOLON) {
+			continue
+		}
+		if !p.curTokenIs(token.IDENT) && !token.IsKeyword(p.curToken.Type) {
+			p.curError(fmt.Sprintf("expected enum member name, got %s", p.curToken.Type))
+			return nil
+		}
+
+		member := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[member.Value] {
+			p.curError(fmt.Sprintf("duplicate member %s in enum %s", member.Value, stmt.Name.Value))
+			return nil
+		}
+		seen[member.Value] = true
+
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			value = p.parseExpression(LOWEST)
+		}
+		stmt.Members = append(stmt.Members, member)
+		stmt.Values = append(stmt.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) {
+			if !p.peekTokenIs(token.COMMA) && !p.peekTokenIs(token.SEMICOLON) {
+				p.peekError(token.COMMA)
+				return nil
+			}
+			p.nextToken()
+		}
+	}
+	p.nextToken() // consume }
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseShortVarDeclaration() *ast.ShortVarDeclaration {
 	stmt := &ast.ShortVarDeclaration{Names: []*ast.Identifier{}}
 	stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
@@ -259,6 +476,58 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Line == p.curToken.Line {
+		p.nextToken()
+		stmt.Label = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Line == p.curToken.Line {
+		p.nextToken()
+		stmt.Label = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLabeledStatement parses `label: for ...`. Labels may only be attached
+// to loops, since they exist solely as targets for break/continue.
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	label := p.curToken
+
+	p.nextToken() // move to COLON
+
+	if !p.expectPeek(token.FOR) {
+		return nil
+	}
+
+	stmt := p.parseForStatement()
+	switch loop := stmt.(type) {
+	case *ast.ForStatement:
+		loop.Label = label.Literal
+	case *ast.RangeStatement:
+		loop.Label = label.Literal
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -279,6 +548,12 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 	leftExp := prefix()
 
+	return p.continueExpression(leftExp, precedence)
+}
+
+// continueExpression applies the infix operators that follow leftExp and bind
+// tighter than precedence.
+func (p *Parser) continueExpression(leftExp ast.Expression, precedence int) ast.Expression {
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
@@ -343,6 +618,25 @@ func (p *Parser) parseNullLiteral() ast.Expression {
 	return &ast.NullLiteral{Token: p.curToken}
 }
 
+// parseYieldExpression parses 'yield' with an optional value. The value is
+// omitted when the expression ends right after the keyword or the line does,
+// so a bare yield can wait a frame without a trailing semicolon.
+func (p *Parser) parseYieldExpression() ast.Expression {
+	expression := &ast.YieldExpression{Token: p.curToken}
+
+	if p.peekToken.Line != p.curToken.Line {
+		return expression
+	}
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.RBRACE, token.RPAREN, token.RBRACKET, token.COMMA, token.EOF:
+		return expression
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	return expression
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -421,6 +715,23 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			// else if: lower into an alternative block holding the nested if
+			p.nextToken()
+			ifToken := p.curToken
+			nested := p.parseIfExpression()
+			if nested == nil {
+				return nil
+			}
+			expression.Alternative = &ast.BlockStatement{
+				Token: ifToken,
+				Statements: []ast.Statement{
+					&ast.ExpressionStatement{Token: ifToken, Expression: nested},
+				},
+			}
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -457,7 +768,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -468,31 +781,75 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses the parameter list of lit: plain names,
+// names with a default value (`count = 1`) and a final variadic parameter
+// (`...rest`). Parameters with defaults must follow the required ones.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
+	for {
+		p.nextToken()
+		if !p.parseFunctionParameter(lit) {
+			return false
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		if lit.Variadic {
+			p.curError(fmt.Sprintf("variadic parameter %s must be last", lit.Parameters[len(lit.Parameters)-1].Value))
+			return false
+		}
+		p.nextToken()
+	}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	return p.expectPeek(token.RPAREN)
+}
 
-	for p.peekTokenIs(token.COMMA) {
+func (p *Parser) parseFunctionParameter(lit *ast.FunctionLiteral) bool {
+	variadic := p.curTokenIs(token.ELLIPSIS)
+	if variadic {
 		p.nextToken()
-		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if !p.curTokenIs(token.IDENT) {
+		p.curError(fmt.Sprintf("expected parameter name, got %s", p.curToken.Type))
+		return false
+	}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	lit.Parameters = append(lit.Parameters, ident)
+	lit.Variadic = variadic
+
+	if !p.peekTokenIs(token.ASSIGN) {
+		if len(lit.Defaults) > 0 && !variadic {
+			p.curError(fmt.Sprintf("parameter %s without a default value follows one with a default", ident.Value))
+			return false
+		}
+		return true
+	}
+
+	if variadic {
+		p.curError(fmt.Sprintf("variadic parameter %s cannot have a default value", ident.Value))
+		return false
 	}
 
-	return identifiers
+	p.nextToken()
+	p.nextToken()
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		return false
+	}
+
+	// Defaults is only allocated once a parameter has a default value
+	for len(lit.Defaults) < len(lit.Parameters)-1 {
+		lit.Defaults = append(lit.Defaults, nil)
+	}
+	lit.Defaults = append(lit.Defaults, value)
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -501,6 +858,16 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseOptionalCallExpression parses f?.(args). curToken is the ?. token.
+func (p *Parser) parseOptionalCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function, Optional: true}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	exp.Arguments = p.parseCallArguments()
+	return exp
+}
+
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 
@@ -510,12 +877,12 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	args = append(args, p.parseCallArgument())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		args = append(args, p.parseCallArgument())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -525,10 +892,68 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return args
 }
 
+// parseCallArgument parses one argument, which may be spread: f(...args).
+func (p *Parser) parseCallArgument() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseInterpolatedString turns "hp: ${hp}/${max}" into the layout of the
+// format builtin, "hp: %v/%v", and the embedded expressions, so that any value
+// can be embedded without converting it first.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	tok := p.curToken
+
+	var layout strings.Builder
+	layout.WriteString(strings.ReplaceAll(tok.Literal, "%", "%%"))
+	args := []ast.Expression{}
+
+	for !p.curTokenIs(token.INTERP_END) {
+		if p.peekTokenIs(token.INTERP_MIDDLE) || p.peekTokenIs(token.INTERP_END) {
+			p.peekError(token.IDENT)
+			return nil
+		}
+		p.nextToken()
+
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		args = append(args, exp)
+
+		if p.peekTokenIs(token.ILLEGAL) {
+			// The rest of the string has a malformed escape
+			p.nextToken()
+			p.noPrefixParseFnError(p.curToken.Type)
+			return nil
+		}
+		if !p.peekTokenIs(token.INTERP_MIDDLE) && !p.peekTokenIs(token.INTERP_END) {
+			p.errors = append(p.errors, token.ScriptError{
+				Kind:    token.ErrorKindParse,
+				Message: fmt.Sprintf("expected } to close interpolation, got %s instead", p.peekToken.Type),
+				Line:    p.peekToken.Line,
+			})
+			return nil
+		}
+		p.nextToken()
+
+		layout.WriteString("%v")
+		layout.WriteString(strings.ReplaceAll(p.curToken.Literal, "%", "%%"))
+	}
+
+	return &ast.InterpolatedString{Token: tok, Format: layout.String(), Values: args}
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
@@ -562,6 +987,27 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Left: left}
+
+	if !p.expectMemberName() {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// expectMemberName advances to a field or method name. Reserved words are
+// accepted, so hosts and hashes can expose names like 'type' or 'default'.
+func (p *Parser) expectMemberName() bool {
+	if token.IsKeyword(p.peekToken.Type) {
+		p.nextToken()
+		return true
+	}
+	return p.expectPeek(token.IDENT)
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	startToken := p.curToken
 	p.nextToken()
@@ -613,6 +1059,24 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return indexExp
 }
 
+// parseOptionalIndexExpression parses left?[index]. curToken is the ?[ token.
+func (p *Parser) parseOptionalIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	exp := p.parseIndexExpression(left)
+	switch exp := exp.(type) {
+	case *ast.IndexExpression:
+		exp.Optional = true
+		return exp
+	case *ast.SliceExpression:
+		p.errors = append(p.errors, token.ScriptError{
+			Kind:    token.ErrorKindParse,
+			Message: "cannot slice with ?[",
+			Line:    tok.Line,
+		})
+	}
+	return nil
+}
+
 func (p *Parser) parseMapLiteral() ast.Expression {
 	hash := &ast.MapLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -693,9 +1157,36 @@ func (p *Parser) parseForStatement() ast.Statement {
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
-	} else if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN_DECLARE) {
+	} else if p.curTokenIs(token.RANGE) {
+		// usage: for range arr { ... }
+		return p.parseRangeStatement(stmt.Token, nil)
+	} else if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.ASSIGN_DECLARE) || p.peekTokenIs(token.COMMA)) {
+		names := []*ast.Identifier{{Token: p.curToken, Value: p.curToken.Literal}}
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			names = append(names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		}
+
+		if !p.expectPeek(token.ASSIGN_DECLARE) {
+			return nil
+		}
+
+		if p.peekTokenIs(token.RANGE) {
+			// usage: for k, v := range m { ... }
+			return p.parseRangeStatement(stmt.Token, names)
+		}
+
 		// usage: for i := 0; i < 10; i = i + 1 { ... }
-		stmt.Init = p.parseShortVarDeclaration()
+		init := &ast.ShortVarDeclaration{Token: p.curToken, Names: names}
+		p.nextToken() // consume :=
+		init.Value = p.parseExpression(LOWEST)
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		stmt.Init = init
 
 		if p.curTokenIs(token.SEMICOLON) {
 			p.nextToken()
@@ -769,6 +1260,226 @@ func (p *Parser) parseForStatement() ast.Statement {
 	return stmt
 }
 
+// parseRangeStatement parses the remainder of `for k, v := range expr { ... }`.
+// names holds the loop variables (zero, one or two); curToken is := (or RANGE
+// when no variables were given).
+func (p *Parser) parseRangeStatement(forToken token.Token, names []*ast.Identifier) ast.Statement {
+	stmt := &ast.RangeStatement{Token: forToken}
+
+	switch len(names) {
+	case 0:
+	case 1:
+		stmt.Key = names[0]
+	case 2:
+		stmt.Key = names[0]
+		stmt.Value = names[1]
+	default:
+		p.errors = append(p.errors, token.ScriptError{
+			Kind:    token.ErrorKindParse,
+			Message: fmt.Sprintf("range permits at most two iteration variables, got %d", len(names)),
+			Line:    p.curToken.Line,
+		})
+		return nil
+	}
+
+	if !p.curTokenIs(token.RANGE) {
+		p.nextToken() // move to RANGE
+	}
+	p.nextToken() // move past RANGE
+
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseBlockStatement()
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	stmt := &ast.TryStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Finally = p.parseBlockStatement()
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		p.errors = append(p.errors, token.ScriptError{
+			Kind:    token.ErrorKindParse,
+			Message: "try statement requires a catch or finally block",
+			Line:    stmt.Token.Line,
+		})
+		return nil
+	}
+
+	return stmt
+}
+
+// parseSwitchStatement parses
+//
+//	switch [subject] { case v1, v2: ... default: ... }
+//	switch subject is { case int: ... case array, user: ... }
+func (p *Parser) parseSwitchStatement() ast.Statement {
+	stmt := &ast.SwitchStatement{Token: p.curToken}
+	p.nextToken()
+
+	if !p.curTokenIs(token.LBRACE) {
+		// Parse the subject above 'is' precedence first, so that in
+		// `switch x is {` the brace is not taken as the operand of 'is'.
+		subject := p.parseExpression(EQUALS)
+		if p.peekTokenIs(token.IS) {
+			p.nextToken()
+			if p.peekTokenIs(token.LBRACE) {
+				stmt.TypeSwitch = true
+			} else {
+				subject = p.parseInfixExpression(subject)
+			}
+		}
+		if !stmt.TypeSwitch {
+			subject = p.continueExpression(subject, LOWEST)
+		}
+		stmt.Subject = subject
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) {
+		switch p.curToken.Type {
+		case token.SEMICOLON:
+			p.nextToken()
+		case token.CASE:
+			clause := p.parseSwitchCase()
+			if clause == nil {
+				return nil
+			}
+			stmt.Cases = append(stmt.Cases, clause)
+		case token.DEFAULT:
+			if stmt.Default != nil {
+				p.curError("multiple defaults in switch")
+				return nil
+			}
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			stmt.Default = p.parseCaseBody()
+		case token.EOF:
+			p.curError("unterminated switch statement")
+			return nil
+		default:
+			p.curError(fmt.Sprintf("expected case or default in switch, got %s", p.curToken.Type))
+			return nil
+		}
+	}
+
+	return stmt
+}
+
+// parseSwitchCase parses `case v1, v2: body`. curToken is CASE; on return it
+// is the token that ends the body.
+func (p *Parser) parseSwitchCase() *ast.SwitchCase {
+	clause := &ast.SwitchCase{Token: p.curToken}
+
+	p.nextToken()
+	clause.Values = append(clause.Values, p.parseExpression(LOWEST))
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		clause.Values = append(clause.Values, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	clause.Body = p.parseCaseBody()
+	return clause
+}
+
+// parseCaseBody parses the statements after a case or default colon, up to
+// the next case, default or the closing brace of the switch.
+func (p *Parser) parseCaseBody() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.CASE) && !p.curTokenIs(token.DEFAULT) &&
+		!p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+
+	return block
+}
+
+// curError records a parse error at the current token.
+func (p *Parser) curError(msg string) {
+	p.errors = append(p.errors, token.ScriptError{
+		Kind:    token.ErrorKindParse,
+		Message: msg,
+		Line:    p.curToken.Line,
+	})
+}
+
 func (p *Parser) parseFunctionDeclaration() ast.Statement {
 	// Syntactic sugar: func name(...) { ... }  => var name = func(...) { ... }
 	stmt := &ast.LetStatement{Token: token.Token{Type: token.VAR, Literal: "var"}}
@@ -788,7 +1499,9 @@ func (p *Parser) parseFunctionDeclaration() ast.Statement {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -837,6 +1550,10 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
+	if t == token.ILLEGAL && strings.HasPrefix(p.curToken.Literal, `\`) {
+		// The lexer reports a string by its malformed escape
+		msg = fmt.Sprintf("invalid escape sequence %s in string", p.curToken.Literal)
+	}
 	p.errors = append(p.errors, token.ScriptError{
 		Kind:    token.ErrorKindParse,
 		Message: msg,
@@ -871,17 +1588,82 @@ func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
 		stmt.Value = p.parseExpression(precedence) // was LOWEST, but let's respect precedence usually?
 		return stmt
 	case *ast.IndexExpression:
+		if !p.checkAssignTarget(leftNode) {
+			return nil
+		}
 		stmt := &ast.IndexAssignExpression{Token: p.curToken, Left: leftNode}
 		precedence := p.curPrecedence()
 		p.nextToken()
 		stmt.Value = p.parseExpression(precedence)
 		return stmt
+	case *ast.MemberExpression:
+		stmt := &ast.MemberAssignExpression{Token: p.curToken, Left: leftNode}
+		precedence := p.curPrecedence()
+		p.nextToken()
+		stmt.Value = p.parseExpression(precedence)
+		return stmt
 	default:
 		p.errors = append(p.errors, token.ScriptError{
 			Kind:    token.ErrorKindParse,
-			Message: fmt.Sprintf("expected identifier or index expression on left side of assignment, got %T", left),
+			Message: fmt.Sprintf("expected identifier, index or member expression on left side of assignment, got %T", left),
 			Line:    p.curToken.Line,
 		})
 		return nil
 	}
 }
+
+// parseCompoundAssignExpression parses x op= value. curToken is the operator.
+func (p *Parser) parseCompoundAssignExpression(left ast.Expression) ast.Expression {
+	if !p.checkAssignTarget(left) {
+		return nil
+	}
+
+	exp := &ast.CompoundAssignExpression{
+		Token:    p.curToken,
+		Target:   left,
+		Operator: p.curToken.Literal,
+	}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.Value = p.parseExpression(precedence)
+	return exp
+}
+
+// parseIncDecExpression parses x++ and x--. curToken is the operator.
+func (p *Parser) parseIncDecExpression(left ast.Expression) ast.Expression {
+	if !p.checkAssignTarget(left) {
+		return nil
+	}
+
+	return &ast.IncDecExpression{
+		Token:    p.curToken,
+		Target:   left,
+		Operator: p.curToken.Literal,
+	}
+}
+
+// checkAssignTarget reports whether left can be updated in place by the
+// operator in curToken, recording a parse error if not.
+func (p *Parser) checkAssignTarget(left ast.Expression) bool {
+	switch left := left.(type) {
+	case *ast.Identifier, *ast.MemberExpression:
+		return true
+	case *ast.IndexExpression:
+		if !left.Optional {
+			return true
+		}
+		p.errors = append(p.errors, token.ScriptError{
+			Kind:    token.ErrorKindParse,
+			Message: "cannot assign to an optional index expression",
+			Line:    p.curToken.Line,
+		})
+		return false
+	}
+
+	p.errors = append(p.errors, token.ScriptError{
+		Kind:    token.ErrorKindParse,
+		Message: fmt.Sprintf("expected identifier, index or member expression on left side of %s, got %s", p.curToken.Literal, left.String()),
+		Line:    p.curToken.Line,
+	})
+	return false
+}
@@ -330,6 +330,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a | b & c",
+			"(a | (b & c))",
+		},
+		{
+			"a + b << c",
+			"(a + (b << c))",
+		},
+		{
+			"a ^ b &^ c >> d",
+			"(a ^ ((b &^ c) >> d))",
+		},
+		{
+			"a & b == c | d",
+			"((a & b) == (c | d))",
+		},
+		{
+			"~a & -b",
+			"((~a) & (-b))",
+		},
+		{
+			"flags & MASK != 0 && x",
+			"(((flags & MASK) != 0) && x)",
+		},
 	}
 
 	for _, tt := range tests {
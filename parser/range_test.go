@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestRangeStatementParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKey   string
+		expectedValue string
+		expectedIter  string
+	}{
+		{"for k, v := range m { }", "k", "v", "m"},
+		{"for i := range arr { }", "i", "", "arr"},
+		{"for _, v := range [1, 2] { }", "_", "v", "[1, 2]"},
+		{"for range items { }", "", "", "items"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.RangeStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.RangeStatement. got=%T", program.Statements[0])
+		}
+
+		if got := identName(stmt.Key); got != tt.expectedKey {
+			t.Errorf("key wrong. want=%q, got=%q", tt.expectedKey, got)
+		}
+		if got := identName(stmt.Value); got != tt.expectedValue {
+			t.Errorf("value wrong. want=%q, got=%q", tt.expectedValue, got)
+		}
+		if got := stmt.Iterable.String(); got != tt.expectedIter {
+			t.Errorf("iterable wrong. want=%q, got=%q", tt.expectedIter, got)
+		}
+	}
+}
+
+func TestCStyleForStillParses(t *testing.T) {
+	l := lexer.New("for i := 0; i < 10; i = i + 1 { }")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := stmt.Init.(*ast.ShortVarDeclaration); !ok {
+		t.Errorf("stmt.Init is not ast.ShortVarDeclaration. got=%T", stmt.Init)
+	}
+}
+
+func identName(ident *ast.Identifier) string {
+	if ident == nil {
+		return ""
+	}
+	return ident.Value
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestTypeStatement(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedName   string
+		expectedFields []string
+	}{
+		{"type Vec = struct { x, y }", "Vec", []string{"x", "y"}},
+		{"type Vec = struct { x, y, }", "Vec", []string{"x", "y"}},
+		{"type Empty = struct {}", "Empty", nil},
+		{"type Entity = struct {\n\tname,\n\thp,\n\tpos,\n};", "Entity", []string{"name", "hp", "pos"}},
+		{"type Entity = struct {\n\tname\n\thp, pos\n}", "Entity", []string{"name", "hp", "pos"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement, got=%d", tt.input, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.TypeStatement)
+		if !ok {
+			t.Fatalf("%q: statement is not *ast.TypeStatement. got=%T", tt.input, program.Statements[0])
+		}
+
+		testIdentifier(t, stmt.Name, tt.expectedName)
+		if len(stmt.Fields) != len(tt.expectedFields) {
+			t.Fatalf("%q: wrong number of fields. want=%d, got=%d", tt.input, len(tt.expectedFields), len(stmt.Fields))
+		}
+		for i, field := range tt.expectedFields {
+			testIdentifier(t, stmt.Fields[i], field)
+		}
+	}
+}
+
+func TestTypeStatementString(t *testing.T) {
+	p := New(lexer.New("type Vec = struct { x, y }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if got := program.String(); got != "type Vec = struct { x, y }" {
+		t.Errorf("wrong String(). got=%q", got)
+	}
+}
+
+func TestTypeStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"type Vec = struct { x, x }", "duplicate field x in struct Vec"},
+		{"type Vec struct { x }", "expected next token to be ="},
+		{"type Vec = { x }", "expected next token to be STRUCT"},
+		{"type Vec = struct { x y }", "expected next token to be ,"},
+		{"type Vec = struct { 1 }", "expected field name, got INT"},
+		{"type Vec = struct { x", "got EOF instead"},
+		// Without a name, type is an ordinary variable
+		{"type = struct { x }", "no prefix parse function for STRUCT"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if !strings.Contains(errors[0], tt.expected) {
+			t.Errorf("%q: expected error containing %q, got %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestSwitchStatement(t *testing.T) {
+	input := `
+switch x + 1 {
+case 1, 2:
+    y = 1
+    z = 2
+case 3:
+default:
+    y = 0
+}`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.SwitchStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.SwitchStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Subject, "x", "+", 1) {
+		return
+	}
+	if stmt.TypeSwitch {
+		t.Errorf("stmt.TypeSwitch is true")
+	}
+
+	if len(stmt.Cases) != 2 {
+		t.Fatalf("wrong number of cases. got=%d", len(stmt.Cases))
+	}
+	if len(stmt.Cases[0].Values) != 2 {
+		t.Fatalf("wrong number of values in first case. got=%d", len(stmt.Cases[0].Values))
+	}
+	testLiteralExpression(t, stmt.Cases[0].Values[0], 1)
+	testLiteralExpression(t, stmt.Cases[0].Values[1], 2)
+	if len(stmt.Cases[0].Body.Statements) != 2 {
+		t.Errorf("first case body does not contain 2 statements. got=%d", len(stmt.Cases[0].Body.Statements))
+	}
+	if len(stmt.Cases[1].Body.Statements) != 0 {
+		t.Errorf("second case body is not empty. got=%d", len(stmt.Cases[1].Body.Statements))
+	}
+
+	if stmt.Default == nil || len(stmt.Default.Statements) != 1 {
+		t.Fatalf("default body does not contain 1 statement. got=%v", stmt.Default)
+	}
+}
+
+func TestTaglessSwitchStatement(t *testing.T) {
+	p := New(lexer.New(`switch { case x > 0: "pos" case x < 0: "neg" }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.SwitchStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.SwitchStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Subject != nil {
+		t.Errorf("stmt.Subject is not nil. got=%s", stmt.Subject)
+	}
+	if len(stmt.Cases) != 2 {
+		t.Fatalf("wrong number of cases. got=%d", len(stmt.Cases))
+	}
+	testInfixExpression(t, stmt.Cases[0].Values[0], "x", ">", 0)
+	if stmt.Default != nil {
+		t.Errorf("stmt.Default is not nil")
+	}
+}
+
+func TestTypeSwitchStatement(t *testing.T) {
+	p := New(lexer.New("switch v is { case int, float: 1 case user: 2 }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.SwitchStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.SwitchStatement. got=%T", program.Statements[0])
+	}
+	if !stmt.TypeSwitch {
+		t.Errorf("stmt.TypeSwitch is false")
+	}
+	testIdentifier(t, stmt.Subject, "v")
+	testIdentifier(t, stmt.Cases[0].Values[0], "int")
+	testIdentifier(t, stmt.Cases[0].Values[1], "float")
+	testIdentifier(t, stmt.Cases[1].Values[0], "user")
+}
+
+func TestSwitchSubjectWithIs(t *testing.T) {
+	p := New(lexer.New("switch v is int { case true: 1 }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SwitchStatement)
+	if stmt.TypeSwitch {
+		t.Errorf("stmt.TypeSwitch is true")
+	}
+	if _, ok := stmt.Subject.(*ast.InfixExpression); !ok {
+		t.Errorf("stmt.Subject is not ast.InfixExpression. got=%T", stmt.Subject)
+	}
+}
+
+func TestSwitchStatementErrors(t *testing.T) {
+	tests := []string{
+		"switch x { default: 1 default: 2 }",
+		"switch x { 1 }",
+		"switch x { case 1: 1",
+		"switch x { case: 1 }",
+		"switch x { case 1 2 }",
+		"switch x case 1: 1",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
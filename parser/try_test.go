@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestTryStatement(t *testing.T) {
+	tests := []struct {
+		input        string
+		catchParam   string
+		hasCatch     bool
+		hasFinally   bool
+		blockStmts   int
+		catchStmts   int
+		finallyStmts int
+	}{
+		{`try { a; b } catch (e) { c }`, "e", true, false, 2, 1, 0},
+		{`try { a } catch { b; c }`, "", true, false, 1, 2, 0},
+		{`try { a } finally { b }`, "", false, true, 1, 0, 1},
+		{`try { a } catch (err) { b } finally { c; d }`, "err", true, true, 1, 1, 2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.TryStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.TryStatement. got=%T", program.Statements[0])
+		}
+
+		if len(stmt.Block.Statements) != tt.blockStmts {
+			t.Errorf("try block has wrong statement count. want=%d, got=%d",
+				tt.blockStmts, len(stmt.Block.Statements))
+		}
+
+		if tt.catchParam == "" {
+			if stmt.CatchParam != nil {
+				t.Errorf("expected no catch parameter, got %s", stmt.CatchParam)
+			}
+		} else if !testIdentifier(t, stmt.CatchParam, tt.catchParam) {
+			return
+		}
+
+		if (stmt.Catch != nil) != tt.hasCatch {
+			t.Fatalf("catch block presence wrong. want=%t", tt.hasCatch)
+		}
+		if tt.hasCatch && len(stmt.Catch.Statements) != tt.catchStmts {
+			t.Errorf("catch block has wrong statement count. want=%d, got=%d",
+				tt.catchStmts, len(stmt.Catch.Statements))
+		}
+
+		if (stmt.Finally != nil) != tt.hasFinally {
+			t.Fatalf("finally block presence wrong. want=%t", tt.hasFinally)
+		}
+		if tt.hasFinally && len(stmt.Finally.Statements) != tt.finallyStmts {
+			t.Errorf("finally block has wrong statement count. want=%d, got=%d",
+				tt.finallyStmts, len(stmt.Finally.Statements))
+		}
+	}
+}
+
+func TestTryRequiresCatchOrFinally(t *testing.T) {
+	l := lexer.New(`try { a }`)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser error")
+	}
+	if !strings.Contains(errors[0], "requires a catch or finally block") {
+		t.Errorf("unexpected error: %q", errors[0])
+	}
+}
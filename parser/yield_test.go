@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iceisfun/icescript/ast"
+	"github.com/iceisfun/icescript/lexer"
+)
+
+func TestYieldExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"func() { yield 1 }", "yield 1"},
+		{"func() { yield }", "yield"},
+		{"func() { yield; }", "yield"},
+		{"func() { yield a + b * 2 }", "yield (a + (b * 2))"},
+		{"func() { var x = yield pos }", "var x = yield pos;"},
+		{"func() { f(yield, 2) }", "f(yield, 2)"},
+		{"func() { yield\nx }", "yield"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		got := function.Body.Statements[0].String()
+		if got != tt.expected {
+			t.Errorf("%q: wrong statement. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -19,9 +19,28 @@ const (
 	FLOAT  = "FLOAT" // 3.14
 	STRING = "STRING"
 
+	// An interpolated string "a ${x} b ${y} c" is lexed as INTERP_BEGIN "a ",
+	// the tokens of x, INTERP_MIDDLE " b ", the tokens of y and INTERP_END " c"
+	INTERP_BEGIN  = "INTERP_BEGIN"
+	INTERP_MIDDLE = "INTERP_MIDDLE"
+	INTERP_END    = "INTERP_END"
+
 	// Operators
-	ASSIGN         = "="
-	ASSIGN_DECLARE = ":="
+	ASSIGN          = "="
+	ASSIGN_DECLARE  = ":="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	MOD_ASSIGN      = "%="
+	AND_ASSIGN      = "&="
+	OR_ASSIGN       = "|="
+	XOR_ASSIGN      = "^="
+	AND_NOT_ASSIGN  = "&^="
+	SHL_ASSIGN      = "<<="
+	SHR_ASSIGN      = ">>="
+	INCREMENT       = "++"
+	DECREMENT       = "--"
 
 	PLUS     = "+"
 	MINUS    = "-"
@@ -30,6 +49,14 @@ const (
 	SLASH    = "/"
 	MOD      = "%"
 
+	BIT_AND = "&"
+	BIT_OR  = "|"
+	BIT_XOR = "^"
+	AND_NOT = "&^"
+	SHL     = "<<"
+	SHR     = ">>"
+	BIT_NOT = "~"
+
 	LT  = "<"
 	GT  = ">"
 	LTE = "<="
@@ -40,6 +67,10 @@ const (
 	OR     = "||"
 	AND    = "&&"
 
+	NULLISH           = "??"
+	QUESTION_LBRACKET = "?[" // obj?[key]
+	QUESTION_DOT      = "?." // f?.(args)
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -51,6 +82,7 @@ const (
 	LBRACKET  = "["
 	RBRACKET  = "]"
 	DOT       = "."
+	ELLIPSIS  = "..."
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -68,6 +100,18 @@ const (
 	CONST    = "CONST"
 	RANGE    = "RANGE"
 	IS       = "IS"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	IMPORT   = "IMPORT"
+	AS       = "AS"
+	SWITCH   = "SWITCH"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
+	YIELD    = "YIELD"
+	TYPE     = "TYPE"
+	STRUCT   = "STRUCT"
+	ENUM     = "ENUM"
 )
 
 var keywords = map[string]TokenType{
@@ -86,6 +130,18 @@ var keywords = map[string]TokenType{
 	"const":    CONST,
 	"range":    RANGE,
 	"is":       IS,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"import":   IMPORT,
+	"as":       AS,
+	"switch":   SWITCH,
+	"case":     CASE,
+	"default":  DEFAULT,
+	"yield":    YIELD,
+	"type":     TYPE,
+	"struct":   STRUCT,
+	"enum":     ENUM,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -94,3 +150,14 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// IsKeyword reports whether t is a reserved word. Keywords are still valid
+// member names after a dot.
+func IsKeyword(t TokenType) bool {
+	for _, kw := range keywords {
+		if kw == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"12 &^ 10", 4},
+		{"1 << 10", 1024},
+		{"1024 >> 3", 128},
+		{"-8 >> 1", -4},
+		{"1 << 64", 0},
+		{"-1 >> 100", -1},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 + 2 << 3", 17},
+		{"6 & 3 == 2", true},
+		{"0xFF & 0x0F", 15},
+		{"0b1010 | 0b0101", 15},
+		{"0o17", 15},
+		{"1_000_000", 1000000},
+		{"1e3", 1000.0},
+		{"1.5e-1", 0.15},
+		{"var flags = 0; flags |= 1 << 2; flags |= 1; flags &^= 1; flags", 4},
+		{"var x = 1; x <<= 4; x >>= 1; x ^= 3; x &= 0xF; x", 11},
+		{"var tiles = [0, 0]; tiles[1] |= 8; tiles[1]", 8},
+		{"const NORTH = 1 << 0; const EAST = 1 << 1; var m = NORTH | EAST; m & EAST != 0", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBitwiseOperatorErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5 & 1", "unsupported types for bitwise operation: FLOAT INTEGER"},
+		{`"a" | 1`, "unsupported types for bitwise operation: STRING INTEGER"},
+		{"true ^ false", "unsupported types for bitwise operation: BOOLEAN BOOLEAN"},
+		{"var n = -1; 1 << n", "negative shift count: -1"},
+		{"~1.5", "unsupported type for bitwise complement: FLOAT"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
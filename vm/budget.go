@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/iceisfun/icescript/opcode"
+)
+
+// Stats describes the work done by the most recent Run, Invoke or Resume.
+type Stats struct {
+	Instructions int64 // instructions executed
+	Cost         int64 // budget used: the cost of each instruction plus the cost of builtin calls
+	Allocated    int64 // approximate bytes allocated for arrays, hashes and strings
+}
+
+// BudgetExceededError is returned by Run, Invoke and Resume when a script
+// uses more than its instruction budget. Like context cancellation it cannot
+// be caught by the script's try blocks.
+type BudgetExceededError struct {
+	Budget   int64
+	Used     int64
+	File     string
+	Line     int
+	Function string
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("instruction budget of %d exceeded at %s:%d in %s", e.Budget, e.File, e.Line, e.Function)
+}
+
+// WithInstructionBudget limits each Run, Invoke and Resume to budget units of
+// work. Every instruction costs 1 unless WithOpcodeCosts says otherwise, and
+// calls of builtins listed in WithBuiltinCosts cost extra. A budget of 0
+// means no limit.
+func WithInstructionBudget(budget int64) Option {
+	return func(vm *VM) {
+		vm.budget = budget
+	}
+}
+
+// WithOpcodeCosts sets the cost of individual instructions. Opcodes that are
+// not listed cost 1.
+func WithOpcodeCosts(costs map[opcode.Opcode]int64) Option {
+	return func(vm *VM) {
+		vm.opCosts = make([]int64, 256)
+		for i := range vm.opCosts {
+			vm.opCosts[i] = 1
+		}
+		for op, cost := range costs {
+			vm.opCosts[op] = cost
+		}
+	}
+}
+
+// WithBuiltinCosts sets the cost of calling builtins, by name, on top of the
+// cost of the call instruction.
+func WithBuiltinCosts(costs map[string]int64) Option {
+	return func(vm *VM) {
+		vm.builtinCosts = make(map[string]int64, len(costs))
+		for name, cost := range costs {
+			vm.builtinCosts[name] = cost
+		}
+	}
+}
+
+// SetInstructionBudget changes the budget used by later calls of Run, Invoke
+// and Resume. A budget of 0 means no limit.
+func (vm *VM) SetInstructionBudget(budget int64) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.budget = budget
+}
+
+// Stats returns the work done by the most recent Run, Invoke or Resume.
+func (vm *VM) Stats() Stats {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.stats
+}
+
+// charge adds cost to the work done by the current call and fails once it is
+// over budget.
+func (vm *VM) charge(cost int64) error {
+	vm.stats.Cost += cost
+	if vm.budget > 0 && vm.stats.Cost > vm.budget {
+		return vm.budgetError()
+	}
+	return nil
+}
+
+func (vm *VM) budgetError() error {
+	err := &BudgetExceededError{Budget: vm.budget, Used: vm.stats.Cost, File: defaultFileName}
+	if frame := vm.currentFrame(); frame != nil && frame.cl != nil && frame.cl.Fn != nil {
+		err.File = fileNameOf(frame.cl.Fn)
+		err.Line = translateIPToLine(frame.cl.Fn.SourceMap, frame.ip)
+		err.Function = frame.cl.Fn.Name
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/opcode"
+)
+
+func TestInstructionBudgetExceeded(t *testing.T) {
+	bytecode := compileInput(t, "var i = 0\nfor {\n\ti++\n}")
+
+	vm := New(bytecode, WithInstructionBudget(1000))
+	err := vm.Run(context.Background())
+
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected *BudgetExceededError, got %T: %v", err, err)
+	}
+	if budgetErr.Budget != 1000 || budgetErr.Used != 1001 {
+		t.Errorf("wrong budget/used. got=%d/%d", budgetErr.Budget, budgetErr.Used)
+	}
+	if budgetErr.Function != "main" || budgetErr.Line < 2 {
+		t.Errorf("wrong location. got=%s line %d", budgetErr.Function, budgetErr.Line)
+	}
+
+	stats := vm.Stats()
+	if stats.Instructions != 1001 || stats.Cost != 1001 {
+		t.Errorf("wrong stats. got=%+v", stats)
+	}
+}
+
+func TestInstructionBudgetNotCatchable(t *testing.T) {
+	input := `
+var caught = 0
+for {
+	try {
+		for {}
+	} catch {
+		caught++
+	}
+}
+`
+	vm := New(compileInput(t, input), WithInstructionBudget(500))
+	err := vm.Run(context.Background())
+
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected *BudgetExceededError, got %T: %v", err, err)
+	}
+	caught, _ := vm.GetGlobal("caught")
+	testExpectedObject(t, 0, caught)
+}
+
+func TestInstructionBudgetWithinLimit(t *testing.T) {
+	vm := New(compileInput(t, "var s = 0; for var i = 0; i < 10; i++ { s += i }; s"), WithInstructionBudget(1000))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 45, vm.LastPoppedStackElem())
+
+	stats := vm.Stats()
+	if stats.Instructions == 0 || stats.Instructions > 1000 || stats.Cost != stats.Instructions {
+		t.Errorf("wrong stats. got=%+v", stats)
+	}
+}
+
+func TestInstructionBudgetPerInvoke(t *testing.T) {
+	input := `
+func tick(n) {
+	var s = 0
+	for var i = 0; i < n; i++ { s += i }
+	return s
+}
+`
+	vm := New(compileInput(t, input), WithInstructionBudget(200))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	tick, _ := vm.GetGlobal("tick")
+
+	// Each Invoke starts with the full budget
+	var used int64
+	for i := 0; i < 5; i++ {
+		result, err := vm.Invoke(context.Background(), tick, &object.Integer{Value: 10})
+		if err != nil {
+			t.Fatalf("Invoke %d: %s", i, err)
+		}
+		testExpectedObject(t, 45, result)
+		if i > 0 && vm.Stats().Cost != used {
+			t.Errorf("Invoke %d: cost %d, previous %d", i, vm.Stats().Cost, used)
+		}
+		used = vm.Stats().Cost
+	}
+
+	_, err := vm.Invoke(context.Background(), tick, &object.Integer{Value: 1000})
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected *BudgetExceededError, got %T: %v", err, err)
+	}
+	if budgetErr.Function != "tick" {
+		t.Errorf("wrong function. got=%q", budgetErr.Function)
+	}
+
+	// A larger allowance takes effect on the next call
+	vm.SetInstructionBudget(0)
+	result, err := vm.Invoke(context.Background(), tick, &object.Integer{Value: 1000})
+	if err != nil {
+		t.Fatalf("Invoke without budget: %s", err)
+	}
+	testExpectedObject(t, 499500, result)
+}
+
+func TestInstructionBudgetPerResume(t *testing.T) {
+	input := `
+func worker() {
+	for {
+		for var i = 0; i < 20; i++ {}
+		yield 1
+	}
+}
+var co = worker()
+`
+	vm := New(compileInput(t, input), WithInstructionBudget(300))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	global, _ := vm.GetGlobal("co")
+	co := global.(*object.Coroutine)
+
+	for i := 0; i < 10; i++ {
+		if _, err := vm.Resume(context.Background(), co, nil); err != nil {
+			t.Fatalf("Resume %d: %s", i, err)
+		}
+	}
+}
+
+func TestOpcodeCosts(t *testing.T) {
+	input := "var s = 0; for var i = 0; i < 3; i++ { s += i }"
+
+	plain := New(compileInput(t, input))
+	if err := plain.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	weighted := New(compileInput(t, input), WithOpcodeCosts(map[opcode.Opcode]int64{
+		opcode.OpAdd:       10,
+		opcode.OpSetGlobal: 0,
+	}))
+	if err := weighted.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if plain.Stats().Instructions != weighted.Stats().Instructions {
+		t.Fatalf("instruction counts differ: %d vs %d", plain.Stats().Instructions, weighted.Stats().Instructions)
+	}
+	if plain.Stats().Cost != plain.Stats().Instructions {
+		t.Errorf("default cost should be 1 per instruction. got=%+v", plain.Stats())
+	}
+	if weighted.Stats().Cost == plain.Stats().Cost {
+		t.Errorf("opcode costs were not applied. got=%+v", weighted.Stats())
+	}
+}
+
+func TestBuiltinCosts(t *testing.T) {
+	input := `var s = ""; for var i = 0; i < 5; i++ { s = format("%v%v", s, i) }; s`
+
+	plain := New(compileInput(t, input))
+	if err := plain.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	weighted := New(compileInput(t, input), WithBuiltinCosts(map[string]int64{"format": 100}))
+	if err := weighted.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if got, want := weighted.Stats().Cost, plain.Stats().Cost+500; got != want {
+		t.Errorf("wrong cost with builtin costs. want=%d, got=%d", want, got)
+	}
+
+	limited := New(compileInput(t, input), WithBuiltinCosts(map[string]int64{"format": 100}), WithInstructionBudget(300))
+	err := limited.Run(context.Background())
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected *BudgetExceededError, got %T: %v", err, err)
+	}
+}
@@ -0,0 +1,194 @@
+package vm
+
+import "testing"
+
+func TestMutableCaptures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			func counter() {
+				n := 0
+				return func() { n = n + 1; return n }
+			}
+			var next = counter()
+			next()
+			next()
+			next()
+			`,
+			3,
+		},
+		{
+			// Each call gets its own variable
+			`
+			func counter() {
+				n := 0
+				return func() { n = n + 1; return n }
+			}
+			var a = counter()
+			var b = counter()
+			a()
+			a()
+			b()
+			a() * 10 + b()
+			`,
+			32,
+		},
+		{
+			// Sibling closures share the captured variable
+			`
+			func pair() {
+				v := 1
+				var get = func() { return v }
+				var set = func(x) { v = x }
+				return [get, set]
+			}
+			var fns = pair()
+			fns[1](42)
+			fns[0]()
+			`,
+			42,
+		},
+		{
+			// The declaring function sees writes made by the closure
+			`
+			func f() {
+				n := 1
+				var bump = func() { n = n + 10 }
+				bump()
+				bump()
+				return n
+			}
+			f()
+			`,
+			21,
+		},
+		{
+			// Captured parameters are mutable too
+			`
+			func acc(total) {
+				return func(x) { total = total + x; return total }
+			}
+			var add = acc(100)
+			add(1)
+			add(2)
+			`,
+			103,
+		},
+		{
+			// Writes propagate through several levels of nesting
+			`
+			func outer() {
+				n := 0
+				var middle = func() {
+					return func() { n = n + 1 }
+				}
+				var inc = middle()
+				inc()
+				inc()
+				return n
+			}
+			outer()
+			`,
+			2,
+		},
+		{
+			// A declaration inside a loop body binds a fresh variable each iteration
+			`
+			func f() {
+				var fns = []
+				for i := 0; i < 3; i = i + 1 {
+					v := i
+					push(fns, func() { return v })
+				}
+				return fns[0]() + fns[1]() * 10 + fns[2]() * 100
+			}
+			f()
+			`,
+			210,
+		},
+		{
+			// Variables declared by a for clause are copied per iteration
+			`
+			func f() {
+				var fns = []
+				for i := 0; i < 3; i++ {
+					push(fns, func() { return i })
+				}
+				return fns[0]() + fns[1]() * 10 + fns[2]() * 100
+			}
+			f()
+			`,
+			210,
+		},
+		{
+			// Range variables are bound per iteration as well
+			`
+			func f() {
+				var fns = []
+				for _, v := range [0, 1, 2] {
+					push(fns, func() { return v })
+				}
+				return fns[0]() + fns[1]() * 10 + fns[2]() * 100
+			}
+			f()
+			`,
+			210,
+		},
+		{
+			// Writes in the body carry over to the next iteration's copy
+			`
+			func f() {
+				var fns = []
+				for i := 0; i < 6; i++ {
+					push(fns, func() { return i })
+					i++
+				}
+				return fns[0]() + fns[1]() * 10 + fns[2]() * 100
+			}
+			f()
+			`,
+			531,
+		},
+		{
+			// Local functions can call themselves
+			`
+			func f() {
+				func fact(n) {
+					if n <= 1 { return 1 }
+					return n * fact(n - 1)
+				}
+				return fact(5)
+			}
+			f()
+			`,
+			120,
+		},
+		{
+			// Memoization
+			`
+			func memo(fn) {
+				var cache = {}
+				var calls = 0
+				var call = func(x) {
+					if !contains(cache, x) {
+						calls = calls + 1
+						cache[x] = fn(x)
+					}
+					return cache[x]
+				}
+				var count = func() { return calls }
+				return [call, count]
+			}
+			var fns = memo(func(x) { return x * x })
+			var sq = fns[0]
+			sq(3)
+			sq(3)
+			sq(4)
+			fns[1]()
+			`,
+			2,
+		},
+	}
+
+	runVmTests(t, tests)
+}
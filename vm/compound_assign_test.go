@@ -0,0 +1,58 @@
+package vm
+
+import "testing"
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []vmTestCase{
+		{"var x = 10; x += 5; x", 15},
+		{"var x = 10; x -= 5; x", 5},
+		{"var x = 10; x *= 5; x", 50},
+		{"var x = 10; x /= 4; x", 2},
+		{"var x = 10; x %= 4; x", 2},
+		{"var x = 1.5; x *= 2; x", 3.0},
+		{"var x = 1; x += 0.5; x", 1.5},
+		{"var x = 1; x += 2", 3},
+		{"var x = 1; x++; x", 2},
+		{"var x = 1; x--; x", 0},
+		{"func f() { n := 1; n += 2; n++; return n }; f()", 4},
+		{"func f() { n := 1; g := func() { n *= 3 }; g(); g(); return n }; f()", 9},
+		{"var s = 0; for i := 0; i < 5; i++ { s += i }; s", 10},
+		{"var s = 0; for i := 10; i > 0; i -= 3 { s++ }; s", 4},
+		{"var a = [1, 2, 3]; a[1] += 10; a", []int{1, 12, 3}},
+		{"var a = [1, 2, 3]; a[0]++; a[2]--; a", []int{2, 2, 2}},
+		{`var m = {"hp": 100}; var dmg = 30; m["hp"] -= dmg; m["hp"]`, 70},
+		{`var m = {"hp": 100}; m.hp /= 4; m.hp`, 25},
+		{`var m = {"stats": {"hp": 1}}; m.stats.hp++; m.stats.hp`, 2},
+		{`var m = {"n": [1]}; m.n[0] += 4; m.n[0]`, 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCompoundAssignmentEvaluatesTargetOnce(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var calls = 0
+			var a = [5, 6]
+			func index() { calls++; return 1 }
+			a[index()] += 10
+			a[1] * 10 + calls
+			`,
+			161,
+		},
+		{
+			`
+			var calls = 0
+			var m = {"hp": 3}
+			func target() { calls++; return m }
+			target().hp *= 2
+			target()["hp"]++
+			m.hp * 10 + calls
+			`,
+			72,
+		},
+	}
+
+	runVmTests(t, tests)
+}
@@ -0,0 +1,60 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+func TestConstants(t *testing.T) {
+	tests := []vmTestCase{
+		{"const TICK = 16; var x = 3; x * TICK", 48},
+		{"const A = 2; const B = A * 8; B + A", 18},
+		{"const RATE = 0.5; 10 * RATE", 5.0},
+		{`const VERSION = "2.0"; "v" + VERSION`, "v2.0"},
+		{"const DEBUG = false; if DEBUG { 1 } else { 2 }", 2},
+		{"const DEBUG = true; DEBUG == true", true},
+		{"const NONE = null; NONE", Null},
+		{"const N = -5; -N", 5},
+		{`const START = len("abc"); START * 2`, 6},
+		{"func f() { const LIMIT = 10; return LIMIT - 1 }; f()", 9},
+		{"func f() { const K = 3; return func(x) { return x * K } }; f()(5)", 15},
+		{"const MAX = 3; var n = 0; for i := 0; i < MAX; i++ { n += MAX }; n", 9},
+		{"func area(r) { return r * r * PI }; const PI = 3; area(2)", 12},
+		{"const ITEMS = [1, 2]; push(ITEMS, 3); len(ITEMS)", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConstantDivisionByZeroIsRuntimeError(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse("const Z = 0\n1 / Z")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	err := New(comp.Bytecode()).Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "integer division by zero") {
+		t.Fatalf("expected division by zero error, got %v", err)
+	}
+}
+
+func TestConstantReadableAsGlobal(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`const VERSION = "2.0"`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	value, err := vm.GetGlobal("VERSION")
+	if err != nil {
+		t.Fatalf("GetGlobal failed: %s", err)
+	}
+	testExpectedObject(t, "2.0", value)
+}
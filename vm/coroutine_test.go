@@ -0,0 +1,253 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+func TestCoroutineResume(t *testing.T) {
+	tests := []vmTestCase{
+		{"func g() { yield 1; yield 2 }; var co = g(); [co(), co()]", []int{1, 2}},
+		{"func g() { yield 1; return 5 }; var co = g(); co(); co()", 5},
+		{"func g() { yield 1 }; var co = g(); co(); co()", Null},
+		{"func g() { yield }; var co = g(); co()", Null},
+		{"func g() { yield 1 }; var co = g(); co(); done(co)", false},
+		{"func g() { yield 1 }; var co = g(); co(); co(); done(co)", true},
+		{"func g() { yield 1 }; typeof(g())", "coroutine"},
+		{"func g() { yield 1 }; g() is coroutine", true},
+		{"func g() { yield 1 }; typeof(g)", "CLOSURE"},
+		// The first resume starts the body; later values are the results of yield
+		{"func g() { var a = yield 1; var b = yield a + 1; return a + b }; var co = g(); [co(100), co(10), co(20)]", []int{1, 11, 30}},
+		// Arguments are bound when the coroutine is created
+		{"func g(a, b = 2, ...rest) { yield a + b + len(rest) }; g(1)() + g(1, 5, 0, 0)()", 11},
+		{"var n = 0; func g() { n++; yield n }; var co = g(); n", 0},
+		{"func counter(n) { for var i = 0; i < n; i++ { yield i } }; var co = counter(3); co(); co(); co()", 2},
+		// Each call creates an independent coroutine
+		{"func g() { var i = 0; for { i++; yield i } }; var a = g(); var b = g(); a(); a(); a() * 10 + b()", 31},
+		// Captured locals survive suspension
+		{"func g() { var x = 1; var inc = func() { x++ }; yield x; inc(); yield x }; var co = g(); co(); co()", 2},
+		{"var co = func() { yield 7 }(); co()", 7},
+		{"func g() { var f = func() { return 3 }; yield f() + 1 }; g()()", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCoroutineRange(t *testing.T) {
+	tests := []vmTestCase{
+		{"func g() { yield 1; yield 2; yield 3 }; var s = 0; for _, v := range g() { s += v }; s", 6},
+		{"func g() { yield 5; yield 6 }; var ks = []; for i, _ := range g() { push(ks, i) }; ks", []int{0, 1}},
+		{"func g() { yield 1; return 100 }; var s = 0; for _, v := range g() { s += v }; s", 1},
+		{"func g() { for { yield 1 } }; var s = 0; for _, v := range g() { s += v; if s == 4 { break } }; s", 4},
+		{"func g() { yield 1; yield 2 }; var co = g(); co(); var s = []; for _, v := range co { push(s, v) }; s", []int{2}},
+		{"func g() { yield 1 }; var co = g(); for _, v := range co {}; var n = 0; for _, v := range co { n++ }; n", 0},
+		{`
+func inner(n) { for var i = 0; i < n; i++ { yield i } }
+func outer() {
+	for _, x := range inner(2) { yield x + 10 }
+	yield 99
+}
+var s = []
+for _, v := range outer() { push(s, v) }
+s`, []int{10, 11, 99}},
+		{`
+func walk(xs) {
+	for _, x := range xs {
+		try {
+			if x < 0 { panic("negative") }
+			yield x * 2
+		} catch {
+			yield -1
+		}
+	}
+}
+var s = []
+for _, v := range walk([1, -5, 3]) { push(s, v) }
+s`, []int{2, -1, 6}},
+		{`
+func g() {
+	try {
+		yield 1
+		panic("late")
+	} catch (e) {
+		yield e["message"]
+	}
+}
+var s = ""
+for _, v := range g() { s = s + format("%v;", v) }
+s`, "1;late;"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCoroutineErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"func g() { yield 1 }; var co = g(); co(); co(); co()", "cannot resume dead coroutine"},
+		{"var co = null; func g() { co() ; yield 1 }; co = g(); co()", "cannot resume running coroutine"},
+		{"func g() { yield 1 }; g()(1, 2)", "wrong number of arguments to resume coroutine: want=0..1, got=2"},
+		{"func g(a) { yield a }; g()", "wrong number of arguments: want=1, got=0"},
+		{"func g() { yield 1; panic(\"boom\") }; var co = g(); co(); co()", "boom"},
+		{"done(1)", "argument to `done` must be coroutine, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCoroutineFailureKillsIt(t *testing.T) {
+	input := `
+func g() { yield 1; panic("boom"); yield 2 }
+var co = g()
+co()
+var caught = false
+try { co() } catch { caught = true }
+format("%v %v", caught, done(co))
+`
+	runVmTests(t, []vmTestCase{{input, "true true"}})
+}
+
+func TestResumeFromGo(t *testing.T) {
+	input := `
+func patrol(start) {
+	var pos = start
+	for {
+		var cmd = yield pos
+		if cmd == "stop" { return "stopped at " + format("%v", pos) }
+		pos += 1
+	}
+}
+`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	patrol, _ := vm.GetGlobal("patrol")
+	result, err := vm.Invoke(context.Background(), patrol, &object.Integer{Value: 10})
+	if err != nil {
+		t.Fatalf("Invoke patrol: %s", err)
+	}
+	co, ok := result.(*object.Coroutine)
+	if !ok {
+		t.Fatalf("Invoke of a generator returned %T, want *object.Coroutine", result)
+	}
+
+	for frame, want := range []int{10, 11, 12} {
+		value, err := vm.Resume(context.Background(), co, nil)
+		if err != nil {
+			t.Fatalf("frame %d: Resume: %s", frame, err)
+		}
+		testExpectedObject(t, want, value)
+		if co.Done() {
+			t.Fatalf("frame %d: coroutine finished early", frame)
+		}
+	}
+
+	value, err := vm.Resume(context.Background(), co, &object.String{Value: "stop"})
+	if err != nil {
+		t.Fatalf("Resume stop: %s", err)
+	}
+	testExpectedObject(t, "stopped at 12", value)
+	if !co.Done() {
+		t.Fatalf("coroutine should be done after returning")
+	}
+
+	_, err = vm.Resume(context.Background(), co, nil)
+	if err == nil || !strings.Contains(err.Error(), "cannot resume dead coroutine") {
+		t.Fatalf("expected dead coroutine error, got %v", err)
+	}
+}
+
+func TestResumeFromGoCatchesInsideCoroutine(t *testing.T) {
+	input := `
+func g() {
+	for {
+		try {
+			var v = yield "ready"
+			if v < 0 { panic("negative") }
+		} catch (e) {
+			yield e["message"]
+		}
+	}
+}
+var co = g()
+`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	global, _ := vm.GetGlobal("co")
+	co := global.(*object.Coroutine)
+
+	steps := []struct {
+		send object.Object
+		want string
+	}{
+		{nil, "ready"},
+		{&object.Integer{Value: 1}, "ready"},
+		{&object.Integer{Value: -1}, "negative"},
+		{nil, "ready"},
+	}
+	for i, step := range steps {
+		value, err := vm.Resume(context.Background(), co, step.send)
+		if err != nil {
+			t.Fatalf("step %d: Resume: %s", i, err)
+		}
+		testExpectedObject(t, step.want, value)
+	}
+}
+
+func TestResumeFromGoError(t *testing.T) {
+	input := `func g() { yield 1; panic("boom") }; var co = g()`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	global, _ := vm.GetGlobal("co")
+	co := global.(*object.Coroutine)
+
+	if _, err := vm.Resume(context.Background(), co, nil); err != nil {
+		t.Fatalf("first Resume: %s", err)
+	}
+	_, err := vm.Resume(context.Background(), co, nil)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if !co.Done() {
+		t.Fatalf("a coroutine that failed should be done")
+	}
+}
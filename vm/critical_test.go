@@ -33,6 +33,42 @@ func TestCriticalRuntimeErrors(t *testing.T) {
 			`testMultiReturn(1);`,
 			"wrong number of arguments",
 		},
+		{
+			`int("abc");`,
+			"cannot convert",
+		},
+		{
+			`floor("1.5");`,
+			"argument to `floor` must be number",
+		},
+		{
+			`int(0.0 / 0.0);`,
+			"argument to `int` out of INTEGER range, got NaN",
+		},
+		{
+			`int(1.0 / 0);`,
+			"argument to `int` out of INTEGER range, got +Inf",
+		},
+		{
+			`int(1e300);`,
+			"argument to `int` out of INTEGER range, got 1e+300",
+		},
+		{
+			`int("-1e300");`,
+			"argument to `int` out of INTEGER range, got -1e+300",
+		},
+		{
+			`round(1.0 / 0);`,
+			"argument to `round` out of INTEGER range, got +Inf",
+		},
+		{
+			`floor(-1.0 / 0);`,
+			"argument to `floor` out of INTEGER range, got -Inf",
+		},
+		{
+			`ceil(9223372036854775807.0);`,
+			"argument to `ceil` out of INTEGER range",
+		},
 	}
 
 	for _, tt := range tests {
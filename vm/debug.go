@@ -0,0 +1,257 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+// StepMode tells the VM how to continue after a Debugger stopped it.
+type StepMode int
+
+const (
+	Continue StepMode = iota // run to the next breakpoint
+	StepInto                 // stop at the next line, including lines of called functions
+	StepOver                 // stop at the next line of the current function or a caller
+	StepOut                  // stop at the next line once the current function returns
+)
+
+// StopReason says why the VM stopped.
+type StopReason int
+
+const (
+	StopBreakpoint StopReason = iota
+	StopStep
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopStep:
+		return "step"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(r))
+	}
+}
+
+// Debugger is notified when execution reaches a breakpoint or finishes a
+// step. OnStop is called on the goroutine running the script, which waits for
+// it to return how to continue. The VM is locked meanwhile, so OnStop must
+// inspect it through stop rather than through VM methods.
+type Debugger interface {
+	OnStop(stop *Stop) StepMode
+}
+
+// Stop describes where execution stopped: at the start of Line, before any
+// of its code has run.
+type Stop struct {
+	Reason StopReason
+	File   string
+	Line   int
+	Frames []DebugFrame // the active calls, innermost first
+
+	vm *VM
+}
+
+// Global returns the current value of a global of the main script.
+func (s *Stop) Global(name string) (object.Object, bool) {
+	if s.vm.symbolTable == nil {
+		return nil, false
+	}
+	symbol, ok := s.vm.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		return nil, false
+	}
+	value := s.vm.globals[symbol.Index]
+	if value == nil {
+		value = Null
+	}
+	return value, true
+}
+
+// DebugFrame is an active call.
+type DebugFrame struct {
+	Function string
+	File     string
+	Line     int
+	Locals   []Variable // parameters first, then locals in declaration order
+	Free     []Variable // variables captured from enclosing functions
+}
+
+// Variable is a named value in a DebugFrame.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+type breakpoint struct {
+	file string
+	line int
+}
+
+// debugState is the stepping state of an attached Debugger.
+type debugState struct {
+	mode  StepMode
+	depth int // framesIndex when the step started
+}
+
+// WithDebugger attaches d, which is told about breakpoints and steps.
+func WithDebugger(d Debugger) Option {
+	return func(vm *VM) {
+		vm.debugger = d
+	}
+}
+
+// SetDebugger attaches d, or detaches the debugger when d is nil. Without a
+// debugger, breakpoints are ignored and execution pays no debugging cost.
+func (vm *VM) SetDebugger(d Debugger) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.debugger = d
+	vm.debug = debugState{}
+}
+
+// SetBreakpoint stops execution when it reaches line of file, the name given
+// by compiler.WithFileName for the main script or the import path of a
+// module. The line is resolved through the source maps of the loaded
+// functions: if it has no code, the breakpoint moves to the next line that
+// has. SetBreakpoint returns the line used, or an error if there is no code
+// at or after line.
+func (vm *VM) SetBreakpoint(file string, line int) (int, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	resolved := 0
+	for _, fn := range vm.functions() {
+		if fileNameOf(fn) != file {
+			continue
+		}
+		for _, l := range fn.SourceMap {
+			if l >= line && (resolved == 0 || l < resolved) {
+				resolved = l
+			}
+		}
+	}
+	if resolved == 0 {
+		return 0, fmt.Errorf("no code at or after %s:%d", file, line)
+	}
+
+	if vm.breakpoints == nil {
+		vm.breakpoints = make(map[breakpoint]bool)
+	}
+	vm.breakpoints[breakpoint{file, resolved}] = true
+	return resolved, nil
+}
+
+// ClearBreakpoint removes the breakpoint at line of file.
+func (vm *VM) ClearBreakpoint(file string, line int) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	delete(vm.breakpoints, breakpoint{file, line})
+}
+
+// Breakpoints lists the breakpoints as "file:line", sorted.
+func (vm *VM) Breakpoints() []string {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	list := make([]string, 0, len(vm.breakpoints))
+	for bp := range vm.breakpoints {
+		list = append(list, fmt.Sprintf("%s:%d", bp.file, bp.line))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// functions returns the main function and every compiled function in the
+// constant pool.
+func (vm *VM) functions() []*object.CompiledFunction {
+	fns := []*object.CompiledFunction{vm.main}
+	for _, constant := range vm.constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fns = append(fns, fn)
+		}
+	}
+	return fns
+}
+
+// debugHook runs before each instruction while a debugger is attached. It
+// stops when the instruction starts a new visit of a line: a different line
+// of the frame, or a jump back to the line the frame is on.
+func (vm *VM) debugHook() {
+	frame := vm.currentFrame()
+	fn := frame.cl.Fn
+	line, ok := fn.SourceMap[frame.ip]
+	if !ok {
+		return
+	}
+
+	revisit := line == frame.debugLine && frame.ip > frame.debugIP
+	frame.debugLine, frame.debugIP = line, frame.ip
+	if revisit {
+		return
+	}
+
+	file := fileNameOf(fn)
+	reason := StopStep
+	switch {
+	case vm.breakpoints[breakpoint{file, line}]:
+		reason = StopBreakpoint
+	case vm.debug.mode == StepInto:
+	case vm.debug.mode == StepOver && vm.framesIndex <= vm.debug.depth:
+	case vm.debug.mode == StepOut && vm.framesIndex < vm.debug.depth:
+	default:
+		return
+	}
+
+	stop := &Stop{Reason: reason, File: file, Line: line, Frames: vm.debugFrames(), vm: vm}
+	vm.debug = debugState{mode: vm.debugger.OnStop(stop), depth: vm.framesIndex}
+}
+
+// debugFrames describes the active calls, innermost first.
+func (vm *VM) debugFrames() []DebugFrame {
+	var frames []DebugFrame
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		if frame == nil || frame.cl == nil {
+			continue
+		}
+		fn := frame.cl.Fn
+
+		info := DebugFrame{
+			Function: functionName(fn),
+			File:     fileNameOf(fn),
+			Line:     translateIPToLine(fn.SourceMap, frame.ip),
+		}
+
+		for slot, name := range fn.LocalNames {
+			if strings.HasPrefix(name, "$") {
+				continue
+			}
+			info.Locals = append(info.Locals, Variable{Name: name, Value: debugValue(vm.stack[frame.basePointer+slot])})
+		}
+		for j, name := range fn.FreeNames {
+			if j < len(frame.cl.Free) {
+				info.Free = append(info.Free, Variable{Name: name, Value: debugValue(frame.cl.Free[j])})
+			}
+		}
+
+		frames = append(frames, info)
+	}
+	return frames
+}
+
+// debugValue unwraps the cell of a captured variable.
+func debugValue(value object.Object) object.Object {
+	if cell, ok := value.(*object.Cell); ok {
+		value = cell.Value
+	}
+	if value == nil {
+		return Null
+	}
+	return value
+}
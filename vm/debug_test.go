@@ -0,0 +1,258 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+// recordingDebugger records every stop and answers with the next of its
+// modes, then Continue.
+type recordingDebugger struct {
+	modes []StepMode
+	stops []*Stop
+}
+
+func (d *recordingDebugger) OnStop(stop *Stop) StepMode {
+	d.stops = append(d.stops, stop)
+	if len(d.modes) == 0 {
+		return Continue
+	}
+	mode := d.modes[0]
+	d.modes = d.modes[1:]
+	return mode
+}
+
+func (d *recordingDebugger) lines() []int {
+	lines := make([]int, len(d.stops))
+	for i, stop := range d.stops {
+		lines[i] = stop.Line
+	}
+	return lines
+}
+
+func variables(vars []Variable) string {
+	s := ""
+	for i, v := range vars {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%s=%s", v.Name, v.Value.Inspect())
+	}
+	return s
+}
+
+const debugInput = `func add(a, b) {
+	var c = a + b
+	return c
+}
+
+var x = add(1, 2)
+var y = add(x, 3)
+`
+
+func TestDebuggerBreakpoint(t *testing.T) {
+	d := &recordingDebugger{}
+	vm := New(compileInput(t, debugInput), WithDebugger(d))
+
+	line, err := vm.SetBreakpoint("script.ice", 3)
+	if err != nil || line != 3 {
+		t.Fatalf("SetBreakpoint: got %d, %v", line, err)
+	}
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if len(d.stops) != 2 {
+		t.Fatalf("wrong number of stops. want=2, got=%d", len(d.stops))
+	}
+
+	stop := d.stops[1]
+	if stop.Reason != StopBreakpoint || stop.File != "script.ice" || stop.Line != 3 {
+		t.Errorf("wrong stop. got=%s at %s:%d", stop.Reason, stop.File, stop.Line)
+	}
+	if len(stop.Frames) != 2 {
+		t.Fatalf("wrong number of frames. want=2, got=%d", len(stop.Frames))
+	}
+
+	inner := stop.Frames[0]
+	if inner.Function != "add" || inner.Line != 3 {
+		t.Errorf("wrong inner frame. got=%s line %d", inner.Function, inner.Line)
+	}
+	if got := variables(inner.Locals); got != "a=3 b=3 c=6" {
+		t.Errorf("wrong locals. got=%q", got)
+	}
+	if outer := stop.Frames[1]; outer.Function != "main" || outer.Line != 7 {
+		t.Errorf("wrong outer frame. got=%s line %d", outer.Function, outer.Line)
+	}
+
+	if x, ok := stop.Global("x"); !ok || x.Inspect() != "3" {
+		t.Errorf("wrong global x. got=%v, %t", x, ok)
+	}
+	if _, ok := stop.Global("nope"); ok {
+		t.Errorf("expected no global nope")
+	}
+}
+
+func TestDebuggerUnassignedLocalsAreNull(t *testing.T) {
+	d := &recordingDebugger{}
+	vm := New(compileInput(t, "func f(a) {\n\tvar b = a\n\tvar c = b\n\treturn c\n}\nf(1)\nf(2)"), WithDebugger(d))
+
+	if _, err := vm.SetBreakpoint("script.ice", 2); err != nil {
+		t.Fatalf("SetBreakpoint: %s", err)
+	}
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	want := []string{"a=1 b=null c=null", "a=2 b=null c=null"}
+	for i, stop := range d.stops {
+		if got := variables(stop.Frames[0].Locals); got != want[i] {
+			t.Errorf("stop %d: wrong locals. want=%q, got=%q", i, want[i], got)
+		}
+	}
+}
+
+func TestSetBreakpointResolvesLine(t *testing.T) {
+	vm := New(compileInput(t, debugInput))
+
+	line, err := vm.SetBreakpoint("script.ice", 5)
+	if err != nil || line != 6 {
+		t.Errorf("blank line should resolve to 6. got %d, %v", line, err)
+	}
+
+	if _, err := vm.SetBreakpoint("script.ice", 20); err == nil || err.Error() != "no code at or after script.ice:20" {
+		t.Errorf("wrong error past the end. got=%v", err)
+	}
+	if _, err := vm.SetBreakpoint("other.ice", 1); err == nil {
+		t.Errorf("expected an error for an unknown file")
+	}
+
+	if _, err := vm.SetBreakpoint("script.ice", 2); err != nil {
+		t.Fatalf("SetBreakpoint: %s", err)
+	}
+	if got := vm.Breakpoints(); !reflect.DeepEqual(got, []string{"script.ice:2", "script.ice:6"}) {
+		t.Errorf("wrong breakpoints. got=%v", got)
+	}
+	vm.ClearBreakpoint("script.ice", 6)
+	if got := vm.Breakpoints(); !reflect.DeepEqual(got, []string{"script.ice:2"}) {
+		t.Errorf("wrong breakpoints after clear. got=%v", got)
+	}
+}
+
+func TestDebuggerStepping(t *testing.T) {
+	tests := []struct {
+		name  string
+		modes []StepMode
+		want  []int
+	}{
+		{"continue", nil, []int{6}},
+		{"step into", []StepMode{StepInto, StepInto, StepInto, StepInto}, []int{6, 2, 3, 7, 2}},
+		{"step over", []StepMode{StepOver, StepOver}, []int{6, 7}},
+		{"step out", []StepMode{StepInto, StepOut, StepInto}, []int{6, 2, 7, 2}},
+	}
+
+	for _, tt := range tests {
+		d := &recordingDebugger{modes: tt.modes}
+		vm := New(compileInput(t, debugInput), WithDebugger(d))
+		if _, err := vm.SetBreakpoint("script.ice", 6); err != nil {
+			t.Fatalf("SetBreakpoint: %s", err)
+		}
+		if err := vm.Run(context.Background()); err != nil {
+			t.Fatalf("%s: vm error: %s", tt.name, err)
+		}
+
+		if got := d.lines(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: wrong stops. want=%v, got=%v", tt.name, tt.want, got)
+		}
+		for i, stop := range d.stops {
+			if i > 0 && stop.Reason != StopStep {
+				t.Errorf("%s: stop %d should be a step, got %s", tt.name, i, stop.Reason)
+			}
+		}
+	}
+}
+
+func TestDebuggerFreeVariables(t *testing.T) {
+	input := `func counter() {
+	var n = 10
+	return func() {
+		n++
+		return n
+	}
+}
+var next = counter()
+next()
+`
+	d := &recordingDebugger{}
+	vm := New(compileInput(t, input), WithDebugger(d))
+	if _, err := vm.SetBreakpoint("script.ice", 5); err != nil {
+		t.Fatalf("SetBreakpoint: %s", err)
+	}
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if len(d.stops) != 1 {
+		t.Fatalf("wrong number of stops. want=1, got=%d", len(d.stops))
+	}
+	frame := d.stops[0].Frames[0]
+	if frame.Function != "<anonymous>" {
+		t.Errorf("wrong function. got=%s", frame.Function)
+	}
+	if got := variables(frame.Free); got != "n=11" {
+		t.Errorf("wrong free variables. got=%q", got)
+	}
+}
+
+func TestDebuggerModuleBreakpoint(t *testing.T) {
+	loader := compiler.ModuleLoaderFunc(func(path string) (string, error) {
+		return testModules[path], nil
+	})
+	comp := compiler.New(compiler.WithModuleLoader(loader))
+	if err := comp.Compile(parse("import \"utils\"\nutils.Clamp(20, 0, 5)")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	d := &recordingDebugger{}
+	vm := New(comp.Bytecode(), WithDebugger(d))
+	if _, err := vm.SetBreakpoint("utils", 4); err != nil {
+		t.Fatalf("SetBreakpoint: %s", err)
+	}
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if len(d.stops) != 1 {
+		t.Fatalf("wrong number of stops. want=1, got=%d", len(d.stops))
+	}
+	stop := d.stops[0]
+	if stop.File != "utils" || stop.Frames[0].Function != "Clamp" {
+		t.Errorf("wrong stop. got=%s in %s", stop.File, stop.Frames[0].Function)
+	}
+	if got := variables(stop.Frames[0].Locals); got != "v=20 lo=0 hi=5" {
+		t.Errorf("wrong locals. got=%q", got)
+	}
+}
+
+func TestBreakpointsIgnoredWithoutDebugger(t *testing.T) {
+	d := &recordingDebugger{}
+	vm := New(compileInput(t, debugInput), WithDebugger(d))
+	if _, err := vm.SetBreakpoint("script.ice", 2); err != nil {
+		t.Fatalf("SetBreakpoint: %s", err)
+	}
+	vm.SetDebugger(nil)
+
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if len(d.stops) != 0 {
+		t.Errorf("detached debugger was called %d times", len(d.stops))
+	}
+	if y, _ := vm.GetGlobal("y"); y == nil || y.Inspect() != "6" {
+		t.Errorf("wrong y. got=%v", y)
+	}
+}
@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+func TestEnums(t *testing.T) {
+	tests := []vmTestCase{
+		{"enum State { Idle, Walk, Attack }; [State.Idle, State.Walk, State.Attack]", []int{0, 1, 2}},
+		{"enum Flags { A = 1, B = 1 << 1, C = 1 << 2, D }; [Flags.A, Flags.B, Flags.C, Flags.D]", []int{1, 2, 4, 5}},
+		{"enum E { A = -2, B, C = 10, D }; [E.A, E.B, E.C, E.D]", []int{-2, -1, 10, 11}},
+		{"enum Flags { A = 1, B = 2 }; Flags.A | Flags.B", 3},
+		{"enum State { Idle, Walk }; State.Walk == 1", true},
+		{"enum State { Idle, Walk, Attack }; State.name(2)", "Attack"},
+		{"enum State { Idle, Walk }; var s = State.Walk; State.name(s)", "Walk"},
+		{"enum State { Idle, Walk }; State.name(7)", Null},
+		{"enum E { A = 1, B = 1 }; E.name(1)", "A"},
+		{"enum E { A = 3, B = 1 }; E.values()", []int{3, 1}},
+		{"enum State { Idle, Walk }; typeof(State)", "enum"},
+		{"enum State { Idle, Walk }; typeof(State.Idle)", "integer"},
+		{"enum State { Idle, Walk }; format(\"%v\", State)", "enum State { Idle, Walk }"},
+		// Dynamic access goes through the enum at runtime
+		{"enum State { Idle, Walk }; var S = State; S.Walk + S.values()[0]", 1},
+		// Enums are hoisted like functions
+		{"func start() { return State.Walk }; enum State { Idle, Walk }; start()", 1},
+		{"func f() { enum Local { X = 4, Y }; return Local.Y }; f()", 5},
+		{"enum State { Idle, Walk }; const Default = State.Walk; Default", 1},
+		{`
+enum State { Idle, Walk, Attack }
+func act(s) {
+	switch s {
+	case State.Idle: return "rest"
+	case State.Walk, State.Attack: return "move"
+	default: return "?"
+	}
+}
+act(State.Idle) + "," + act(State.Walk) + "," + act(State.Attack) + "," + act(9)`, "rest,move,move,?"},
+		{"enum State { Idle, Walk }; var s = 0; for _, v := range State.values() { s += v + 1 }; s", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestEnumErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum State { Idle }; var S = State; S.Idle = 3", "cannot assign to State.Idle: enum members are constant"},
+		{`enum State { Idle }; State.name("Idle")`, "argument to `State.name` must be INTEGER, got STRING"},
+		{"enum State { Idle }; State.values(1)", "wrong number of arguments. got=1, want=0"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestEnumFromModule(t *testing.T) {
+	tests := []vmTestCase{
+		{`import "shapes"; shapes.Dir.South`, 2},
+		{`import "shapes"; shapes.Dir.name(3)`, "West"},
+		{`import "shapes"; switch 1 { case shapes.Dir.East: "east" default: "other" }`, "east"},
+	}
+
+	for _, tt := range tests {
+		vm, err := runWithModules(t, tt.input)
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestEnumFromGo(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse("enum State { Idle, Walk = 10, Attack }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	global, err := vm.GetGlobal("State")
+	if err != nil {
+		t.Fatalf("GetGlobal State: %s", err)
+	}
+	state, ok := global.(*object.Enum)
+	if !ok {
+		t.Fatalf("State is %T, want *object.Enum", global)
+	}
+
+	if v, ok := state.Value("Attack"); !ok || v != 11 {
+		t.Errorf("wrong value for Attack. got=%d, %t", v, ok)
+	}
+	if name, ok := state.NameOf(10); !ok || name != "Walk" {
+		t.Errorf("wrong name for 10. got=%q, %t", name, ok)
+	}
+	if _, ok := state.NameOf(3); ok {
+		t.Errorf("NameOf(3) should not match a member")
+	}
+}
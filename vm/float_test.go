@@ -0,0 +1,62 @@
+package vm
+
+import "testing"
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.0 + 2.0", 3.0},
+		{"3.5 * 2", 7.0},
+		{"2 * 3.5", 7.0},
+		{"10 - 2.5", 7.5},
+		{"7.5 / 2.5", 3.0},
+		{"1 / 2.0", 0.5},
+		{"5.5 % 2", 1.5},
+		{"-1.5 + 1", -0.5},
+		{"var v = 2.0; var dt = 0.5; v * dt", 1.0},
+		{"7 / 2", 3}, // integer division is unchanged
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMixedNumericComparison(t *testing.T) {
+	tests := []vmTestCase{
+		{"1 < 1.5", true},
+		{"1.5 < 1", false},
+		{"2 > 1.5", true},
+		{"1.5 > 2", false},
+		{"2 >= 2.0", true},
+		{"2.0 <= 1", false},
+		{"1 == 1.0", true},
+		{"1 != 1.5", true},
+		{"0.5 < 0.75", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestNumericConversionBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(7)", 7},
+		{`int("42")`, 42},
+		{`int("2.5")`, 2},
+		{"int(true)", 1},
+		{"float(3)", 3.0},
+		{`float("0.25")`, 0.25},
+		{"round(2.5)", 3},
+		{"round(2.4)", 2},
+		{"round(-2.5)", -3},
+		{"floor(2.7)", 2},
+		{"floor(-2.1)", -3},
+		{"ceil(2.1)", 3},
+		{"ceil(5)", 5},
+		{"int(3.5 * 2) + 1", 8},
+		// The ends of the INTEGER range still convert
+		{"int(-9223372036854775808.0)", -9223372036854775808},
+		{"floor(9223372036854774784.5)", 9223372036854774784},
+	}
+
+	runVmTests(t, tests)
+}
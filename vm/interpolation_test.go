@@ -0,0 +1,81 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []vmTestCase{
+		{`var e = {"hp": 10}; var max = 20; "hp: ${e["hp"]}/${max}"`, "hp: 10/20"},
+		{`"${1} ${1.5} ${true} ${null}"`, "1 1.500000 true null"},
+		{`"${[1, 2]}"`, "[1, 2]"},
+		{`var name = "orc"; "${name}!"`, "orc!"},
+		{`"100% done in ${3}s"`, "100% done in 3s"},
+		{`var n = 2; "${n} x ${n} = ${n * n}"`, "2 x 2 = 4"},
+		{`var x = 1; "outer ${"inner ${x + 1}"}"`, "outer inner 2"},
+		{`func f(a) { return "<${a}>" }; f("x") + f(5)`, "<x><5>"},
+		{`"${ {"k": "v"}["k"] }"`, "v"},
+		{`"literal \${x}"`, "literal ${x}"},
+		{"`raw ${x}\\n`", "raw ${x}\\n"},
+		{"`line1\nline2`", "line1\nline2"},
+		{`"café \x41"`, "café A"},
+		{`len("é")`, 1},
+		{"var total = 3; var s = \"a ${\n  total\n} b\"; s", "a 3 b"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestInterpolationMatchesPrint(t *testing.T) {
+	input := `
+var x = 2.0
+var items = [0.5, "a", {"k": 1.5}]
+print(x, items)
+"${x} ${items}"
+`
+	var out bytes.Buffer
+	vm := New(compileInput(t, input))
+	vm.SetOutput(&out)
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	printed := strings.TrimSuffix(out.String(), "\n")
+	if printed != "2.000000 [0.500000, a, {k: 1.500000}]" {
+		t.Errorf("unexpected print output %q", printed)
+	}
+	testExpectedObject(t, printed, vm.LastPoppedStackElem())
+}
+
+func TestInterpolationIgnoresShadowedFormat(t *testing.T) {
+	tests := []vmTestCase{
+		{`func show(format, x) { return "x=${x}" }; show("json", 1)`, "x=1"},
+		{`func show(x) { var format = "json"; return "${format}: ${x}" }; show(2)`, "json: 2"},
+		{`format := "json"; var x = 3; "${format} ${x}"`, "json 3"},
+		{`var format = func(a) { return "shadowed" }; "${1}"`, "1"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestInterpolationErrorLine(t *testing.T) {
+	input := "var x = 1\nvar s = \"ok ${\n\n  x / 0\n}\""
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	err := New(comp.Bytecode()).Run(context.Background())
+	if err == nil {
+		t.Fatalf("expected runtime error")
+	}
+	if !strings.Contains(err.Error(), "script.ice:4") {
+		t.Errorf("expected error on line 4, got %q", err.Error())
+	}
+}
@@ -0,0 +1,76 @@
+package vm
+
+import "fmt"
+
+// Approximate sizes charged to the allocation budget. Strings are charged one
+// byte per byte.
+const (
+	arrayElementSize = 16 // an interface value
+	hashPairSize     = 64 // key, value and map overhead
+)
+
+// WithMaxArrayLength limits the number of elements in an array built or grown
+// by a script. A limit of 0 means no limit.
+func WithMaxArrayLength(n int64) Option {
+	return func(vm *VM) {
+		vm.maxArrayLength = n
+	}
+}
+
+// WithMaxHashSize limits the number of pairs in a hash built or grown by a
+// script. A limit of 0 means no limit.
+func WithMaxHashSize(n int64) Option {
+	return func(vm *VM) {
+		vm.maxHashSize = n
+	}
+}
+
+// WithMaxStringLength limits the length in bytes of a string built by a
+// script. A limit of 0 means no limit.
+func WithMaxStringLength(n int64) Option {
+	return func(vm *VM) {
+		vm.maxStringLength = n
+	}
+}
+
+// WithAllocationBudget limits the approximate number of bytes each Run,
+// Invoke and Resume may allocate for arrays, hashes and strings. Memory is
+// charged when it is allocated and not returned when it becomes garbage. A
+// budget of 0 means no limit.
+func WithAllocationBudget(bytes int64) Option {
+	return func(vm *VM) {
+		vm.allocBudget = bytes
+	}
+}
+
+// AllocArray implements object.Limiter.
+func (vm *VM) AllocArray(length, added int64) error {
+	if vm.maxArrayLength > 0 && length > vm.maxArrayLength {
+		return fmt.Errorf("array length %d exceeds the limit of %d", length, vm.maxArrayLength)
+	}
+	return vm.allocate(added * arrayElementSize)
+}
+
+// AllocHash implements object.Limiter.
+func (vm *VM) AllocHash(size, added int64) error {
+	if vm.maxHashSize > 0 && size > vm.maxHashSize {
+		return fmt.Errorf("hash size %d exceeds the limit of %d", size, vm.maxHashSize)
+	}
+	return vm.allocate(added * hashPairSize)
+}
+
+// AllocString implements object.Limiter.
+func (vm *VM) AllocString(length int64) error {
+	if vm.maxStringLength > 0 && length > vm.maxStringLength {
+		return fmt.Errorf("string length %d exceeds the limit of %d", length, vm.maxStringLength)
+	}
+	return vm.allocate(length)
+}
+
+func (vm *VM) allocate(bytes int64) error {
+	vm.stats.Allocated += bytes
+	if vm.allocBudget > 0 && vm.stats.Allocated > vm.allocBudget {
+		return fmt.Errorf("allocation budget of %d bytes exceeded", vm.allocBudget)
+	}
+	return nil
+}
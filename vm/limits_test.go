@@ -0,0 +1,137 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/token"
+)
+
+func TestMemoryLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{"var a = []; for { push(a, 1) }", []Option{WithMaxArrayLength(100)}, "array length 101 exceeds the limit of 100"},
+		{"var a = [1]; push(a, 0, 1000000000000)", []Option{WithMaxArrayLength(100)}, "array length 1000000000001 exceeds the limit of 100"},
+		{"[1, 2, 3]", []Option{WithMaxArrayLength(2)}, "array length 3 exceeds the limit of 2"},
+		// The literal uses 64 bytes of the budget and the slice another 48
+		{"var a = [1, 2, 3, 4]; a[1:]", []Option{WithAllocationBudget(100)}, "allocation budget of 100 bytes exceeded"},
+		{"var m = {}; var i = 0; for { m[i] = i; i++ }", []Option{WithMaxHashSize(10)}, "hash size 11 exceeds the limit of 10"},
+		{"var m = {}; var i = 0; for { set(m, i, i); i++ }", []Option{WithMaxHashSize(10)}, "hash size 11 exceeds the limit of 10"},
+		{`var m = {}; var i = 0; for { m.x = i; m["k${i}"] = i; i++ }`, []Option{WithMaxHashSize(5)}, "hash size 6 exceeds the limit of 5"},
+		{`{"a": 1, "b": 2}`, []Option{WithMaxHashSize(1)}, "hash size 2 exceeds the limit of 1"},
+		{`var s = "ab"; for { s = s + s }`, []Option{WithMaxStringLength(1000)}, "string length 1024 exceeds the limit of 1000"},
+		{`var s = "ab"; for { s = "${s}${s}" }`, []Option{WithMaxStringLength(1000)}, "string length 1024 exceeds the limit of 1000"},
+		{`repeat("ab", 1000000000000000)`, []Option{WithMaxStringLength(1000)}, "string length 2000000000000000 exceeds the limit of 1000"},
+		{`repeat("ab", 5000000000000000000)`, []Option{WithMaxStringLength(1000)}, "exceeds the limit of 1000"},
+		{`join(["aaa", "bbb"], ",")`, []Option{WithMaxStringLength(5)}, "string length 7 exceeds the limit of 5"},
+		{`join(["a", "b", "c"], repeat(",", 600))`, []Option{WithMaxStringLength(1000)}, "string length 1203 exceeds the limit of 1000"},
+		// An empty pattern matches before each rune and at the end
+		{`replace("abc", "", repeat("x", 500))`, []Option{WithMaxStringLength(1000)}, "string length 2003 exceeds the limit of 1000"},
+		// format charges an upper bound on its result
+		{`format("%1000000d", 1)`, []Option{WithMaxStringLength(1000)}, "exceeds the limit of 1000"},
+		{`format("%[1]s%[1]s%[1]s", repeat("x", 400))`, []Option{WithMaxStringLength(1000)}, "exceeds the limit of 1000"},
+		{`sprintf("%2000s", "x")`, []Option{WithMaxStringLength(1000)}, "exceeds the limit of 1000"},
+		{"var keep = []; for { push(keep, [1, 2, 3, 4]) }", []Option{WithAllocationBudget(10000)}, "allocation budget of 10000 bytes exceeded"},
+		{`split("a,b,c", ",")`, []Option{WithMaxArrayLength(2)}, "array length 3 exceeds the limit of 2"},
+		{`runes("héllo")`, []Option{WithMaxArrayLength(4)}, "array length 5 exceeds the limit of 4"},
+		{`keys({"a": 1, "b": 2, "c": 3})`, []Option{WithMaxArrayLength(2)}, "array length 3 exceeds the limit of 2"},
+		{"enum E { A, B, C }; E.values()", []Option{WithMaxArrayLength(2)}, "array length 3 exceeds the limit of 2"},
+		{"func f(...xs) { return xs }; f(1, 2, 3)", []Option{WithMaxArrayLength(2)}, "array length 3 exceeds the limit of 2"},
+		{"func g(a, b, c, d) { return a }; var xs = [1, 2]; g(...xs, ...xs)", []Option{WithMaxArrayLength(3)}, "array length 4 exceeds the limit of 3"},
+		{`var s = repeat("ab", 3); upper(s)`, []Option{WithMaxStringLength(6), WithAllocationBudget(10)}, "allocation budget of 10 bytes exceeded"},
+		{`var s = repeat("ab", 3); lower(s)`, []Option{WithMaxStringLength(6), WithAllocationBudget(10)}, "allocation budget of 10 bytes exceeded"},
+		{`var s = repeat("ab", 3); trim(s, "x")`, []Option{WithMaxStringLength(6), WithAllocationBudget(10)}, "allocation budget of 10 bytes exceeded"},
+		{`var s = repeat(" ab", 3); trim(s)`, []Option{WithMaxStringLength(9), WithAllocationBudget(15)}, "allocation budget of 15 bytes exceeded"},
+	}
+
+	for _, tt := range tests {
+		vm := New(compileInput(t, tt.input), tt.opts...)
+		err := vm.Run(context.Background())
+
+		var scriptErr *token.ScriptError
+		if !errors.As(err, &scriptErr) {
+			t.Errorf("%q: expected *token.ScriptError, got %T: %v", tt.input, err, err)
+			continue
+		}
+		if !strings.Contains(scriptErr.Message, tt.expected) {
+			t.Errorf("%q: expected error %q, got %q", tt.input, tt.expected, scriptErr.Message)
+		}
+	}
+}
+
+func TestMemoryLimitsAllowSmallScripts(t *testing.T) {
+	input := `
+var a = [1, 2, 3]
+push(a, 4)
+var m = {"a": 1}
+m["a"] = 2
+m["b"] = 3
+set(m, "b", 4)
+var s = "x" + "y"
+format("%v %v %v", len(a), m["b"], s)
+`
+	vm := New(compileInput(t, input),
+		WithMaxArrayLength(4),
+		WithMaxHashSize(2),
+		WithMaxStringLength(16),
+		WithAllocationBudget(1000),
+	)
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, "4 4 xy", vm.LastPoppedStackElem())
+}
+
+func TestAllocationBudgetPerInvoke(t *testing.T) {
+	input := `
+func tick() {
+	var a = []
+	push(a, 0, 10)
+	return len(a)
+}
+`
+	vm := New(compileInput(t, input), WithAllocationBudget(200))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	tick, _ := vm.GetGlobal("tick")
+
+	for i := 0; i < 5; i++ {
+		result, err := vm.Invoke(context.Background(), tick)
+		if err != nil {
+			t.Fatalf("Invoke %d: %s", i, err)
+		}
+		testExpectedObject(t, 10, result)
+		if got := vm.Stats().Allocated; got != 10*arrayElementSize {
+			t.Errorf("Invoke %d: wrong allocation. got=%d", i, got)
+		}
+	}
+}
+
+func TestMemoryLimitCatchableByScript(t *testing.T) {
+	input := `
+var big = []
+var message = ""
+try {
+	push(big, 0, 1000)
+} catch (e) {
+	message = e["message"]
+}
+message
+`
+	vm := New(compileInput(t, input), WithMaxArrayLength(10))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, "array length 1000 exceeds the limit of 10", vm.LastPoppedStackElem())
+}
+
+func TestVMImplementsLimiter(t *testing.T) {
+	var _ object.Limiter = New(compileInput(t, "1"))
+}
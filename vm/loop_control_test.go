@@ -0,0 +1,156 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+func TestBreakContinue(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var sum = 0
+			for i := 0; i < 10; i = i + 1 {
+				if i == 5 { break }
+				sum = sum + i
+			}
+			sum
+			`,
+			10,
+		},
+		{
+			`
+			var sum = 0
+			for i := 0; i < 10; i = i + 1 {
+				if i % 2 == 0 { continue }
+				sum = sum + i
+			}
+			sum
+			`,
+			25,
+		},
+		{
+			`
+			var n = 0
+			for {
+				n = n + 1
+				if n > 3 { break }
+			}
+			n
+			`,
+			4,
+		},
+		{
+			`
+			var i = 0
+			var hits = 0
+			for i < 10 {
+				i = i + 1
+				if i < 8 { continue }
+				hits = hits + 1
+			}
+			hits
+			`,
+			3,
+		},
+		{
+			`
+			func find(arr, target) {
+				var found = -1
+				for i := 0; i < len(arr); i = i + 1 {
+					if arr[i] == target {
+						found = i
+						break
+					}
+				}
+				return found
+			}
+			find([4, 5, 6], 6)
+			`,
+			2,
+		},
+		{
+			`
+			func apply(f) { return f() }
+			apply(func() {
+				var x = 0
+				for i := 0; i < 5; i = i + 1 {
+					if i == 2 {
+						x = x + 1
+						continue
+						x = x + 100
+					}
+					x = x + 10
+					if i == 3 {
+						break
+						x = x + 1000
+					}
+				}
+				return x
+			})
+			`,
+			31,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLabeledBreakContinue(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var count = 0
+			outer: for i := 0; i < 5; i = i + 1 {
+				for j := 0; j < 5; j = j + 1 {
+					if j == 2 { continue outer }
+					if i == 3 { break outer }
+					count = count + 1
+				}
+			}
+			count
+			`,
+			6,
+		},
+		{
+			`
+			var count = 0
+			outer: for i := 0; i < 3; i = i + 1 {
+				for j := 0; j < 3; j = j + 1 {
+					if j == 1 { break }
+					count = count + 1
+				}
+			}
+			count
+			`,
+			3,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBreakContinueCompileErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		errContains string
+	}{
+		{`break`, "break statement outside of loop"},
+		{`continue`, "continue statement outside of loop"},
+		{`for { func() { break } }`, "break statement outside of loop"},
+		{`for { break missing }`, "break label not defined: missing"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err == nil {
+			t.Errorf("expected compile error containing %q, got nil", tt.errContains)
+		} else if !strings.Contains(err.Error(), tt.errContains) {
+			t.Errorf("expected compile error containing %q, got %q", tt.errContains, err.Error())
+		}
+	}
+}
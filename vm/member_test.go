@@ -0,0 +1,164 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+func TestHashMemberAccess(t *testing.T) {
+	tests := []vmTestCase{
+		{`var user = {"name": "Alice", "id": 123}; user.id`, 123},
+		{`var user = {"name": "Alice"}; user.name`, "Alice"},
+		{`var user = {"name": "Alice"}; user.missing`, Null},
+		{`var user = {}; user.id = 7; user["id"]`, 7},
+		{`var user = {}; user.id = 7`, 7},
+		{`var p = {"pos": {"x": 1, "y": 2}}; p.pos.x = 10; p.pos.x + p.pos.y`, 12},
+		{`var items = [{"v": 3}, {"v": 4}]; items[1].v`, 4},
+		{`var m = {"double": func(x) { return x * 2 }}; m.double(21)`, 42},
+		{
+			`
+			func makeCounter() {
+				var c = {"n": 0}
+				c.inc = func() { c.n = c.n + 1; return c.n }
+				return c
+			}
+			var counter = makeCounter()
+			counter.inc()
+			counter.inc()
+			`,
+			2,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestReservedWordMembers(t *testing.T) {
+	tests := []vmTestCase{
+		{`var e = {"type": "orc"}; e.type`, "orc"},
+		{`var opts = {}; opts.default = 3; opts.default`, 3},
+		{`var m = {"import": 1, "yield": 2}; m.import + m.yield`, 3},
+		{`var x = {"case": func() { return 7 }}; x.case()`, 7},
+		{`type T = struct { type, default }; var t = T(1, 2); t.type + t.default`, 3},
+		{`enum E { default, case }; E.case`, 1},
+		// Without a name after it, type is an ordinary variable
+		{`type := 5; type + 1`, 6},
+		{`var type = 2; type = type * 3; type`, 6},
+		{`func f(type) { return type }; f(4)`, 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+type testEntity struct {
+	hp int64
+}
+
+func (e *testEntity) GetAttr(name string) (object.Object, bool) {
+	switch name {
+	case "hp":
+		return &object.Integer{Value: e.hp}, true
+	case "damage":
+		return &object.Builtin{Name: "damage", Fn: func(ctx object.BuiltinContext, args ...object.Object) object.Object {
+			e.hp -= args[0].(*object.Integer).Value
+			return &object.Integer{Value: e.hp}
+		}}, true
+	}
+	return nil, false
+}
+
+func (e *testEntity) SetAttr(name string, value object.Object) error {
+	if name != "hp" {
+		return fmt.Errorf("cannot set %s", name)
+	}
+	hp, ok := value.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("hp must be INTEGER, got %s", value.Type())
+	}
+	e.hp = hp.Value
+	return nil
+}
+
+func runWithEntity(t *testing.T, input string, entity *testEntity) (object.Object, error) {
+	t.Helper()
+
+	comp := compiler.New()
+	sym := comp.SymbolTable().Define("entity")
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	vm.SetGlobal(sym.Index, &object.User{Value: entity})
+	err := vm.Run(context.Background())
+	return vm.LastPoppedStackElem(), err
+}
+
+func TestHostMemberAccess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		hp       int64
+	}{
+		{`entity.hp`, 100, 100},
+		{`entity.damage(30)`, 70, 70},
+		{`entity.damage(5); entity.hp`, 95, 95},
+		{`entity.hp = 42; entity.hp`, 42, 42},
+		{`entity.hp = entity.hp + 1`, 101, 101},
+	}
+
+	for _, tt := range tests {
+		entity := &testEntity{hp: 100}
+		result, err := runWithEntity(t, tt.input, entity)
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+		testExpectedObject(t, tt.expected, result)
+		if entity.hp != tt.hp {
+			t.Errorf("host state wrong for %q. want=%d, got=%d", tt.input, tt.hp, entity.hp)
+		}
+	}
+}
+
+func TestMemberAccessErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		errContains string
+	}{
+		{`entity.mana`, "unknown attribute mana"},
+		{`entity.damage = 1`, "cannot set damage"},
+		{`entity.hp = "full"`, "hp must be INTEGER"},
+		{`var n = 5; n.x`, "attribute access not supported: INTEGER"},
+		{`var a = [1]; a.x = 1`, "attribute assignment not supported: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		_, err := runWithEntity(t, tt.input, &testEntity{hp: 100})
+		if err == nil {
+			t.Fatalf("expected error for %q, got nil", tt.input)
+		}
+		if !strings.Contains(err.Error(), tt.errContains) {
+			t.Errorf("error for %q should contain %q, got %q", tt.input, tt.errContains, err.Error())
+		}
+	}
+}
+
+func TestUserWithoutAttributes(t *testing.T) {
+	comp := compiler.New()
+	sym := comp.SymbolTable().Define("thing")
+	if err := comp.Compile(parse(`thing.x`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	vm.SetGlobal(sym.Index, &object.User{Value: 42})
+	err := vm.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unknown attribute x") {
+		t.Fatalf("expected unknown attribute error, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/token"
+)
+
+var testModules = map[string]string{
+	"utils": `
+func Clamp(v, lo, hi) {
+	if v < lo { return lo }
+	if v > hi { return hi }
+	return v
+}
+func helper() { return 1 }
+var Version = "1.0"
+const Limit = 10
+`,
+	"registry": `
+var Items = ["init"]
+`,
+	"plugin": `
+import "registry"
+func Register(x) { push(registry.Items, x) }
+`,
+	"private": `
+var x = 1
+func GetX() { return x }
+`,
+	"lib/math_utils": `
+func Double(n) { return n * 2 }
+`,
+	"shapes": `
+type Vec = struct { x, y }
+func Origin() { return Vec(0, 0) }
+enum Dir { North, East, South, West }
+`,
+	"bad": `
+func Boom() {
+	return 1 / 0
+}
+`,
+}
+
+func runWithModules(t *testing.T, input string) (*VM, error) {
+	t.Helper()
+
+	loader := compiler.ModuleLoaderFunc(func(path string) (string, error) {
+		source, ok := testModules[path]
+		if !ok {
+			return "", fmt.Errorf("not found")
+		}
+		return source, nil
+	})
+
+	comp := compiler.New(compiler.WithModuleLoader(loader))
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	return vm, vm.Run(context.Background())
+}
+
+func TestImport(t *testing.T) {
+	tests := []vmTestCase{
+		{`import "utils"; utils.Clamp(15, 0, 10)`, 10},
+		{`import "utils" as u; u.Clamp(-3, 0, 10)`, 0},
+		{`import "utils"; utils.Version`, "1.0"},
+		{`import "utils"; utils.Limit * 2`, 20},
+		{`import "lib/math_utils"; math_utils.Double(21)`, 42},
+		{`import "utils"; typeof(utils)`, "module"},
+		// Module globals live in their own namespace
+		{`var x = 100; import "private"; x + private.GetX()`, 101},
+		// Functions may reference an import declared later in the file
+		{`func f() { return utils.Clamp(5, 0, 3) }; import "utils"; f()`, 3},
+		// A module is initialized once and shared by every importer
+		{`import "registry"; import "plugin"; plugin.Register("a"); import "registry" as r; len(r.Items) * 10 + len(registry.Items)`, 22},
+	}
+
+	for _, tt := range tests {
+		vm, err := runWithModules(t, tt.input)
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestImportRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		errContains string
+	}{
+		{`import "utils"; utils.helper()`, "unknown attribute helper on <module utils>"},
+		{`import "private"; private.x`, "unknown attribute x"},
+		{`import "utils"; utils.Version = "2.0"`, "module members are read-only"},
+	}
+
+	for _, tt := range tests {
+		_, err := runWithModules(t, tt.input)
+		if err == nil {
+			t.Fatalf("expected error for %q, got nil", tt.input)
+		}
+		if !strings.Contains(err.Error(), tt.errContains) {
+			t.Errorf("error for %q should contain %q, got %q", tt.input, tt.errContains, err.Error())
+		}
+	}
+}
+
+func TestModuleErrorLocation(t *testing.T) {
+	_, err := runWithModules(t, "import \"bad\"\n\nbad.Boom()")
+
+	var scriptErr *token.ScriptError
+	if !errors.As(err, &scriptErr) {
+		t.Fatalf("expected *token.ScriptError, got %T: %v", err, err)
+	}
+	if scriptErr.File != "bad" {
+		t.Errorf("wrong file. want=%q, got=%q", "bad", scriptErr.File)
+	}
+	if scriptErr.Line != 3 {
+		t.Errorf("wrong line. want=3, got=%d", scriptErr.Line)
+	}
+	if scriptErr.Function != "Boom" {
+		t.Errorf("wrong function. want=%q, got=%q", "Boom", scriptErr.Function)
+	}
+
+	want := []string{"Boom (bad:3)", "main (line 3)"}
+	if strings.Join(scriptErr.StackTrace, "|") != strings.Join(want, "|") {
+		t.Errorf("wrong stack trace. want=%q, got=%q", want, scriptErr.StackTrace)
+	}
+}
+
+func TestMainFileName(t *testing.T) {
+	comp := compiler.New(compiler.WithFileName("game.ice"))
+	if err := comp.Compile(parse("var a = 1\npanic(\"x\")")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	err := New(comp.Bytecode()).Run(context.Background())
+
+	var scriptErr *token.ScriptError
+	if !errors.As(err, &scriptErr) {
+		t.Fatalf("expected *token.ScriptError, got %T: %v", err, err)
+	}
+	if scriptErr.File != "game.ice" {
+		t.Errorf("wrong file. want=%q, got=%q", "game.ice", scriptErr.File)
+	}
+	if !strings.Contains(err.Error(), "game.ice:2") {
+		t.Errorf("error should name game.ice:2, got %q", err.Error())
+	}
+}
+
+func TestModuleNamespaceIsSnapshot(t *testing.T) {
+	vm, err := runWithModules(t, `import "registry" as r; r`)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	module, ok := vm.LastPoppedStackElem().(*object.Module)
+	if !ok {
+		t.Fatalf("object is not Module. got=%T", vm.LastPoppedStackElem())
+	}
+	if module.Name != "registry" {
+		t.Errorf("wrong module name. want=%q, got=%q", "registry", module.Name)
+	}
+	if len(module.Members) != 1 {
+		t.Errorf("module should export only Items, got %v", module.Members)
+	}
+}
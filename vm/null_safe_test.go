@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+func TestNullCoalescing(t *testing.T) {
+	tests := []vmTestCase{
+		{"null ?? 1", 1},
+		{"2 ?? 1", 2},
+		{"null ?? null ?? 3", 3},
+		{"null ?? null", Null},
+		// Only null is replaced; other falsy values are kept
+		{"false ?? 1", false},
+		{"0 ?? 1", 0},
+		{`"" ?? "x"`, ""},
+		{`var m = {"hp": 10}; m["hp"] ?? 0`, 10},
+		{`var m = {"hp": 10}; m["mp"] ?? 0`, 0},
+		{"null ?? 1 + 2", 3},
+		{"var x = null; x = x ?? 4; x", 4},
+		{"func f(o) { return o ?? \"none\" }; f(null) + f(\"a\")", "nonea"},
+		// The right side only runs when the left is null
+		{"var n = 0; func bump() { n++; return n }; 1 ?? bump(); null ?? bump(); n", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestOptionalIndex(t *testing.T) {
+	tests := []vmTestCase{
+		{`var m = null; m?["k"]`, Null},
+		{`var m = {"k": 1}; m?["k"]`, 1},
+		{`var m = {"a": {"b": 2}}; m?["a"]?["b"]`, 2},
+		{`var m = {"a": null}; m["a"]?["b"]`, Null},
+		{`var m = {}; m["a"]?["b"] ?? "default"`, "default"},
+		{"var xs = [1, 2]; xs?[1]", 2},
+		{"var xs = null; xs?[0]", Null},
+		// The index is not evaluated when the left side is null
+		{"var n = 0; func key() { n++; return 0 }; var xs = null; xs?[key()]; n", 0},
+		{"func first(xs) { return xs?[0] ?? -1 }; [first([5]), first(null)]", []int{5, -1}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestOptionalCall(t *testing.T) {
+	tests := []vmTestCase{
+		{"var f = null; f?.(1)", Null},
+		{"var f = func(x) { return x * 2 }; f?.(4)", 8},
+		{"var f = null; f?.(...[1, 2])", Null},
+		{"var f = func(a, b) { return a - b }; f?.(...[5, 2])", 3},
+		{`var handlers = {"hit": func(d) { return d + 1 }}; format("%v %v", handlers["hit"]?.(1), handlers["miss"]?.(1))`, "2 null"},
+		{`var handlers = {}; handlers["miss"]?.(1) ?? "unhandled"`, "unhandled"},
+		// The arguments are not evaluated when the function is null
+		{"var n = 0; func arg() { n++; return 0 }; var f = null; f?.(arg()); n", 0},
+		{"func make() { return null }; make()?.()", Null},
+		{"func g() { yield 1 }; var co = g(); co?.()", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestOptionalChain(t *testing.T) {
+	tests := []vmTestCase{
+		// A null before ?[ or ?. skips the rest of the chain
+		{`var n = null; n?["a"]["b"]`, Null},
+		{`var n = null; n?["a"].b.c`, Null},
+		{`var n = null; n?["a"]["b"](1)[0]`, Null},
+		{`var n = null; n?[0][1:]`, Null},
+		{`var f = null; f?.()["x"].y`, Null},
+		{`var n = null; n?["a"]["b"] ?? "default"`, "default"},
+		{`var m = {"a": {"b": [1, 2]}}; m?["a"]["b"][1]`, 2},
+		{`var m = {"a": null}; m["a"]?["b"]["c"]`, Null},
+		// Nothing after the skipped link is evaluated
+		{"var n = 0; func key() { n++; return 0 }; var xs = null; xs?[0][key()](key()); n", 0},
+		// The short circuit ends with the chain: operands are separate chains
+		{`var n = null; [n?["a"]["b"], 1][1]`, 1},
+		{`func f(x) { return x ?? 3 }; var n = null; f(n?["a"]["b"])`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestNullSafeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Only a null left side is skipped
+		{"var f = 1; f?.()", "calling non-function"},
+		{`var m = 5; m?["k"]`, "index operator not supported: INTEGER"},
+		{`var m = {"a": null}; m["a"]["b"]`, "index operator not supported: NULL"},
+		// A null produced after the optional link is not skipped
+		{`var m = {"a": null}; m?["a"]["b"]`, "index operator not supported: NULL"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+	"github.com/iceisfun/icescript/token"
+)
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input        string
+		errContains  string
+		expectedLine int
+	}{
+		{"var x = 0\n10 / x", "integer division by zero", 2},
+		{"var x = 0\n\n10 % x", "integer modulo by zero", 3},
+		{"func f(a) {\n  return a / 0\n}\nf(1)", "integer division by zero", 2},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run(context.Background())
+		if err == nil {
+			t.Fatalf("expected error for %q, got nil", tt.input)
+		}
+
+		var scriptErr *token.ScriptError
+		if !errors.As(err, &scriptErr) {
+			t.Fatalf("expected *token.ScriptError, got %T: %v", err, err)
+		}
+		if !strings.Contains(scriptErr.Message, tt.errContains) {
+			t.Errorf("expected message containing %q, got %q", tt.errContains, scriptErr.Message)
+		}
+		if scriptErr.Line != tt.expectedLine {
+			t.Errorf("expected line %d, got %d", tt.expectedLine, scriptErr.Line)
+		}
+		if len(scriptErr.StackTrace) == 0 {
+			t.Error("expected stack trace, got empty")
+		}
+	}
+}
+
+func newPanickingVM(t *testing.T, input string, opts ...Option) *VM {
+	t.Helper()
+
+	c := compiler.New()
+	sym := c.SymbolTable().Define("explode")
+
+	if err := c.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(c.Bytecode(), opts...)
+	vm.SetGlobal(sym.Index, &object.Builtin{
+		Name: "explode",
+		Fn: func(ctx object.BuiltinContext, args ...object.Object) object.Object {
+			var m map[string]int
+			m["boom"] = 1 // nil map write
+			return object.NullObj
+		},
+	})
+	return vm
+}
+
+func TestBuiltinPanicRecovered(t *testing.T) {
+	vm := newPanickingVM(t, "var a = 1\nexplode()")
+
+	err := vm.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	var scriptErr *token.ScriptError
+	if !errors.As(err, &scriptErr) {
+		t.Fatalf("expected *token.ScriptError, got %T: %v", err, err)
+	}
+	if !strings.Contains(scriptErr.Message, "builtin explode panicked") {
+		t.Errorf("unexpected message: %q", scriptErr.Message)
+	}
+	if scriptErr.Line != 2 {
+		t.Errorf("expected line 2, got %d", scriptErr.Line)
+	}
+}
+
+func TestBuiltinPanicRecoveredInInvoke(t *testing.T) {
+	vm := newPanickingVM(t, "func tick() { explode() }")
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	tick, err := vm.GetGlobal("tick")
+	if err != nil {
+		t.Fatalf("GetGlobal error: %s", err)
+	}
+
+	_, err = vm.Invoke(context.Background(), tick)
+	if err == nil || !strings.Contains(err.Error(), "builtin explode panicked") {
+		t.Fatalf("expected recovered builtin panic, got %v", err)
+	}
+}
+
+func TestWithRepanic(t *testing.T) {
+	vm := newPanickingVM(t, "explode()", WithRepanic())
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic to propagate with WithRepanic")
+		}
+	}()
+
+	vm.Run(context.Background())
+}
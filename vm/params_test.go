@@ -0,0 +1,135 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+func TestDefaultParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{"func spawn(kind, count = 1) { return count }; spawn(1)", 1},
+		{"func spawn(kind, count = 1) { return count }; spawn(1, 5)", 5},
+		{"func f(a = 1, b = 2) { return [a, b] }; f()", []int{1, 2}},
+		{"func f(a = 1, b = 2) { return [a, b] }; f(7)", []int{7, 2}},
+		{"func f(a = 1, b = 2) { return [a, b] }; f(7, 8)", []int{7, 8}},
+		{"func f(a, b = a * 2) { return b }; f(4)", 8},
+		{"func f(a = b, b = 1) { return a }; f()", Null},
+		{"func f(x = null) { return x }; f(3)", 3},
+		{"var n = 0; func next() { n++; return n }; func f(x = next()) { return x }; f(); f(); f(10); n", 2},
+		{`func greet(name = "world") { return "hi " + name }; greet() + "/" + greet("bob")`, "hi world/hi bob"},
+		{"func f(x = 5) { return func() { x++; return x } }; var g = f(); g(); g()", 7},
+		{"var f = func(a, b = 10) { return a + b }; f(1)", 11},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestVariadicParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{"func f(...xs) { return xs }; f()", []int{}},
+		{"func f(...xs) { return xs }; f(1, 2, 3)", []int{1, 2, 3}},
+		{"func f(a, ...xs) { return xs }; f(1)", []int{}},
+		{"func f(a, ...xs) { return len(xs) + a }; f(10, 1, 2)", 12},
+		{`func log(level, ...parts) { return level + ": " + join(parts, " ") }; log("warn", "low", "hp")`, "warn: low hp"},
+		{"func f(a, b = 2, ...xs) { return [a, b, len(xs)] }; f(1)", []int{1, 2, 0}},
+		{"func f(a, b = 2, ...xs) { return [a, b, len(xs)] }; f(1, 5, 6, 7)", []int{1, 5, 2}},
+		{"func f(...xs) { return func() { return len(xs) } }; f(1, 2)()", 2},
+		{"func f(...xs) { push(xs, 1); return len(xs) }; f(); f()", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSpreadArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{"func add(a, b) { return a + b }; add(...[1, 2])", 3},
+		{"func add(a, b) { return a + b }; var xs = [5]; add(...xs, 10)", 15},
+		{"func f(...xs) { return xs }; f(0, ...[1, 2], 3, ...[], ...[4])", []int{0, 1, 2, 3, 4}},
+		{"func f(a, b = 2) { return [a, b] }; f(...[9])", []int{9, 2}},
+		{"func add(a, b) { return a + b }; add(...testMultiReturn(1, 2))", 3},
+		{"func pair() { return (1, 2) }; func add(a, b) { return a + b }; add(...pair())", 3},
+		{"len(...[[1, 2, 3]])", 3},
+		{`join(...[["a", "b"], "-"])`, "a-b"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"func f(a, b = 1) {}; f()", "wrong number of arguments: want=1..2, got=0"},
+		{"func f(a, b = 1) {}; f(1, 2, 3)", "wrong number of arguments: want=1..2, got=3"},
+		{"func f(a, ...b) {}; f()", "wrong number of arguments: want at least 1, got=0"},
+		{"func f(a) {}; f(...[1, 2])", "wrong number of arguments: want=1, got=2"},
+		{"func f(a) {}; f(...5)", "cannot spread INTEGER into call arguments"},
+		{`func f(a) {}; f(..."ab")`, "cannot spread STRING into call arguments"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestInvokeVariadicAndDefaults(t *testing.T) {
+	input := `
+func log(level, ...parts) { return level + ":" + join(parts, ",") }
+func spawn(kind, count = 1) { return count }
+`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	log, _ := vm.GetGlobal("log")
+	spawn, _ := vm.GetGlobal("spawn")
+	str := func(s string) object.Object { return &object.String{Value: s} }
+
+	result, err := vm.Invoke(context.Background(), log, str("info"))
+	if err != nil {
+		t.Fatalf("Invoke log: %s", err)
+	}
+	testExpectedObject(t, "info:", result)
+
+	result, err = vm.Invoke(context.Background(), log, str("warn"), str("a"), str("b"))
+	if err != nil {
+		t.Fatalf("Invoke log: %s", err)
+	}
+	testExpectedObject(t, "warn:a,b", result)
+
+	result, err = vm.Invoke(context.Background(), spawn, str("orc"))
+	if err != nil {
+		t.Fatalf("Invoke spawn: %s", err)
+	}
+	testExpectedObject(t, 1, result)
+
+	result, err = vm.Invoke(context.Background(), spawn, str("orc"), &object.Integer{Value: 4})
+	if err != nil {
+		t.Fatalf("Invoke spawn: %s", err)
+	}
+	testExpectedObject(t, 4, result)
+
+	_, err = vm.Invoke(context.Background(), log)
+	if err == nil || !strings.Contains(err.Error(), "want at least 1") {
+		t.Fatalf("expected arity error, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package vm
+
+import (
+	"compress/gzip"
+	"io"
+	"time"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format of
+// pprof, so that it can be explored with `go tool pprof` and rendered as a
+// flame graph. It has two sample types: instructions and wall time.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	samples := p.Samples()
+
+	p.mu.Lock()
+	start := p.start
+	p.mu.Unlock()
+
+	b := &profileBuilder{strings: map[string]int64{"": 0}, stringTable: []string{""}, functions: map[[2]string]uint64{}, locations: map[ProfileLocation]uint64{}}
+	var out protoBuffer
+
+	// Profile.sample_type
+	out.message(1, b.valueType("instructions", "count"))
+	out.message(1, b.valueType("wall", "nanoseconds"))
+	periodType := b.valueType("instructions", "count")
+
+	// Profile.sample
+	for _, s := range samples {
+		ids := make([]uint64, len(s.Stack))
+		for i, loc := range s.Stack {
+			ids[i] = b.location(loc)
+		}
+		var sample protoBuffer
+		sample.packedUint64(1, ids)
+		sample.packedInt64(2, []int64{s.Instructions, int64(s.Wall)})
+		out.message(2, sample)
+	}
+
+	// Profile.location
+	for _, loc := range b.locationList {
+		out.message(4, loc)
+	}
+	// Profile.function
+	for _, fn := range b.functionList {
+		out.message(5, fn)
+	}
+
+	// Profile.string_table
+	for _, s := range b.stringTable {
+		out.string(6, s)
+	}
+
+	out.int64(9, start.UnixNano())          // Profile.time_nanos
+	out.int64(10, int64(time.Since(start))) // Profile.duration_nanos
+	out.message(11, periodType)             // Profile.period_type
+	out.int64(12, p.every)                  // Profile.period
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(out.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// profileBuilder interns the strings, functions and locations of a pprof
+// profile.
+type profileBuilder struct {
+	strings     map[string]int64
+	stringTable []string
+
+	functions    map[[2]string]uint64
+	functionList []protoBuffer
+
+	locations    map[ProfileLocation]uint64
+	locationList []protoBuffer
+}
+
+func (b *profileBuilder) str(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	i := int64(len(b.stringTable))
+	b.strings[s] = i
+	b.stringTable = append(b.stringTable, s)
+	return i
+}
+
+func (b *profileBuilder) valueType(typ, unit string) protoBuffer {
+	var vt protoBuffer
+	vt.int64(1, b.str(typ))
+	vt.int64(2, b.str(unit))
+	return vt
+}
+
+func (b *profileBuilder) function(name, file string) uint64 {
+	key := [2]string{name, file}
+	if id, ok := b.functions[key]; ok {
+		return id
+	}
+	id := uint64(len(b.functionList) + 1)
+	b.functions[key] = id
+
+	var fn protoBuffer
+	fn.uint64(1, id)
+	fn.int64(2, b.str(name))
+	fn.int64(3, b.str(name))
+	fn.int64(4, b.str(file))
+	b.functionList = append(b.functionList, fn)
+	return id
+}
+
+func (b *profileBuilder) location(loc ProfileLocation) uint64 {
+	if id, ok := b.locations[loc]; ok {
+		return id
+	}
+	id := uint64(len(b.locationList) + 1)
+	b.locations[loc] = id
+
+	var line protoBuffer
+	line.uint64(1, b.function(loc.Function, loc.File))
+	line.int64(2, int64(loc.Line))
+
+	var l protoBuffer
+	l.uint64(1, id)
+	l.message(4, line)
+	b.locationList = append(b.locationList, l)
+	return id
+}
+
+// protoBuffer encodes protocol buffer messages. Fields with zero values are
+// omitted, as proto3 does.
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) key(field int, wireType uint64) {
+	b.varint(uint64(field)<<3 | wireType)
+}
+
+func (b *protoBuffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// string always writes s: the string table must keep its empty first entry.
+func (b *protoBuffer) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protoBuffer) message(field int, m protoBuffer) {
+	b.bytes(field, m.data)
+}
+
+func (b *protoBuffer) packedUint64(field int, xs []uint64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(field, packed.data)
+}
+
+func (b *protoBuffer) packedInt64(field int, xs []int64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.bytes(field, packed.data)
+}
@@ -0,0 +1,250 @@
+package vm
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iceisfun/icescript/object"
+)
+
+// Profiler attributes the instructions executed and the wall time spent by
+// scripts to their call stacks. Attach it with WithProfiler or SetProfiler;
+// one Profiler may collect from several VMs, including concurrently running
+// ones.
+//
+// Every Nth instruction is sampled and stands for the N instructions since
+// the previous sample, as does the time since then. Builtin calls are timed
+// individually and show up as callees of the line that made them.
+type Profiler struct {
+	every int64
+
+	mu      sync.Mutex
+	samples map[string]*profileSample
+	start   time.Time
+}
+
+// profileSample accumulates the cost of one call stack.
+type profileSample struct {
+	stack        []ProfileLocation
+	instructions int64
+	wall         time.Duration
+}
+
+// ProfileLocation is a line of a function in a profiled call stack.
+// Builtins have no file or line.
+type ProfileLocation struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// ProfileSample is the cost of one call stack.
+type ProfileSample struct {
+	Stack        []ProfileLocation // innermost first
+	Instructions int64
+	Wall         time.Duration
+}
+
+// FunctionProfile is the cost of the code of one function, excluding the
+// functions it calls.
+type FunctionProfile struct {
+	Function     string
+	File         string
+	Instructions int64
+	Wall         time.Duration
+}
+
+// NewProfiler creates a Profiler that samples every Nth instruction. An every
+// of 1 or less records every instruction, which is exact but walks the call
+// stack each time and slows scripts down tenfold or more; production use
+// should sample every thousand or so.
+func NewProfiler(every int) *Profiler {
+	if every < 1 {
+		every = 1
+	}
+	return &Profiler{every: int64(every), samples: make(map[string]*profileSample), start: time.Now()}
+}
+
+// Reset discards everything recorded so far.
+func (p *Profiler) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.samples = make(map[string]*profileSample)
+	p.start = time.Now()
+}
+
+// Samples returns the recorded call stacks, most instructions first.
+func (p *Profiler) Samples() []ProfileSample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	samples := make([]ProfileSample, 0, len(p.samples))
+	for _, s := range p.samples {
+		samples = append(samples, ProfileSample{Stack: s.stack, Instructions: s.instructions, Wall: s.wall})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Instructions != samples[j].Instructions {
+			return samples[i].Instructions > samples[j].Instructions
+		}
+		return samples[i].Wall > samples[j].Wall
+	})
+	return samples
+}
+
+// Functions sums the samples by the function that was running, most
+// instructions first.
+func (p *Profiler) Functions() []FunctionProfile {
+	type key struct{ function, file string }
+
+	totals := make(map[key]*FunctionProfile)
+	var list []*FunctionProfile
+	for _, s := range p.Samples() {
+		leaf := s.Stack[0]
+		k := key{leaf.Function, leaf.File}
+		fp, ok := totals[k]
+		if !ok {
+			fp = &FunctionProfile{Function: leaf.Function, File: leaf.File}
+			totals[k] = fp
+			list = append(list, fp)
+		}
+		fp.Instructions += s.Instructions
+		fp.Wall += s.Wall
+	}
+
+	functions := make([]FunctionProfile, len(list))
+	for i, fp := range list {
+		functions[i] = *fp
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		if functions[i].Instructions != functions[j].Instructions {
+			return functions[i].Instructions > functions[j].Instructions
+		}
+		return functions[i].Wall > functions[j].Wall
+	})
+	return functions
+}
+
+// profileState is the sampling state of a VM during one Run, Invoke or
+// Resume.
+type profileState struct {
+	skip int64          // instructions left until the next sample
+	last time.Time      // when the previous sample was taken
+	prev *profileSample // the previous sample, charged with the time since then
+	key  []byte
+}
+
+// WithProfiler attaches p, which records the cost of everything the VM runs.
+func WithProfiler(p *Profiler) Option {
+	return func(vm *VM) {
+		vm.profiler = p
+	}
+}
+
+// SetProfiler attaches p, or detaches the profiler when p is nil.
+func (vm *VM) SetProfiler(p *Profiler) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.profiler = p
+}
+
+// profileStart begins the sampling of a Run, Invoke or Resume.
+func (vm *VM) profileStart() {
+	vm.prof.skip = 0
+	vm.prof.last = time.Now()
+	vm.prof.prev = nil
+}
+
+// profileStop charges the time since the last sample.
+func (vm *VM) profileStop() {
+	if vm.prof.prev == nil {
+		return
+	}
+	p := vm.profiler
+	p.mu.Lock()
+	vm.prof.prev.wall += time.Since(vm.prof.last)
+	p.mu.Unlock()
+	vm.prof.prev = nil
+}
+
+// profileHook runs before each instruction while a profiler is attached.
+func (vm *VM) profileHook() {
+	if vm.prof.skip > 0 {
+		vm.prof.skip--
+		return
+	}
+	p := vm.profiler
+	vm.prof.skip = p.every - 1
+	now := time.Now()
+
+	p.mu.Lock()
+	if vm.prof.prev != nil {
+		vm.prof.prev.wall += now.Sub(vm.prof.last)
+	}
+	sample := vm.profileSample(p, "")
+	sample.instructions += p.every
+	p.mu.Unlock()
+
+	vm.prof.prev, vm.prof.last = sample, now
+}
+
+// profileBuiltin charges the time spent in a call of b, which is taken out of
+// the time of the instruction that called it.
+func (vm *VM) profileBuiltin(b *object.Builtin, d time.Duration) {
+	name := b.Name
+	if name == "" {
+		name = "<builtin>"
+	}
+
+	p := vm.profiler
+	p.mu.Lock()
+	vm.profileSample(p, name).wall += d
+	p.mu.Unlock()
+
+	vm.prof.last = vm.prof.last.Add(d)
+}
+
+// profileSample returns the sample of the current call stack, below a call of
+// the named builtin if there is one. p.mu must be held.
+func (vm *VM) profileSample(p *Profiler, builtin string) *profileSample {
+	key := append(vm.prof.key[:0], builtin...)
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		if frame == nil || frame.cl == nil {
+			continue
+		}
+		fn := frame.cl.Fn
+		key = append(key, 0)
+		key = append(key, fn.Name...)
+		key = append(key, 0)
+		key = append(key, fn.File...)
+		key = append(key, 0)
+		key = strconv.AppendInt(key, int64(translateIPToLine(fn.SourceMap, frame.ip)), 10)
+	}
+	vm.prof.key = key
+
+	if sample, ok := p.samples[string(key)]; ok {
+		return sample
+	}
+
+	var stack []ProfileLocation
+	if builtin != "" {
+		stack = append(stack, ProfileLocation{Function: builtin})
+	}
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		if frame == nil || frame.cl == nil {
+			continue
+		}
+		fn := frame.cl.Fn
+		stack = append(stack, ProfileLocation{
+			Function: functionName(fn),
+			File:     fileNameOf(fn),
+			Line:     translateIPToLine(fn.SourceMap, frame.ip),
+		})
+	}
+	sample := &profileSample{stack: stack}
+	p.samples[string(key)] = sample
+	return sample
+}
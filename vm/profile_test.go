@@ -0,0 +1,152 @@
+package vm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+)
+
+const profileInput = `func fib(n) {
+	if n < 2 { return n }
+	return fib(n - 1) + fib(n - 2)
+}
+func work() {
+	var i = 0
+	for i < 50 {
+		format("%d", i)
+		i++
+	}
+}
+fib(10)
+work()
+`
+
+func TestProfilerExact(t *testing.T) {
+	p := NewProfiler(1)
+	vm := New(compileInput(t, profileInput), WithProfiler(p))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	var total int64
+	for _, s := range p.Samples() {
+		total += s.Instructions
+	}
+	if want := vm.Stats().Instructions; total != want {
+		t.Errorf("wrong instruction total. want=%d, got=%d", want, total)
+	}
+
+	functions := map[string]FunctionProfile{}
+	for _, fp := range p.Functions() {
+		functions[fp.Function] = fp
+	}
+	if fib := functions["fib"]; fib.File != "script.ice" || fib.Instructions <= functions["work"].Instructions {
+		t.Errorf("fib should be the most expensive function. got=%+v", p.Functions())
+	}
+	if _, ok := functions["main"]; !ok {
+		t.Errorf("missing main. got=%+v", p.Functions())
+	}
+	if format, ok := functions["format"]; !ok || format.Instructions != 0 {
+		t.Errorf("builtin format should have wall time only. got=%+v", format)
+	}
+}
+
+func TestProfilerStacks(t *testing.T) {
+	p := NewProfiler(1)
+	vm := New(compileInput(t, profileInput), WithProfiler(p))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	found := false
+	for _, s := range p.Samples() {
+		if s.Stack[0].Function != "format" {
+			continue
+		}
+		found = true
+		want := []ProfileLocation{{Function: "format"}, {"work", "script.ice", 8}, {"main", "script.ice", 13}}
+		if len(s.Stack) != len(want) {
+			t.Fatalf("wrong stack. got=%+v", s.Stack)
+		}
+		for i := range want {
+			if s.Stack[i] != want[i] {
+				t.Errorf("wrong location %d. want=%+v, got=%+v", i, want[i], s.Stack[i])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no sample for format")
+	}
+}
+
+func TestProfilerSampling(t *testing.T) {
+	p := NewProfiler(100)
+	vm := New(compileInput(t, profileInput), WithProfiler(p))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	var total int64
+	for _, s := range p.Samples() {
+		total += s.Instructions
+		if s.Instructions%100 != 0 {
+			t.Errorf("samples should count 100 instructions each. got=%d", s.Instructions)
+		}
+	}
+	executed := vm.Stats().Instructions
+	if total < executed || total >= executed+100 {
+		t.Errorf("sampled total %d too far from %d", total, executed)
+	}
+
+	p.Reset()
+	if len(p.Samples()) != 0 {
+		t.Errorf("Reset should discard the samples")
+	}
+}
+
+func TestProfilerDetached(t *testing.T) {
+	p := NewProfiler(1)
+	vm := New(compileInput(t, profileInput), WithProfiler(p))
+	vm.SetProfiler(nil)
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if len(p.Samples()) != 0 {
+		t.Errorf("detached profiler recorded %d samples", len(p.Samples()))
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	p := NewProfiler(1)
+	vm := New(compileInput(t, profileInput), WithProfiler(p))
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WritePprof(&buf); err != nil {
+		t.Fatalf("WritePprof: %s", err)
+	}
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("profile is not gzipped: %s", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading profile: %s", err)
+	}
+
+	// The first field is the instructions sample type, whose strings are at
+	// indexes 1 and 2 of the string table
+	if !bytes.HasPrefix(data, []byte{0x0a, 0x04, 0x08, 0x01, 0x10, 0x02}) {
+		t.Errorf("unexpected start of profile: % x", data[:6])
+	}
+	for _, s := range []string{"instructions", "nanoseconds", "fib", "work", "format", "script.ice"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("profile does not mention %q", s)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+func TestRangeArray(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var sum = 0
+			for i, v := range [10, 20, 30] {
+				sum = sum + i * v
+			}
+			sum
+			`,
+			80,
+		},
+		{
+			`
+			var sum = 0
+			for i := range [5, 5, 5, 5] {
+				sum = sum + i
+			}
+			sum
+			`,
+			6,
+		},
+		{
+			`
+			var sum = 0
+			for _, v := range [1, 2, 3] {
+				sum = sum + v
+			}
+			sum
+			`,
+			6,
+		},
+		{
+			`
+			var n = 0
+			for range [1, 2, 3] {
+				n = n + 1
+			}
+			n
+			`,
+			3,
+		},
+		{
+			`
+			var n = 0
+			for _, v := range [] {
+				n = n + 1
+			}
+			n
+			`,
+			0,
+		},
+		{
+			`
+			func total(arr) {
+				var sum = 0
+				for _, v := range arr {
+					if v < 0 { continue }
+					if v > 100 { break }
+					sum = sum + v
+				}
+				return sum
+			}
+			total([1, -5, 2, 200, 3])
+			`,
+			3,
+		},
+		{
+			`
+			var count = 0
+			outer: for _, row := range [[1, 2], [3, 4], [5, 6]] {
+				for _, v := range row {
+					if v == 4 { break outer }
+					count = count + 1
+				}
+			}
+			count
+			`,
+			3,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRangeArrayGrowingInBody(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var a = [1, 2, 3]
+			for _, v := range a {
+				push(a, v)
+			}
+			len(a)
+			`,
+			6,
+		},
+		{
+			`
+			var a = [1, 2, 3]
+			var sum = 0
+			for _, v := range a {
+				push(a, v * 10)
+				sum = sum + v
+			}
+			sum
+			`,
+			6,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRangeHashTupleString(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var total = 0
+			for k, v := range {1: 10, 2: 20, 3: 30} {
+				total = total + k * v
+			}
+			total
+			`,
+			140,
+		},
+		{
+			`
+			var first = 0
+			var seen = false
+			for k := range {3: "c", 1: "a", 2: "b"} {
+				if !seen {
+					first = k
+					seen = true
+				}
+			}
+			first
+			`,
+			1,
+		},
+		{
+			`
+			var sum = 0
+			for i, v := range (4, 5, 6) {
+				sum = sum + i + v
+			}
+			sum
+			`,
+			18,
+		},
+		{
+			`
+			var offsets = []
+			for i := range "aéb" {
+				push(offsets, i)
+			}
+			offsets
+			`,
+			[]int{0, 1, 3},
+		},
+		{
+			`
+			var n = 0
+			for _, ch := range "héllo" {
+				if ch == "é" { n = n + 1 }
+			}
+			n
+			`,
+			1,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRangeHashDeterministicOrder(t *testing.T) {
+	input := `
+	var out = ""
+	for k, _ := range {"b": 2, "c": 3, "a": 1} {
+		out = out + k
+	}
+	out
+	`
+
+	for i := 0; i < 10; i++ {
+		program := parse(input)
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(context.Background()); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if got := vm.LastPoppedStackElem().Inspect(); got != "abc" {
+			t.Fatalf("expected keys in sorted order %q, got %q", "abc", got)
+		}
+	}
+}
+
+func TestRangeNonIterable(t *testing.T) {
+	program := parse(`for k, v := range 5 { }`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err := vm.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected runtime error, got nil")
+	}
+	if !strings.Contains(err.Error(), "cannot range over INTEGER") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
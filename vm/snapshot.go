@@ -0,0 +1,761 @@
+package vm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+// Snapshot format, version 1. Integers are varints.
+//
+//	magic "ICESNAP", version, fingerprint of the bytecode (32 bytes)
+//	number of objects, then the kind of each object (one byte each)
+//	the contents of each object, in order
+//	number of globals, then their values
+//
+// Values are a tag byte followed by the scalar, the index of an object or the
+// index of a constant. Objects are referenced by index, so that shared
+// references and cycles survive, and their kinds come first so that Restore
+// can allocate every object before filling any of them in.
+const (
+	snapshotMagic   = "ICESNAP"
+	snapshotVersion = 1
+)
+
+// ErrBytecodeMismatch is returned by Restore when the snapshot was taken from
+// a VM running different bytecode.
+var ErrBytecodeMismatch = errors.New("snapshot was taken with different bytecode")
+
+// UserCodec converts the host values wrapped by object.User to and from bytes
+// for Snapshot and Restore. The bytes are opaque to the VM, so a codec that
+// handles several types must record which one it encoded.
+type UserCodec interface {
+	EncodeUser(value any) ([]byte, error)
+	DecodeUser(data []byte) (any, error)
+}
+
+// WithUserCodec sets the codec used for object.User values in snapshots.
+// Without one, snapshots of VMs holding user values fail.
+func WithUserCodec(codec UserCodec) Option {
+	return func(vm *VM) {
+		vm.userCodec = codec
+	}
+}
+
+const (
+	tagNil byte = iota // an unset global or stack slot
+	tagNull
+	tagTrue
+	tagFalse
+	tagInteger
+	tagFloat
+	tagString
+	tagObject   // index into the objects
+	tagConstant // index into the constant pool of the bytecode
+	tagBuiltin  // name of a builtin
+)
+
+const (
+	kindArray byte = iota
+	kindHash
+	kindTuple
+	kindStruct
+	kindCell
+	kindClosure
+	kindCoroutine
+	kindModule
+	kindIterator
+	kindUser
+)
+
+// Snapshot writes the state of the script to w: its globals and everything
+// reachable from them, including closures with their captured variables and
+// suspended coroutines with their frames. Objects referenced from several
+// places are written once, so shared references and cycles are restored as
+// they were.
+//
+// Snapshot must be called between calls of Run, Invoke and Resume, which is
+// when every unfinished call is a suspended coroutine. Builtins are saved by
+// name; those supplied by the host are restored as null and must be set
+// again, as before the first Run. User values are saved through the
+// UserCodec given with WithUserCodec.
+func (vm *VM) Snapshot(w io.Writer) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	s := &snapshotWriter{
+		codec:     vm.userCodec,
+		ids:       make(map[object.Object]int),
+		constants: make(map[object.Object]int),
+	}
+	for i, constant := range vm.constants {
+		switch constant.(type) {
+		case *object.CompiledFunction, *object.StructType, *object.Enum, *object.JumpTable:
+			s.constants[constant] = i
+		}
+	}
+
+	n := len(vm.globals)
+	for n > 0 && vm.globals[n-1] == nil {
+		n--
+	}
+	var globals snapshotBuffer
+	globals.uvarint(uint64(n))
+	for _, global := range vm.globals[:n] {
+		if err := s.value(&globals, global); err != nil {
+			return err
+		}
+	}
+
+	// Writing an object's contents discovers the objects it references, which
+	// are appended to s.objects in turn
+	var contents snapshotBuffer
+	for i := 0; i < len(s.objects); i++ {
+		if err := s.contents(&contents, s.objects[i]); err != nil {
+			return err
+		}
+	}
+
+	var out snapshotBuffer
+	out.data = append(out.data, snapshotMagic...)
+	out.uvarint(snapshotVersion)
+	fp := fingerprint(vm.main.Instructions, vm.constants)
+	out.data = append(out.data, fp[:]...)
+	out.uvarint(uint64(len(s.kinds)))
+	out.data = append(out.data, s.kinds...)
+	out.data = append(out.data, contents.data...)
+	out.data = append(out.data, globals.data...)
+
+	_, err := w.Write(out.data)
+	return err
+}
+
+// Restore creates a VM for bytecode with the state saved by Snapshot. The
+// bytecode must be the same as that of the VM the snapshot was taken from,
+// or Restore fails with ErrBytecodeMismatch.
+//
+// The restored VM is ready for Invoke and Resume. Run would execute the
+// script again from the start, initializing its globals anew.
+func Restore(r io.Reader, bytecode *compiler.Bytecode, opts ...Option) (*VM, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := New(bytecode, opts...)
+	if err := vm.restore(data); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+func (vm *VM) restore(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(snapshotMagic)) {
+		return errors.New("not a snapshot")
+	}
+	d := &snapshotReader{vm: vm, data: data, pos: len(snapshotMagic)}
+
+	if version := d.uvarint(); d.err == nil && version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	fp := fingerprint(vm.main.Instructions, vm.constants)
+	if saved := d.next(len(fp)); d.err == nil && !bytes.Equal(saved, fp[:]) {
+		return ErrBytecodeMismatch
+	}
+
+	kinds := d.next(d.count())
+	d.objects = make([]object.Object, len(kinds))
+	for i, kind := range kinds {
+		d.objects[i] = newSnapshotObject(kind)
+		if d.objects[i] == nil {
+			d.fail("unknown object kind %d", kind)
+			break
+		}
+	}
+	for _, obj := range d.objects {
+		if d.err != nil {
+			break
+		}
+		d.contents(obj)
+	}
+
+	n := d.count()
+	if n > len(vm.globals) {
+		d.fail("%d globals exceed the limit of %d", n, len(vm.globals))
+	}
+	for i := 0; i < n && d.err == nil; i++ {
+		vm.globals[i] = d.value()
+	}
+
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("%d bytes of trailing data", len(d.data)-d.pos)
+	}
+	if d.err != nil {
+		return fmt.Errorf("corrupt snapshot: %w", d.err)
+	}
+	return nil
+}
+
+// snapshotWriter numbers the objects of a snapshot as it finds them.
+type snapshotWriter struct {
+	codec     UserCodec
+	ids       map[object.Object]int
+	objects   []object.Object
+	kinds     []byte
+	constants map[object.Object]int
+}
+
+func snapshotKind(obj object.Object) (byte, bool) {
+	switch obj.(type) {
+	case *object.Array:
+		return kindArray, true
+	case *object.Hash:
+		return kindHash, true
+	case *object.Tuple:
+		return kindTuple, true
+	case *object.Struct:
+		return kindStruct, true
+	case *object.Cell:
+		return kindCell, true
+	case *object.Closure:
+		return kindClosure, true
+	case *object.Coroutine:
+		return kindCoroutine, true
+	case *object.Module:
+		return kindModule, true
+	case *object.Iterator:
+		return kindIterator, true
+	case *object.User:
+		return kindUser, true
+	default:
+		return 0, false
+	}
+}
+
+func newSnapshotObject(kind byte) object.Object {
+	switch kind {
+	case kindArray:
+		return &object.Array{}
+	case kindHash:
+		return &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	case kindTuple:
+		return &object.Tuple{}
+	case kindStruct:
+		return &object.Struct{}
+	case kindCell:
+		return &object.Cell{}
+	case kindClosure:
+		return &object.Closure{}
+	case kindCoroutine:
+		return &object.Coroutine{}
+	case kindModule:
+		return &object.Module{}
+	case kindIterator:
+		return &object.Iterator{}
+	case kindUser:
+		return &object.User{}
+	default:
+		return nil
+	}
+}
+
+func (s *snapshotWriter) value(b *snapshotBuffer, obj object.Object) error {
+	switch obj := obj.(type) {
+	case nil:
+		b.byte(tagNil)
+	case *object.Null:
+		b.byte(tagNull)
+	case *object.Boolean:
+		if obj.Value {
+			b.byte(tagTrue)
+		} else {
+			b.byte(tagFalse)
+		}
+	case *object.Integer:
+		b.byte(tagInteger)
+		b.varint(obj.Value)
+	case *object.Float:
+		b.byte(tagFloat)
+		b.uvarint(math.Float64bits(obj.Value))
+	case *object.String:
+		b.byte(tagString)
+		b.string(obj.Value)
+	case *object.Builtin:
+		b.byte(tagBuiltin)
+		b.string(obj.Name)
+	case *object.CompiledFunction, *object.StructType, *object.Enum, *object.JumpTable:
+		i, ok := s.constants[obj]
+		if !ok {
+			return fmt.Errorf("cannot snapshot %s: it is not part of the bytecode", obj.Inspect())
+		}
+		b.byte(tagConstant)
+		b.uvarint(uint64(i))
+	default:
+		kind, ok := snapshotKind(obj)
+		if !ok {
+			return fmt.Errorf("cannot snapshot a value of type %s", obj.Type())
+		}
+		id, ok := s.ids[obj]
+		if !ok {
+			id = len(s.objects)
+			s.ids[obj] = id
+			s.objects = append(s.objects, obj)
+			s.kinds = append(s.kinds, kind)
+		}
+		b.byte(tagObject)
+		b.uvarint(uint64(id))
+	}
+	return nil
+}
+
+func (s *snapshotWriter) values(b *snapshotBuffer, objs []object.Object) error {
+	b.uvarint(uint64(len(objs)))
+	for _, obj := range objs {
+		if err := s.value(b, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshotWriter) contents(b *snapshotBuffer, obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return s.values(b, obj.Elements)
+
+	case *object.Tuple:
+		return s.values(b, obj.Elements)
+
+	case *object.Hash:
+		keys := obj.SortedKeys()
+		b.uvarint(uint64(len(keys)))
+		for _, key := range keys {
+			pair := obj.Pairs[key]
+			if err := s.value(b, pair.Key); err != nil {
+				return err
+			}
+			if err := s.value(b, pair.Value); err != nil {
+				return err
+			}
+		}
+
+	case *object.Struct:
+		if err := s.value(b, obj.Def); err != nil {
+			return err
+		}
+		return s.values(b, obj.Fields)
+
+	case *object.Cell:
+		return s.value(b, obj.Value)
+
+	case *object.Closure:
+		if err := s.value(b, obj.Fn); err != nil {
+			return err
+		}
+		return s.values(b, obj.Free)
+
+	case *object.Coroutine:
+		if obj.Status == object.CoroutineRunning {
+			return fmt.Errorf("cannot snapshot %s", obj.Inspect())
+		}
+		if err := s.value(b, obj.Closure); err != nil {
+			return err
+		}
+		b.uvarint(uint64(obj.Status))
+		b.bool(obj.Started)
+		b.varint(int64(obj.IP))
+		if err := s.values(b, obj.Stack); err != nil {
+			return err
+		}
+		b.uvarint(uint64(len(obj.Handlers)))
+		for _, h := range obj.Handlers {
+			b.uvarint(uint64(h.SP))
+			b.uvarint(uint64(h.Target))
+		}
+
+	case *object.Module:
+		names := make([]string, 0, len(obj.Members))
+		for name := range obj.Members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		b.string(obj.Name)
+		b.uvarint(uint64(len(names)))
+		for _, name := range names {
+			b.string(name)
+			if err := s.value(b, obj.Members[name]); err != nil {
+				return err
+			}
+		}
+
+	case *object.Iterator:
+		source, keys, length, pos := obj.State()
+		if err := s.value(b, source); err != nil {
+			return err
+		}
+		b.uvarint(uint64(len(keys)))
+		for _, key := range keys {
+			b.string(string(key.Type))
+			b.uvarint(key.Value)
+		}
+		b.uvarint(uint64(length))
+		b.uvarint(uint64(pos))
+
+	case *object.User:
+		if s.codec == nil {
+			return fmt.Errorf("cannot snapshot %s: no UserCodec was given", obj.Inspect())
+		}
+		data, err := s.codec.EncodeUser(obj.Value)
+		if err != nil {
+			return fmt.Errorf("cannot snapshot %s: %w", obj.Inspect(), err)
+		}
+		b.bytes(data)
+	}
+	return nil
+}
+
+// snapshotReader decodes a snapshot. The first error is kept in err, after
+// which every read returns a zero value.
+type snapshotReader struct {
+	vm      *VM
+	data    []byte
+	pos     int
+	objects []object.Object
+	err     error
+}
+
+func (d *snapshotReader) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (d *snapshotReader) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data)-d.pos {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *snapshotReader) byte() byte {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *snapshotReader) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad varint at offset %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return x
+}
+
+func (d *snapshotReader) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad varint at offset %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return x
+}
+
+// count reads a number of elements, each taking at least one byte.
+func (d *snapshotReader) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)-d.pos) {
+		d.fail("count %d exceeds the remaining data", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *snapshotReader) string() string {
+	return string(d.next(d.count()))
+}
+
+func (d *snapshotReader) value() object.Object {
+	switch tag := d.byte(); tag {
+	case tagNil:
+		return nil
+	case tagNull:
+		return Null
+	case tagTrue:
+		return True
+	case tagFalse:
+		return False
+	case tagInteger:
+		return &object.Integer{Value: d.varint()}
+	case tagFloat:
+		return &object.Float{Value: math.Float64frombits(d.uvarint())}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagBuiltin:
+		return d.builtin(d.string())
+	case tagConstant:
+		i := d.uvarint()
+		if i >= uint64(len(d.vm.constants)) {
+			d.fail("constant %d out of range", i)
+			return nil
+		}
+		return d.vm.constants[i]
+	case tagObject:
+		id := d.uvarint()
+		if id >= uint64(len(d.objects)) {
+			d.fail("object %d out of range", id)
+			return nil
+		}
+		return d.objects[id]
+	default:
+		d.fail("unknown value tag %d", tag)
+		return nil
+	}
+}
+
+func (d *snapshotReader) values() []object.Object {
+	n := d.count()
+	objs := make([]object.Object, n)
+	for i := range objs {
+		objs[i] = d.value()
+	}
+	return objs
+}
+
+// builtin finds a standard builtin or an enum method by name. Host builtins
+// cannot be found and become null.
+func (d *snapshotReader) builtin(name string) object.Object {
+	if b := object.GetBuiltinByName(name); b != nil {
+		return b
+	}
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		for _, constant := range d.vm.constants {
+			if enum, ok := constant.(*object.Enum); ok && enum.Name == name[:dot] {
+				if method, ok := enum.GetAttr(name[dot+1:]); ok {
+					return method
+				}
+			}
+		}
+	}
+	return Null
+}
+
+func (d *snapshotReader) contents(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		obj.Elements = d.values()
+
+	case *object.Tuple:
+		obj.Elements = d.values()
+
+	case *object.Hash:
+		n := d.count()
+		for i := 0; i < n && d.err == nil; i++ {
+			key, value := d.value(), d.value()
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				d.fail("unusable hash key %v", key)
+				return
+			}
+			obj.Pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+
+	case *object.Struct:
+		def, ok := d.value().(*object.StructType)
+		if !ok {
+			d.fail("struct without a struct type")
+			return
+		}
+		obj.Def, obj.Fields = def, d.values()
+		if d.err == nil && len(obj.Fields) != len(def.Fields) {
+			d.fail("struct %s with %d fields", def.Name, len(obj.Fields))
+		}
+
+	case *object.Cell:
+		obj.Value = d.value()
+
+	case *object.Closure:
+		fn, ok := d.value().(*object.CompiledFunction)
+		if !ok {
+			d.fail("closure without a function")
+			return
+		}
+		obj.Fn, obj.Free = fn, d.values()
+
+	case *object.Coroutine:
+		closure, ok := d.value().(*object.Closure)
+		if !ok {
+			d.fail("coroutine without a closure")
+			return
+		}
+		obj.Closure = closure
+		obj.Status = object.CoroutineStatus(d.uvarint())
+		obj.Started = d.byte() != 0
+		obj.IP = int(d.varint())
+		obj.Stack = d.values()
+		n := d.count()
+		for i := 0; i < n && d.err == nil; i++ {
+			obj.Handlers = append(obj.Handlers, object.CoroutineHandler{SP: int(d.uvarint()), Target: int(d.uvarint())})
+		}
+		if obj.Status != object.CoroutineSuspended && obj.Status != object.CoroutineDead {
+			d.fail("coroutine with status %s", obj.Status)
+		}
+
+	case *object.Module:
+		obj.Name = d.string()
+		n := d.count()
+		obj.Members = make(map[string]object.Object, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			name := d.string()
+			obj.Members[name] = d.value()
+		}
+
+	case *object.Iterator:
+		source := d.value()
+		n := d.count()
+		var keys []object.HashKey
+		for i := 0; i < n && d.err == nil; i++ {
+			keys = append(keys, object.HashKey{Type: object.ObjectType(d.string()), Value: d.uvarint()})
+		}
+		length := int(d.uvarint())
+		obj.SetState(source, keys, length, int(d.uvarint()))
+
+	case *object.User:
+		data := d.next(d.count())
+		if d.err != nil {
+			return
+		}
+		if d.vm.userCodec == nil {
+			d.fail("user value without a UserCodec")
+			return
+		}
+		value, err := d.vm.userCodec.DecodeUser(data)
+		if err != nil {
+			d.fail("decoding user value: %w", err)
+			return
+		}
+		obj.Value = value
+	}
+}
+
+// snapshotBuffer accumulates an encoded snapshot.
+type snapshotBuffer struct {
+	data []byte
+}
+
+func (b *snapshotBuffer) byte(c byte) {
+	b.data = append(b.data, c)
+}
+
+func (b *snapshotBuffer) bool(v bool) {
+	if v {
+		b.byte(1)
+	} else {
+		b.byte(0)
+	}
+}
+
+func (b *snapshotBuffer) uvarint(x uint64) {
+	b.data = binary.AppendUvarint(b.data, x)
+}
+
+func (b *snapshotBuffer) varint(x int64) {
+	b.data = binary.AppendVarint(b.data, x)
+}
+
+func (b *snapshotBuffer) bytes(data []byte) {
+	b.uvarint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *snapshotBuffer) string(s string) {
+	b.uvarint(uint64(len(s)))
+	b.data = append(b.data, s...)
+}
+
+// fingerprint identifies bytecode: its instructions and constants. A
+// snapshot holds instruction offsets and constant indexes, which only make
+// sense for the same bytecode.
+func fingerprint(instructions []byte, constants []object.Object) [sha256.Size]byte {
+	var b snapshotBuffer
+	b.bytes(instructions)
+	b.uvarint(uint64(len(constants)))
+
+	for _, constant := range constants {
+		b.string(string(constant.Type()))
+		switch c := constant.(type) {
+		case *object.Integer:
+			b.varint(c.Value)
+		case *object.Float:
+			b.uvarint(math.Float64bits(c.Value))
+		case *object.String:
+			b.string(c.Value)
+		case *object.Boolean:
+			b.bool(c.Value)
+		case *object.CompiledFunction:
+			b.bytes(c.Instructions)
+			b.uvarint(uint64(c.NumLocals))
+			b.uvarint(uint64(c.NumParameters))
+			b.uvarint(uint64(c.NumDefaults))
+			b.bool(c.Variadic)
+			b.bool(c.Generator)
+			b.uvarint(uint64(len(c.Entries)))
+			for _, entry := range c.Entries {
+				b.uvarint(uint64(entry))
+			}
+		case *object.StructType:
+			b.string(c.Name)
+			b.uvarint(uint64(len(c.Fields)))
+			for _, field := range c.Fields {
+				b.string(field)
+			}
+		case *object.Enum:
+			b.string(c.Name)
+			b.uvarint(uint64(len(c.Members)))
+			for i, member := range c.Members {
+				b.string(member)
+				b.varint(c.Values[i])
+			}
+		case *object.JumpTable:
+			keys := make([]int64, 0, len(c.Targets))
+			for key := range c.Targets {
+				keys = append(keys, key)
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+			b.uvarint(uint64(len(keys)))
+			for _, key := range keys {
+				b.varint(key)
+				b.uvarint(uint64(c.Targets[key]))
+			}
+			b.uvarint(uint64(c.Default))
+		}
+	}
+
+	return sha256.Sum256(b.data)
+}
@@ -0,0 +1,332 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+// snapshotRoundTrip runs input, snapshots the VM and restores the snapshot
+// into a new VM for the same bytecode.
+func snapshotRoundTrip(t *testing.T, bytecode *compiler.Bytecode, opts ...Option) *VM {
+	t.Helper()
+
+	machine := New(bytecode, opts...)
+	if err := machine.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := machine.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %s", err)
+	}
+	restored, err := Restore(&buf, bytecode, opts...)
+	if err != nil {
+		t.Fatalf("Restore: %s", err)
+	}
+	return restored
+}
+
+func mustGlobal(t *testing.T, machine *VM, name string) object.Object {
+	t.Helper()
+	value, err := machine.GetGlobal(name)
+	if err != nil {
+		t.Fatalf("GetGlobal(%s): %s", name, err)
+	}
+	return value
+}
+
+func TestSnapshotValues(t *testing.T) {
+	input := `
+var i = 42
+var f = 2.5
+var s = "hello"
+var b = true
+var n = null
+var h = {"a": [1, 2], 3: "x", true: 1.5}
+`
+	restored := snapshotRoundTrip(t, compileInput(t, input))
+
+	tests := map[string]string{"i": "42", "f": "2.500000", "s": "hello", "b": "true", "n": "null"}
+	for name, want := range tests {
+		if got := mustGlobal(t, restored, name).Inspect(); got != want {
+			t.Errorf("wrong %s. want=%s, got=%s", name, want, got)
+		}
+	}
+
+	h := mustGlobal(t, restored, "h").(*object.Hash)
+	for key, want := range map[object.Hashable]string{
+		&object.String{Value: "a"}: "[1, 2]",
+		&object.Integer{Value: 3}:  "x",
+		object.True:                "1.500000",
+	} {
+		pair, ok := h.Pairs[key.HashKey()]
+		if !ok || pair.Value.Inspect() != want {
+			t.Errorf("wrong value for %s. want=%s, got=%v", key.(object.Object).Inspect(), want, pair.Value)
+		}
+	}
+}
+
+func TestSnapshotSharedReferencesAndCycles(t *testing.T) {
+	input := `
+var a = [1]
+var pair = [a, a]
+var h = {}
+h["self"] = h
+push(a, h)
+`
+	restored := snapshotRoundTrip(t, compileInput(t, input))
+
+	a := mustGlobal(t, restored, "a").(*object.Array)
+	pair := mustGlobal(t, restored, "pair").(*object.Array)
+	if pair.Elements[0] != a || pair.Elements[1] != a {
+		t.Errorf("shared array was not restored as one object")
+	}
+
+	h := mustGlobal(t, restored, "h").(*object.Hash)
+	self := h.Pairs[(&object.String{Value: "self"}).HashKey()].Value
+	if self != h {
+		t.Errorf("cycle was not restored")
+	}
+	if a.Elements[1] != h {
+		t.Errorf("hash referenced from the array was not restored as one object")
+	}
+}
+
+func TestSnapshotClosures(t *testing.T) {
+	input := `
+func counter() {
+	var n = 0
+	return [func() { n++; return n }, func() { return n }]
+}
+var fns = counter()
+var next = fns[0]
+var peek = fns[1]
+next()
+next()
+`
+	restored := snapshotRoundTrip(t, compileInput(t, input))
+
+	next := mustGlobal(t, restored, "next")
+	peek := mustGlobal(t, restored, "peek")
+	if got, err := restored.Invoke(context.Background(), next); err != nil || got.Inspect() != "3" {
+		t.Fatalf("next() after restore: got %v, %v", got, err)
+	}
+	// Both closures still share the captured variable
+	if got, err := restored.Invoke(context.Background(), peek); err != nil || got.Inspect() != "3" {
+		t.Errorf("peek() after restore: got %v, %v", got, err)
+	}
+}
+
+func TestSnapshotSuspendedCoroutine(t *testing.T) {
+	input := `
+func gen(items) {
+	var total = 0
+	for _, v := range items {
+		total += v
+		try {
+			yield total
+		} catch {
+		}
+	}
+	return "done"
+}
+var co = gen([1, 2, 3])
+var first = co()
+`
+	restored := snapshotRoundTrip(t, compileInput(t, input))
+
+	co, ok := mustGlobal(t, restored, "co").(*object.Coroutine)
+	if !ok || co.Status != object.CoroutineSuspended || len(co.Handlers) != 1 {
+		t.Fatalf("wrong coroutine. got=%v", mustGlobal(t, restored, "co"))
+	}
+	for _, want := range []string{"3", "6", "done"} {
+		got, err := restored.Resume(context.Background(), co, nil)
+		if err != nil {
+			t.Fatalf("Resume: %s", err)
+		}
+		if got.Inspect() != want {
+			t.Errorf("wrong value. want=%s, got=%s", want, got.Inspect())
+		}
+	}
+	if !co.Done() {
+		t.Errorf("coroutine should be done")
+	}
+}
+
+func TestSnapshotStructsEnumsAndBuiltins(t *testing.T) {
+	input := `
+type Vec = struct { x, y }
+enum Dir { North, East }
+var v = Vec(1, 2)
+var d = Dir.East
+var name = Dir.name
+var length = len
+`
+	restored := snapshotRoundTrip(t, compileInput(t, input))
+
+	if got := mustGlobal(t, restored, "v").Inspect(); got != "Vec{x: 1, y: 2}" {
+		t.Errorf("wrong struct. got=%s", got)
+	}
+	if got := mustGlobal(t, restored, "d").Inspect(); got != "1" {
+		t.Errorf("wrong enum value. got=%s", got)
+	}
+	if b, ok := mustGlobal(t, restored, "name").(*object.Builtin); !ok || b.Name != "Dir.name" {
+		t.Errorf("enum method not restored. got=%v", mustGlobal(t, restored, "name"))
+	}
+	if b, ok := mustGlobal(t, restored, "length").(*object.Builtin); !ok || b != object.GetBuiltinByName("len") {
+		t.Errorf("builtin not restored. got=%v", mustGlobal(t, restored, "length"))
+	}
+}
+
+func TestSnapshotModules(t *testing.T) {
+	loader := compiler.ModuleLoaderFunc(func(path string) (string, error) {
+		return testModules[path], nil
+	})
+	comp := compiler.New(compiler.WithModuleLoader(loader))
+	if err := comp.Compile(parse(`import "utils"`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	restored := snapshotRoundTrip(t, comp.Bytecode())
+
+	utils, ok := mustGlobal(t, restored, "utils").(*object.Module)
+	if !ok || utils.Name != "utils" {
+		t.Fatalf("module not restored. got=%v", mustGlobal(t, restored, "utils"))
+	}
+	clamp, _ := utils.GetAttr("Clamp")
+	got, err := restored.Invoke(context.Background(), clamp, &object.Integer{Value: 20}, &object.Integer{Value: 0}, &object.Integer{Value: 5})
+	if err != nil || got.Inspect() != "5" {
+		t.Errorf("utils.Clamp after restore: got %v, %v", got, err)
+	}
+}
+
+type snapshotPlayer struct {
+	Name string
+}
+
+type playerCodec struct{}
+
+func (playerCodec) EncodeUser(value any) ([]byte, error) {
+	p, ok := value.(*snapshotPlayer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+	return []byte(p.Name), nil
+}
+
+func (playerCodec) DecodeUser(data []byte) (any, error) {
+	return &snapshotPlayer{Name: string(data)}, nil
+}
+
+func TestSnapshotUserValues(t *testing.T) {
+	comp := compiler.New()
+	sym := comp.SymbolTable().Define("player")
+	if err := comp.Compile(parse("var party = [player, player]")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	machine := New(bytecode)
+	machine.SetGlobal(sym.Index, &object.User{Value: &snapshotPlayer{Name: "ann"}})
+	if err := machine.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	err := machine.Snapshot(&bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "no UserCodec") {
+		t.Errorf("expected an error without a codec, got %v", err)
+	}
+
+	machine = New(bytecode, WithUserCodec(playerCodec{}))
+	machine.SetGlobal(sym.Index, &object.User{Value: &snapshotPlayer{Name: "ann"}})
+	if err := machine.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := machine.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %s", err)
+	}
+	restored, err := Restore(&buf, bytecode, WithUserCodec(playerCodec{}))
+	if err != nil {
+		t.Fatalf("Restore: %s", err)
+	}
+
+	player := mustGlobal(t, restored, "player").(*object.User)
+	if player.Value.(*snapshotPlayer).Name != "ann" {
+		t.Errorf("wrong player. got=%+v", player.Value)
+	}
+	if party := mustGlobal(t, restored, "party").(*object.Array); party.Elements[0] != player || party.Elements[1] != player {
+		t.Errorf("user value was not restored as one object")
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	bytecode := compileInput(t, "var a = [1, 2, 3]")
+	machine := New(bytecode)
+	if err := machine.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := machine.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %s", err)
+	}
+	data := buf.Bytes()
+
+	_, err := Restore(bytes.NewReader(data), compileInput(t, "var a = [1, 2, 4]"))
+	if !errors.Is(err, ErrBytecodeMismatch) {
+		t.Errorf("expected ErrBytecodeMismatch, got %v", err)
+	}
+
+	_, err = Restore(bytes.NewReader(data[:len(data)-2]), bytecode)
+	if err == nil || !strings.HasPrefix(err.Error(), "corrupt snapshot: ") {
+		t.Errorf("expected a corrupt snapshot error, got %v", err)
+	}
+
+	_, err = Restore(strings.NewReader("not a snapshot at all"), bytecode)
+	if err == nil || err.Error() != "not a snapshot" {
+		t.Errorf("wrong error for garbage. got=%v", err)
+	}
+
+	future := append([]byte(snapshotMagic), 2)
+	_, err = Restore(bytes.NewReader(future), bytecode)
+	if err == nil || err.Error() != "unsupported snapshot version 2" {
+		t.Errorf("wrong error for a future version. got=%v", err)
+	}
+}
+
+func TestSnapshotHostBuiltins(t *testing.T) {
+	comp := compiler.New()
+	sym := comp.SymbolTable().Define("host")
+	if err := comp.Compile(parse("var saved = host")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	machine := New(bytecode)
+	machine.SetGlobal(sym.Index, &object.Builtin{Fn: func(ctx object.BuiltinContext, args ...object.Object) object.Object {
+		return Null
+	}})
+	if err := machine.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := machine.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %s", err)
+	}
+	restored, err := Restore(&buf, bytecode)
+	if err != nil {
+		t.Fatalf("Restore: %s", err)
+	}
+	for _, name := range []string{"host", "saved"} {
+		if got := mustGlobal(t, restored, name); got != Null {
+			t.Errorf("host builtin %s should restore as null, got %v", name, got)
+		}
+	}
+}
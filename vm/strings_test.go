@@ -0,0 +1,96 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+)
+
+func TestStringIndexAndSlice(t *testing.T) {
+	tests := []vmTestCase{
+		{`"hello"[0]`, "h"},
+		{`"hello"[4]`, "o"},
+		{`"hello"[5]`, Null},
+		{`"hello"[-1]`, Null},
+		{`"héllo"[1]`, "é"},
+		{`"日本語"[2]`, "語"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[3:]`, "lo"},
+		{`"hello"[:]`, "hello"},
+		{`"héllo wörld"[1:4]`, "éll"},
+		{`"héllo wörld"[7:]`, "örld"},
+		{`"hello"[3:1]`, ""},
+		{`"hello"[:-1]`, ""},
+		{`"hello"[2:100]`, "llo"},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
+		{`var s = "héllo"; s[len(s) - 1]`, "o"},
+		{`var out = ""; var s = "añb"; for i := 0; i < len(s); i++ { out = s[i] + out }; out`, "bña"},
+		{`[1, 2, 3][:-1]`, []int{}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringLibrary(t *testing.T) {
+	tests := []vmTestCase{
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`len(split("a b  c", " "))`, 4},
+		{`join([], ",")`, ""},
+		{`trim("  hi \n")`, "hi"},
+		{`trim("--hi--", "-")`, "hi"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("HeLLo")`, "hello"},
+		{`replace("a.b.c", ".", "/")`, "a/b/c"},
+		{`replace("a.b.c", ".", "/", 1)`, "a/b.c"},
+		{`hasPrefix("/give sword", "/give")`, true},
+		{`hasPrefix("give", "/give")`, false},
+		{`hasSuffix("file.ice", ".ice")`, true},
+		{`indexOf("hello", "l")`, 2},
+		{`indexOf("héllo", "l")`, 2},
+		{`indexOf("hello", "z")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`join(runes("añb"), "|")`, "a|ñ|b"},
+		{`len(runes("日本語"))`, 3},
+		{`format("%s has %d hp", "orc", 12)`, "orc has 12 hp"},
+		{`format("%.2f", 3.14159)`, "3.14"},
+		{`format("%v %t %q", [1, 2], true, "x")`, `[1, 2] true "x"`},
+		{`sprintf("%03d", 7)`, "007"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringLibraryErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		errContains string
+	}{
+		{`split("a", 1)`, "arguments to `split` must be STRINGs, got INTEGER"},
+		{`upper()`, "wrong number of arguments. got=0, want=1"},
+		{`join(["a", 1], ",")`, "`join` element 1 must be STRING, got INTEGER"},
+		{`repeat("a", -1)`, "argument to `repeat` count must be positive"},
+		{`format(1)`, "first argument to `format` must be STRING"},
+		{`"abc"["x"]`, "index operator not supported: STRING"},
+		{`"abc"["a":]`, "slice start index must be INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run(context.Background())
+		if err == nil {
+			t.Fatalf("expected error for %q, got nil", tt.input)
+		}
+		if !strings.Contains(err.Error(), tt.errContains) {
+			t.Errorf("error for %q should contain %q, got %q", tt.input, tt.errContains, err.Error())
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/object"
+)
+
+func TestStructs(t *testing.T) {
+	tests := []vmTestCase{
+		{"type Vec = struct { x, y }; var v = Vec(3, 4); v.x * v.x + v.y * v.y", 25},
+		{"type Vec = struct { x, y }; var v = Vec(1, null); v.y", Null},
+		{"type Unit = struct { }; typeof(Unit())", "Unit"},
+		{"type Vec = struct { x, y }; var v = Vec(1, 2); v.x = 10; v.x", 10},
+		{"type Vec = struct { x, y }; var v = Vec(1, 2); v.y += 5; v.y++; v.y", 8},
+		{`type Vec = struct { x, y }; var v = Vec(1, 2); v["y"] = 7; v["x"] + v.y`, 8},
+		{"type Vec = struct { x, y }; var a = Vec(1, 2); var b = a; b.x = 5; a.x", 5},
+		{"type Vec = struct { x, y }; var a = Vec(1, 2); var b = Vec(1, 2); b.x = 5; a.x", 1},
+		{"type Ent = struct { name, pos }; type Vec = struct { x, y }; var e = Ent(\"orc\", Vec(0, 0)); e.pos.x = 3; e.pos.x", 3},
+		{"type Vec = struct { x, y }; format(\"%v\", Vec(1, \"a\"))", "Vec{x: 1, y: a}"},
+		{"type Vec = struct { x, y }; typeof(Vec(1, 2))", "Vec"},
+		{"type Vec = struct { x, y }; typeof(Vec)", "type"},
+		// Types are hoisted like functions
+		{"func origin() { return Vec(0, 0) }; type Vec = struct { x, y }; origin() is Vec", true},
+		{"func f() { type P = struct { a }; return P(4) }; f().a", 4},
+		{"type Vec = struct { x, y }; var s = 0; for _, v := range [Vec(1, 2), Vec(3, 4)] { s += v.x }; s", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStructIs(t *testing.T) {
+	tests := []vmTestCase{
+		{"type Vec = struct { x, y }; Vec(1, 2) is Vec", true},
+		{"type Vec = struct { x, y }; type Pt = struct { x, y }; Pt(1, 2) is Vec", false},
+		{"type Vec = struct { x, y }; 5 is Vec", false},
+		{`type Vec = struct { x, y }; {"x": 1, "y": 2} is Vec`, false},
+		{"type Vec = struct { x, y }; var T = Vec; Vec(1, 2) is T", true},
+		{`
+type Vec = struct { x, y }
+type Ent = struct { name }
+func kind(v) {
+	switch v is {
+	case int: return "int"
+	case Vec: return "vec"
+	case Ent: return "ent"
+	default: return "other"
+	}
+}
+kind(1) + "," + kind(Vec(1, 2)) + "," + kind(Ent("a")) + "," + kind("s")`, "int,vec,ent,other"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`type Ent = struct { hp }; var e = Ent(1); e.hpp`, "struct Ent has no field hpp"},
+		{`type Ent = struct { hp }; var e = Ent(1); e.hpp = 2`, "struct Ent has no field hpp"},
+		{`type Ent = struct { hp }; var e = Ent(1); e["hpp"]`, "struct Ent has no field hpp"},
+		{`type Ent = struct { hp }; var e = Ent(1); e["hpp"] = 2`, "struct Ent has no field hpp"},
+		{`type Ent = struct { hp }; var E = Ent; E(1, 2)`, "wrong number of values for struct Ent: want=1, got=2"},
+		{`type Ent = struct { hp }; var E = Ent; E()`, "wrong number of values for struct Ent: want=1, got=0"},
+		{`type Ent = struct { hp }; Ent(...[1, 2])`, "wrong number of values for struct Ent: want=1, got=2"},
+		{`type Ent = struct { hp, mp }; Ent(...[1])`, "wrong number of values for struct Ent: want=2, got=1"},
+		{`type Ent = struct { hp }; var e = Ent(1); e[0]`, "index operator not supported: STRUCT"},
+		{`var x = 5; 1 is x`, "right side of 'is' must be a type, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestStructFromModule(t *testing.T) {
+	tests := []vmTestCase{
+		{`import "shapes"; shapes.Origin() is shapes.Vec`, true},
+		{`import "shapes"; shapes.Vec(1, 2).y`, 2},
+		{`import "shapes"; typeof(shapes.Origin())`, "Vec"},
+	}
+
+	for _, tt := range tests {
+		vm, err := runWithModules(t, tt.input)
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestStructFromGo(t *testing.T) {
+	input := `
+type Vec = struct { x, y }
+func length2(v) { return v.x * v.x + v.y * v.y }
+`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	global, err := vm.GetGlobal("Vec")
+	if err != nil {
+		t.Fatalf("GetGlobal Vec: %s", err)
+	}
+	vec, ok := global.(*object.StructType)
+	if !ok {
+		t.Fatalf("Vec is %T, want *object.StructType", global)
+	}
+
+	v, err := vec.New([]object.Object{&object.Integer{Value: 3}, &object.Integer{Value: 4}})
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	length2, _ := vm.GetGlobal("length2")
+	result, err := vm.Invoke(context.Background(), length2, v)
+	if err != nil {
+		t.Fatalf("Invoke: %s", err)
+	}
+	testExpectedObject(t, 25, result)
+
+	if got := v.Inspect(); got != "Vec{x: 3, y: 4}" {
+		t.Errorf("wrong Inspect. got=%q", got)
+	}
+}
@@ -0,0 +1,126 @@
+package vm
+
+import "testing"
+
+func TestSwitch(t *testing.T) {
+	tests := []vmTestCase{
+		{`var r = ""; switch 2 { case 1: r = "one" case 2, 3: r = "two or three" default: r = "other" }; r`, "two or three"},
+		{`var r = ""; switch 3 { case 1: r = "one" case 2, 3: r = "two or three" }; r`, "two or three"},
+		{`var r = ""; switch 9 { case 1: r = "one" default: r = "other" }; r`, "other"},
+		{`var r = "none"; switch 9 { case 1: r = "one" }; r`, "none"},
+		{`var r = 0; switch "b" { case "a": r = 1 case "b": r = 2 }; r`, 2},
+		{`var r = 0; var k = "a"; switch k + "b" { case "a" + "b": r = 1 }; r`, 1},
+		{`var n = 0; func next() { n++; return n }; var r = 0; switch next() { case 5: r = 5 case 1: r = 1 }; [n, r]`, []int{1, 1}},
+		{`var r = 0; var x = 1; switch x { case 1: r = 1 case 1.0: r = 2 }; r`, 1},
+		{`var r = 0; switch 1.5 { case 1: r = 1 case 1.5: r = 2 }; r`, 2},
+		{`var r = 0; switch null { case null: r = 1 }; r`, 1},
+		{`var r = 0; switch [1] { case 1: r = 1 default: r = 2 }; r`, 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTaglessSwitch(t *testing.T) {
+	tests := []vmTestCase{
+		{`func sign(x) { switch { case x > 0: return "pos" case x < 0: return "neg" }; return "zero" }; sign(5) + sign(-5) + sign(0)`, "posnegzero"},
+		{`var r = 0; var x = 5; switch { case x > 3: r = 1 case x > 1: r = 2 }; r`, 1},
+		{`var r = 0; var x = 2; switch { case x > 3, x == 2: r = 1 default: r = 2 }; r`, 1},
+		{`var r = 0; switch { default: r = 3 }; r`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTypeSwitch(t *testing.T) {
+	input := `
+func kind(v) {
+    switch v is {
+    case int, float:
+        return "number"
+    case string:
+        return "string"
+    case array:
+        return "array"
+    case null:
+        return "null"
+    case bool:
+        return "bool"
+    }
+    return "other"
+}
+`
+	tests := []vmTestCase{
+		{input + "kind(1)", "number"},
+		{input + "kind(1.5)", "number"},
+		{input + `kind("a")`, "string"},
+		{input + "kind([1])", "array"},
+		{input + "kind(null)", "null"},
+		{input + "kind(true)", "bool"},
+		{input + "kind(len)", "other"},
+		{`var r = 0; switch 1 is int { case true: r = 1 case false: r = 2 }; r`, 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSwitchBreakAndContinue(t *testing.T) {
+	tests := []vmTestCase{
+		// break leaves the switch, not the loop
+		{`var out = []; for i := 0; i < 4; i++ { switch i { case 1: break; push(out, 100) }; push(out, i) }; out`, []int{0, 1, 2, 3}},
+		{`var out = []; for i := 0; i < 4; i++ { switch { case i == 1: break; push(out, 100) }; push(out, i) }; out`, []int{0, 1, 2, 3}},
+		// continue applies to the enclosing loop
+		{`var out = []; for i := 0; i < 4; i++ { switch i { case 1, 2: continue }; push(out, i) }; out`, []int{0, 3}},
+		{`var out = []; for i := 0; i < 4; i++ { switch "x" { case "x": if i == 2 { continue } }; push(out, i) }; out`, []int{0, 1, 3}},
+		// a labeled break can leave the loop from inside a switch
+		{`var out = []; outer: for i := 0; i < 4; i++ { switch i { case 2: break outer }; push(out, i) }; out`, []int{0, 1}},
+		{`var n = 0; for i := 0; i < 3; i++ { switch i { default: break }; n++ }; n`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSwitchJumpTable(t *testing.T) {
+	input := `
+const RUN = 1
+const STOP = RUN + 1
+func state(s) {
+    switch s {
+    case 0:
+        return "idle"
+    case RUN:
+        return "run"
+    case STOP, 3:
+        return "stop"
+    case -1:
+        return "error"
+    default:
+        return "unknown"
+    }
+}
+`
+	tests := []vmTestCase{
+		{input + "state(0)", "idle"},
+		{input + "state(1)", "run"},
+		{input + "state(2)", "stop"},
+		{input + "state(3)", "stop"},
+		{input + "state(-1)", "error"},
+		{input + "state(4)", "unknown"},
+		{input + "state(2.0)", "stop"},
+		{input + "state(2.5)", "unknown"},
+		{input + `state("1")`, "unknown"},
+		{input + "state(null)", "unknown"},
+		{`var r = "none"; switch 7 { case 1: r = "one" }; r`, "none"},
+		{`var r = 0; for i := 0; i < 3; i++ { switch i { case 1: break default: r += 10 } }; r`, 20},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSwitchInClosure(t *testing.T) {
+	tests := []vmTestCase{
+		{`func f(x) { var y = 10; return func() { switch x { case y: return "ten" default: return "other" } } }; f(10)()`, "ten"},
+		{`func f(x) { var r = 0; switch x { case 1: var y = 5; r = y }; return r }; f(1)`, 5},
+	}
+
+	runVmTests(t, tests)
+}
@@ -0,0 +1,355 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iceisfun/icescript/compiler"
+	"github.com/iceisfun/icescript/token"
+)
+
+func TestTryCatch(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var msg = ""
+			try {
+				panic("boom")
+			} catch (e) {
+				msg = e["message"]
+			}
+			msg
+			`,
+			"boom",
+		},
+		{
+			// Errors raised by builtins are catchable
+			`
+			var msg = ""
+			try {
+				int("abc")
+			} catch (e) {
+				msg = e["message"]
+			}
+			contains(msg, "abc")
+			`,
+			true,
+		},
+		{
+			// The handler unwinds frames back to the function that installed it
+			`
+			func inner(x) {
+				return 10 / x
+			}
+			func outer() {
+				return inner(0)
+			}
+			var e = null
+			try {
+				outer()
+			} catch (err) {
+				e = err
+			}
+			[e["line"], len(e["stack"])]
+			`,
+			[]int{3, 3},
+		},
+		{
+			`
+			func inner(x) {
+				return 10 / x
+			}
+			var fn = ""
+			try {
+				inner(0)
+			} catch (e) {
+				fn = e["function"]
+			}
+			fn
+			`,
+			"inner",
+		},
+		{
+			// The stack is restored, so execution continues inside expressions
+			`
+			func safeDiv(a, b) {
+				try {
+					return a / b
+				} catch {
+					return -1
+				}
+			}
+			100 + safeDiv(1, 0) + safeDiv(10, 2)
+			`,
+			104,
+		},
+		{
+			// Nested handlers: the inner catch rethrows
+			`
+			var log = []
+			try {
+				try {
+					panic("first")
+				} catch (e) {
+					push(log, 1)
+					panic("second")
+				}
+			} catch (e) {
+				push(log, 2)
+				if e["message"] == "second" {
+					push(log, 3)
+				}
+			}
+			log
+			`,
+			[]int{1, 2, 3},
+		},
+		{
+			// Handlers are uninstalled when the try block completes
+			`
+			var caught = 0
+			try {
+				1
+			} catch {
+				caught = caught + 1
+			}
+			try {
+				try {
+					2
+				} catch {
+					caught = caught + 10
+				}
+				panic("outer")
+			} catch {
+				caught = caught + 100
+			}
+			caught
+			`,
+			100,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTryFinally(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			var log = []
+			try {
+				push(log, 1)
+			} catch {
+				push(log, 2)
+			} finally {
+				push(log, 3)
+			}
+			log
+			`,
+			[]int{1, 3},
+		},
+		{
+			`
+			var log = []
+			try {
+				push(log, 1)
+				panic("x")
+				push(log, 2)
+			} catch {
+				push(log, 3)
+			} finally {
+				push(log, 4)
+			}
+			log
+			`,
+			[]int{1, 3, 4},
+		},
+		{
+			// An error escaping catch still runs finally before propagating
+			`
+			var log = []
+			try {
+				try {
+					panic("x")
+				} catch {
+					push(log, 1)
+					panic("y")
+				} finally {
+					push(log, 2)
+				}
+			} catch {
+				push(log, 3)
+			}
+			log
+			`,
+			[]int{1, 2, 3},
+		},
+		{
+			// try/finally without catch
+			`
+			var log = []
+			try {
+				try {
+					panic("x")
+				} finally {
+					push(log, 1)
+				}
+			} catch (e) {
+				push(log, len(e["message"]))
+			}
+			log
+			`,
+			[]int{1, 1},
+		},
+		{
+			// return runs finally and keeps the returned value
+			`
+			var log = []
+			func f() {
+				try {
+					return 42
+				} finally {
+					push(log, 1)
+				}
+				return 0
+			}
+			[f(), len(log)]
+			`,
+			[]int{42, 1},
+		},
+		{
+			// break and continue run finally and uninstall the handler
+			`
+			var log = []
+			for i := 0; i < 5; i = i + 1 {
+				try {
+					if i == 1 { continue }
+					if i == 3 { break }
+					push(log, i)
+				} finally {
+					push(log, 10 + i)
+				}
+			}
+			try {
+				panic("after loop")
+			} catch {
+				push(log, 99)
+			}
+			log
+			`,
+			[]int{0, 10, 11, 2, 12, 13, 99},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestUncaughtErrorAfterFinally(t *testing.T) {
+	input := `
+var ran = false
+func f() {
+	try {
+		panic("original")
+	} finally {
+		ran = true
+	}
+}
+f()
+`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err := vm.Run(context.Background())
+
+	var scriptErr *token.ScriptError
+	if !errors.As(err, &scriptErr) {
+		t.Fatalf("expected *token.ScriptError, got %T: %v", err, err)
+	}
+	if scriptErr.Message != "original" {
+		t.Errorf("wrong message. want=%q, got=%q", "original", scriptErr.Message)
+	}
+	if scriptErr.Line != 5 {
+		t.Errorf("wrong line. want=5, got=%d", scriptErr.Line)
+	}
+
+	ran, _ := vm.GetGlobal("ran")
+	if ran != True {
+		t.Errorf("finally block did not run")
+	}
+}
+
+func TestCancellationIsNotCatchable(t *testing.T) {
+	input := `
+var caught = false
+try {
+	for { }
+} catch {
+	caught = true
+}
+`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := vm.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	caught, _ := vm.GetGlobal("caught")
+	if caught != False {
+		t.Errorf("cancellation was caught by the script")
+	}
+}
+
+func TestInvokeDoesNotLeakHandlers(t *testing.T) {
+	input := `
+func risky(x) {
+	if x { panic("bad input") }
+	return 1
+}
+func guarded() {
+	try {
+		return risky(true)
+	} catch (e) {
+		return 2
+	}
+}
+`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(context.Background()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	guarded, _ := vm.GetGlobal("guarded")
+	risky, _ := vm.GetGlobal("risky")
+
+	for i := 0; i < 3; i++ {
+		result, err := vm.Invoke(context.Background(), guarded)
+		if err != nil {
+			t.Fatalf("guarded: %s", err)
+		}
+		testExpectedObject(t, 2, result)
+
+		_, err = vm.Invoke(context.Background(), risky, True)
+		if err == nil || !strings.Contains(err.Error(), "bad input") {
+			t.Fatalf("risky: expected uncaught error, got %v", err)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package vm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"os"
 	"sync"
@@ -46,12 +48,64 @@ type VM struct {
 	mu       sync.Mutex
 
 	printPrefix string
+
+	repanic bool // re-raise Go panics instead of converting them to ScriptErrors
+
+	handlers []handler // active try blocks, innermost last
+
+	budget       int64            // per Run, Invoke or Resume; 0 for no limit
+	opCosts      []int64          // indexed by opcode; nil when every instruction costs 1
+	builtinCosts map[string]int64 // extra cost of calling a builtin, by name
+	stats        Stats
+
+	maxArrayLength  int64 // 0 for no limit
+	maxHashSize     int64
+	maxStringLength int64
+	allocBudget     int64 // approximate bytes per Run, Invoke or Resume
+
+	main        *object.CompiledFunction
+	debugger    Debugger // nil unless a debugger is attached
+	debug       debugState
+	breakpoints map[breakpoint]bool
+
+	profiler *Profiler // nil unless a profiler is attached
+	prof     profileState
+
+	userCodec UserCodec
+}
+
+// handler is an installed try block. When a runtime error is raised, the VM
+// unwinds to the handler's frame and stack depth and resumes at target with
+// the error value on the stack.
+type handler struct {
+	framesIndex int
+	sp          int
+	target      int
+}
+
+// Option configures optional VM behavior at construction time.
+type Option func(*VM)
+
+// WithRepanic disables panic recovery. Go panics raised by builtins or the VM
+// itself propagate to the caller instead of being converted into ScriptErrors,
+// preserving the original Go stack trace for debugging.
+func WithRepanic() Option {
+	return func(vm *VM) {
+		vm.repanic = true
+	}
 }
 
 type Frame struct {
 	cl          *object.Closure
 	ip          int
 	basePointer int
+
+	co       *object.Coroutine // set when the frame runs a coroutine
+	iter     *object.Iterator  // set when a range loop resumed the coroutine
+	iterExit int               // where that loop continues once it finishes
+
+	debugLine int // the line a debugger last saw the frame on, and where
+	debugIP   int
 }
 
 func (vm *VM) Rand() *rand.Rand {
@@ -91,15 +145,15 @@ func (vm *VM) PrintPrefix() string {
 	return vm.printPrefix
 }
 
-func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap, Name: "main"}
+func New(bytecode *compiler.Bytecode, opts ...Option) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap, Name: "main", File: bytecode.FileName}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
 	frames := make([]*Frame, MaxFrames)
 	frames[0] = mainFrame
 
-	return &VM{
+	vm := &VM{
 		constants:   bytecode.Constants,
 		globals:     make([]object.Object, GlobalSize),
 		stack:       make([]object.Object, StackSize),
@@ -110,11 +164,18 @@ func New(bytecode *compiler.Bytecode) *VM {
 		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
 		output:      os.Stdout,
 		ctxStore:    make(map[string]any),
+		main:        mainFn,
+	}
+
+	for _, opt := range opts {
+		opt(vm)
 	}
+
+	return vm
 }
 
-func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
-	vm := New(bytecode)
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object, opts ...Option) *VM {
+	vm := New(bytecode, opts...)
 	vm.globals = s
 	return vm
 }
@@ -132,6 +193,7 @@ func (vm *VM) Instructions() []byte {
 func (vm *VM) Invoke(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
+	vm.stats = Stats{}
 
 	// 1. Validate function type
 	closure, ok := fn.(*object.Closure)
@@ -139,15 +201,10 @@ func (vm *VM) Invoke(ctx context.Context, fn object.Object, args ...object.Objec
 		return nil, fmt.Errorf("Invoke expected a function/closure, got %s", fn.Type())
 	}
 
-	// 2. Validate Arity
-	if len(args) != closure.Fn.NumParameters {
-		return nil, fmt.Errorf("wrong number of arguments: want=%d, got=%d", closure.Fn.NumParameters, len(args))
-	}
-
-	// 3. Prepare Stack
+	// 2. Prepare Stack
 	vm.sp = 0
 
-	// 4. Push Closure & Args
+	// 3. Push Closure & Args
 	err := vm.push(closure)
 	if err != nil {
 		return nil, err
@@ -160,25 +217,60 @@ func (vm *VM) Invoke(ctx context.Context, fn object.Object, args ...object.Objec
 		}
 	}
 
-	// 5. Setup Frame
-	// In OpCall, basePointer points to the first argument.
-	// vm.sp currently points after the last argument.
-	// So basePointer should be vm.sp - len(args).
-	basePointer := vm.sp - len(args)
-	frame := NewFrame(closure, basePointer)
+	// 4. Validate arity and setup Frame, as OpCall does
+	frame, err := vm.newClosureFrame(closure, len(args))
+	if err != nil {
+		return nil, err
+	}
+	if closure.Fn.Generator {
+		return vm.newCoroutine(frame), nil
+	}
 	vm.frames[0] = frame
 	vm.framesIndex = 1
 
 	// Set SP to reserve space for locals
 	vm.sp = frame.basePointer + closure.Fn.NumLocals
 
-	// 6. Run
-	err = vm.run(ctx)
+	// 5. Run
+	err = vm.run(ctx, len(vm.handlers))
 	if err != nil {
 		return nil, err
 	}
 
-	// 7. Get Return Value
+	// 6. Get Return Value
+	return vm.lastPopped, nil
+}
+
+// Resume runs co until it yields or returns and gives back the yielded or
+// returned value; co.Done reports which of the two it was. value becomes the
+// result of the yield the coroutine is suspended at, and is ignored when the
+// coroutine starts. Like Invoke, it is cancellable via ctx.
+func (vm *VM) Resume(ctx context.Context, co *object.Coroutine, value object.Object) (object.Object, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.stats = Stats{}
+
+	if value == nil {
+		value = Null
+	}
+
+	vm.sp = 0
+	vm.framesIndex = 0
+	handlerBase := len(vm.handlers)
+
+	err := vm.push(co)
+	if err != nil {
+		return nil, err
+	}
+	_, err = vm.resumeCoroutine(co, value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = vm.run(ctx, handlerBase)
+	if err != nil {
+		return nil, err
+	}
 	return vm.lastPopped, nil
 }
 
@@ -205,10 +297,47 @@ func (vm *VM) GetGlobal(name string) (object.Object, error) {
 func (vm *VM) Run(ctx context.Context) error {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
-	return vm.run(ctx)
+	vm.stats = Stats{}
+	return vm.run(ctx, len(vm.handlers))
+}
+
+// run executes until the outermost frame finishes. Only handlers installed
+// above handlerBase may catch its errors.
+func (vm *VM) run(ctx context.Context, handlerBase int) (err error) {
+	defer func() {
+		if err != nil {
+			vm.abandonCoroutines(0)
+		}
+	}()
+
+	if !vm.repanic {
+		// No script or builtin may crash the host: convert any Go panic that
+		// escapes the dispatch loop into a runtime error.
+		defer func() {
+			if r := recover(); r != nil {
+				err = vm.panicError("internal error: %v", r)
+			}
+		}()
+	}
+
+	defer func() { vm.handlers = vm.handlers[:handlerBase] }()
+
+	if vm.profiler != nil {
+		vm.profileStart()
+		defer vm.profileStop()
+	}
+
+	for {
+		err = vm.execute(ctx)
+		if err == nil || !vm.catchError(err, handlerBase) {
+			return err
+		}
+	}
 }
 
-func (vm *VM) run(ctx context.Context) error {
+// execute runs the dispatch loop until the outermost frame finishes or an
+// error is raised.
+func (vm *VM) execute(ctx context.Context) (err error) {
 	var (
 		ip  int
 		ins []byte
@@ -235,6 +364,23 @@ func (vm *VM) run(ctx context.Context) error {
 			}
 		}
 
+		if vm.debugger != nil {
+			vm.debugHook()
+		}
+		if vm.profiler != nil {
+			vm.profileHook()
+		}
+
+		vm.stats.Instructions++
+		cost := int64(1)
+		if vm.opCosts != nil {
+			cost = vm.opCosts[op]
+		}
+		err = vm.charge(cost)
+		if err != nil {
+			return err
+		}
+
 		switch op {
 		case opcode.OpConstant:
 			constIndex := opcode.ReadUint16(ins[ip+1:])
@@ -253,11 +399,26 @@ func (vm *VM) run(ctx context.Context) error {
 				return vm.newRuntimeError("%s", err.Error())
 			}
 
+		case opcode.OpDup2:
+			left, right := vm.stack[vm.sp-2], vm.stack[vm.sp-1]
+			err := vm.push(left)
+			if err == nil {
+				err = vm.push(right)
+			}
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
 		case opcode.OpAdd, opcode.OpSub, opcode.OpMul, opcode.OpDiv, opcode.OpMod:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return vm.newRuntimeError("%s", err.Error())
 			}
+		case opcode.OpBitAnd, opcode.OpBitOr, opcode.OpBitXor, opcode.OpBitAndNot, opcode.OpShiftLeft, opcode.OpShiftRight:
+			err := vm.executeBitwiseOperation(op)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
 		case opcode.OpEqual, opcode.OpNotEqual, opcode.OpGreaterThan:
 			err := vm.executeComparison(op)
 			if err != nil {
@@ -274,6 +435,16 @@ func (vm *VM) run(ctx context.Context) error {
 			if err != nil {
 				return vm.newRuntimeError("%s", err.Error())
 			}
+		case opcode.OpBitNot:
+			operand := vm.pop()
+			value, ok := operand.(*object.Integer)
+			if !ok {
+				return vm.newRuntimeError("unsupported type for bitwise complement: %s", operand.Type())
+			}
+			err := vm.push(&object.Integer{Value: ^value.Value})
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
 
 		case opcode.OpTrue:
 			err := vm.push(True)
@@ -294,6 +465,10 @@ func (vm *VM) run(ctx context.Context) error {
 		case opcode.OpJump:
 			pos := int(opcode.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip = pos - 1
+		case opcode.OpJumpTable:
+			tableIndex := opcode.ReadUint16(ins[ip+1:])
+			table := vm.constants[tableIndex].(*object.JumpTable)
+			vm.currentFrame().ip = table.Target(vm.pop()) - 1
 		case opcode.OpJumpNotTruthy:
 			pos := int(opcode.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -306,6 +481,24 @@ func (vm *VM) run(ctx context.Context) error {
 			if !result {
 				vm.currentFrame().ip = pos - 1
 			}
+		case opcode.OpJumpNull:
+			pos := int(opcode.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if _, ok := vm.pop().(*object.Null); ok {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case opcode.OpInspect:
+			value := vm.stack[vm.sp-1]
+			if _, ok := value.(*object.String); !ok {
+				s := value.Inspect()
+				err := vm.AllocString(int64(len(s)))
+				if err != nil {
+					return vm.newRuntimeError("%s", err.Error())
+				}
+				vm.stack[vm.sp-1] = &object.String{Value: s}
+			}
 
 		case opcode.OpSetGlobal:
 			globalIndex := opcode.ReadUint16(ins[ip+1:])
@@ -335,6 +528,33 @@ func (vm *VM) run(ctx context.Context) error {
 				return vm.newRuntimeError("%s", err.Error())
 			}
 
+		case opcode.OpNewCell:
+			localIndex := opcode.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = &object.Cell{Value: unwrapTuple(vm.pop())}
+
+		case opcode.OpSetCell:
+			localIndex := opcode.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.localCell(int(localIndex)).Value = unwrapTuple(vm.pop())
+
+		case opcode.OpGetCell:
+			localIndex := opcode.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err := vm.push(vm.localCell(int(localIndex)).Value)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+		case opcode.OpLoadLocalCell:
+			localIndex := opcode.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err := vm.push(vm.localCell(int(localIndex)))
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
 		case opcode.OpArray:
 			numElements := int(opcode.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -342,11 +562,15 @@ func (vm *VM) run(ctx context.Context) error {
 			if vm.sp-numElements < 0 {
 				return vm.newRuntimeError("stack underflow in OpArray")
 			}
+			err := vm.AllocArray(int64(numElements), int64(numElements))
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
 
 			array := vm.buildArray(vm.sp-numElements, vm.sp)
 			vm.sp = vm.sp - numElements
 
-			err := vm.push(array)
+			err = vm.push(array)
 			if err != nil {
 				return vm.newRuntimeError("%s", err.Error())
 			}
@@ -358,6 +582,10 @@ func (vm *VM) run(ctx context.Context) error {
 			if vm.sp-numElements < 0 {
 				return vm.newRuntimeError("stack underflow in OpHash")
 			}
+			err := vm.AllocHash(int64(numElements/2), int64(numElements/2))
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
 
 			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
 			if err != nil {
@@ -411,73 +639,55 @@ func (vm *VM) run(ctx context.Context) error {
 			numArgs := opcode.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 
-			// Callee is on stack before args
-			callee := vm.stack[vm.sp-1-int(numArgs)]
+			err := vm.callValue(int(numArgs))
+			if err != nil {
+				return err
+			}
 
-			switch callee := callee.(type) {
-			case *object.Closure:
-				if int(numArgs) != callee.Fn.NumParameters {
-					return vm.newRuntimeError("wrong number of arguments: want=%d, got=%d", callee.Fn.NumParameters, numArgs)
-				}
-				frame := NewFrame(callee, vm.sp-int(numArgs))
-				err := vm.pushFrame(frame)
-				if err != nil {
-					return vm.newRuntimeError("%s", err.Error())
-				}
-				vm.sp = frame.basePointer + callee.Fn.NumLocals // Reserve space? Or stack grows dynamically?
-
-			case *object.Builtin:
-				args := vm.stack[vm.sp-int(numArgs) : vm.sp] // Get args slice
-				result := callee.Fn(vm, args...)
-				vm.sp = vm.sp - int(numArgs) - 1 // Pop args and function
-				if result != nil {
-					if rtErr, ok := result.(*object.Panic); ok {
-						return vm.newRuntimeError("%s", rtErr.Message)
-					}
-					if crit, ok := result.(*object.Critical); ok {
-						return vm.newRuntimeError("%s", crit.Message)
-					}
-					vm.push(result)
-				} else {
-					vm.push(Null)
-				}
+		case opcode.OpCallSpread:
+			numSegments := int(opcode.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
 
-			default:
-				return vm.newRuntimeError("calling non-function")
+			numArgs, err := vm.spreadArguments(numSegments)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+			err = vm.callValue(numArgs)
+			if err != nil {
+				return err
 			}
 
 		case opcode.OpReturnValue:
 			returnValue := vm.pop()
-			vm.lastPopped = returnValue
 
-			if vm.framesIndex == 1 {
-				// Returning from main frame
-				vm.popFrame()
+			finished, err := vm.returnFromFrame(returnValue)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+			if finished {
 				return nil
 			}
 
-			frame := vm.popFrame()
-			vm.sp = frame.basePointer - 1 // -1 to pop the function/closure itself
-
-			err := vm.push(returnValue)
+		case opcode.OpReturn:
+			finished, err := vm.returnFromFrame(Null)
 			if err != nil {
 				return vm.newRuntimeError("%s", err.Error())
 			}
-
-		case opcode.OpReturn:
-			vm.lastPopped = Null
-			if vm.framesIndex == 1 {
-				vm.popFrame()
+			if finished {
 				return nil
 			}
 
-			frame := vm.popFrame()
-			vm.sp = frame.basePointer - 1
+		case opcode.OpYield:
+			value := vm.pop()
 
-			err := vm.push(Null)
+			finished, err := vm.yieldFrame(value)
 			if err != nil {
 				return vm.newRuntimeError("%s", err.Error())
 			}
+			if finished {
+				return nil
+			}
 
 		case opcode.OpClosure:
 			constIndex := opcode.ReadUint16(ins[ip+1:])
@@ -497,6 +707,23 @@ func (vm *VM) run(ctx context.Context) error {
 			freeIndex := opcode.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex].(*object.Cell).Value)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+		case opcode.OpSetFree:
+			freeIndex := opcode.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].(*object.Cell).Value = unwrapTuple(vm.pop())
+
+		case opcode.OpLoadFreeCell:
+			freeIndex := opcode.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
 			currentClosure := vm.currentFrame().cl
 			err := vm.push(currentClosure.Free[freeIndex])
 			if err != nil {
@@ -573,6 +800,8 @@ func (vm *VM) run(ctx context.Context) error {
 				match = obj.Type() == object.USER_OBJ
 			case opcode.VMTypeTuple:
 				match = obj.Type() == object.TUPLE_OBJ
+			case opcode.VMTypeCoroutine:
+				match = obj.Type() == object.COROUTINE_OBJ
 			default:
 				return vm.newRuntimeError("unknown type ID in OpIs: %d", typeID)
 			}
@@ -589,6 +818,77 @@ func (vm *VM) run(ctx context.Context) error {
 				}
 			}
 
+		case opcode.OpIsType:
+			typ := vm.pop()
+			obj := vm.pop()
+
+			match, err := isInstance(obj, typ)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+			err = vm.push(nativeBoolToBooleanObject(match))
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+		case opcode.OpIterInit:
+			iterable := vm.pop()
+			iter, ok := object.NewIterator(iterable)
+			if !ok {
+				return vm.newRuntimeError("cannot range over %s", iterable.Type())
+			}
+
+			err := vm.push(iter)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+		case opcode.OpIterNext:
+			pos := int(opcode.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iter, ok := vm.pop().(*object.Iterator)
+			if !ok {
+				return vm.newRuntimeError("OpIterNext expects an iterator")
+			}
+
+			if co, ok := iter.Coroutine(); ok {
+				if co.Done() {
+					vm.currentFrame().ip = pos - 1
+					continue
+				}
+
+				// The coroutine hands its next value to the loop when it
+				// yields, or ends the loop when it returns
+				err := vm.push(co)
+				if err != nil {
+					return vm.newRuntimeError("%s", err.Error())
+				}
+				frame, err := vm.resumeCoroutine(co, Null)
+				if err != nil {
+					return vm.newRuntimeError("%s", err.Error())
+				}
+				frame.iter = iter
+				frame.iterExit = pos
+				continue
+			}
+
+			key, value, ok := iter.Next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+
+			err := vm.push(key)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+			err = vm.push(value)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
 		case opcode.OpSetIndex:
 			val := vm.pop()
 			index := vm.pop()
@@ -598,6 +898,60 @@ func (vm *VM) run(ctx context.Context) error {
 			if err != nil {
 				return vm.newRuntimeError("%s", err.Error())
 			}
+
+		case opcode.OpGetAttr:
+			nameIndex := opcode.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[nameIndex].(*object.String)
+			err := vm.executeGetAttr(vm.pop(), name)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+		case opcode.OpSetAttr:
+			nameIndex := opcode.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[nameIndex].(*object.String)
+			val := unwrapTuple(vm.pop())
+			err := vm.executeSetAttr(vm.pop(), name, val)
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+		case opcode.OpModule:
+			nameIndex := opcode.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[nameIndex].(*object.String)
+			exports := vm.pop().(*object.Hash)
+
+			members := make(map[string]object.Object, len(exports.Pairs))
+			for _, pair := range exports.Pairs {
+				members[pair.Key.(*object.String).Value] = pair.Value
+			}
+
+			err := vm.push(&object.Module{Name: name.Value, Members: members})
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+
+		case opcode.OpTry:
+			target := int(opcode.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{
+				framesIndex: vm.framesIndex,
+				sp:          vm.sp,
+				target:      target,
+			})
+
+		case opcode.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case opcode.OpThrow:
+			return vm.throwError(vm.pop())
 		}
 	}
 	return nil
@@ -624,7 +978,7 @@ func (e *RuntimeError) Error() string {
 	msg = fmt.Sprintf("Runtime error: %s\n", e.Message)
 	msg += "Stack trace:\n"
 	for _, f := range e.Stack {
-		msg += fmt.Sprintf("  at %s (%s:%d)\n", f.FunctionName, "script.ice", f.Line)
+		msg += fmt.Sprintf("  at %s (%s:%d)\n", f.FunctionName, f.FileName, f.Line)
 	}
 	return msg
 }
@@ -635,6 +989,24 @@ type StackFrameInfo struct {
 	Line         int
 }
 
+// defaultFileName is reported for code compiled without compiler.WithFileName.
+const defaultFileName = "script.ice"
+
+// functionName names fn in debugger frames and profiles.
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name != "" {
+		return fn.Name
+	}
+	return "<anonymous>"
+}
+
+func fileNameOf(fn *object.CompiledFunction) string {
+	if fn.File != "" {
+		return fn.File
+	}
+	return defaultFileName
+}
+
 func (vm *VM) newRuntimeError(format string, args ...interface{}) error {
 	msg := fmt.Sprintf(format, args...)
 
@@ -642,7 +1014,7 @@ func (vm *VM) newRuntimeError(format string, args ...interface{}) error {
 
 	// Default values
 	line := 0
-	fileName := "script.ice" // Default filename
+	fileName := defaultFileName
 	functionName := ""
 
 	if currentFrame != nil {
@@ -654,6 +1026,7 @@ func (vm *VM) newRuntimeError(format string, args ...interface{}) error {
 			line = translateIPToLine(currentFrame.cl.Fn.SourceMap, rawIP)
 
 			functionName = currentFrame.cl.Fn.Name
+			fileName = fileNameOf(currentFrame.cl.Fn)
 		}
 	}
 
@@ -667,7 +1040,12 @@ func (vm *VM) newRuntimeError(format string, args ...interface{}) error {
 			if fname == "" {
 				fname = "anonymous"
 			}
-			stackTrace = append(stackTrace, fmt.Sprintf("%s (line %d)", fname, translateIPToLine(f.cl.Fn.SourceMap, f.ip)))
+			line := translateIPToLine(f.cl.Fn.SourceMap, f.ip)
+			if f.cl.Fn.File != "" {
+				stackTrace = append(stackTrace, fmt.Sprintf("%s (%s:%d)", fname, f.cl.Fn.File, line))
+			} else {
+				stackTrace = append(stackTrace, fmt.Sprintf("%s (line %d)", fname, line))
+			}
 		}
 	}
 
@@ -681,6 +1059,112 @@ func (vm *VM) newRuntimeError(format string, args ...interface{}) error {
 	}
 }
 
+// catchError transfers control to the innermost handler installed since
+// handlerBase. It reports false if the error is not catchable: only script
+// errors are, so context cancellation and internal errors always propagate.
+func (vm *VM) catchError(err error, handlerBase int) bool {
+	var scriptErr *token.ScriptError
+	if len(vm.handlers) <= handlerBase || !errors.As(err, &scriptErr) {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.abandonCoroutines(h.framesIndex)
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.target - 1
+	vm.push(errorValue(scriptErr))
+	return true
+}
+
+// errorValue converts a script error into the hash bound by catch.
+func errorValue(err *token.ScriptError) *object.Hash {
+	stack := make([]object.Object, len(err.StackTrace))
+	for i, frame := range err.StackTrace {
+		stack[i] = &object.String{Value: frame}
+	}
+
+	fields := []object.HashPair{
+		{Key: &object.String{Value: "message"}, Value: &object.String{Value: err.Message}},
+		{Key: &object.String{Value: "line"}, Value: &object.Integer{Value: int64(err.Line)}},
+		{Key: &object.String{Value: "function"}, Value: &object.String{Value: err.Function}},
+		{Key: &object.String{Value: "stack"}, Value: &object.Array{Elements: stack}},
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair, len(fields))
+	for _, pair := range fields {
+		pairs[pair.Key.(object.Hashable).HashKey()] = pair
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+// throwError re-raises an error value left over by a finally block. Error
+// hashes produced by catch keep their original location and stack trace.
+func (vm *VM) throwError(value object.Object) error {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return vm.newRuntimeError("%s", value.Inspect())
+	}
+
+	field := func(name string) object.Object {
+		pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+		if !ok {
+			return nil
+		}
+		return pair.Value
+	}
+
+	scriptErr := vm.newRuntimeError("%s", hash.Inspect()).(*token.ScriptError)
+	if message, ok := field("message").(*object.String); ok {
+		scriptErr.Message = message.Value
+	}
+	if line, ok := field("line").(*object.Integer); ok {
+		scriptErr.Line = int(line.Value)
+	}
+	if function, ok := field("function").(*object.String); ok {
+		scriptErr.Function = function.Value
+	}
+	if stack, ok := field("stack").(*object.Array); ok {
+		scriptErr.StackTrace = scriptErr.StackTrace[:0]
+		for _, frame := range stack.Elements {
+			scriptErr.StackTrace = append(scriptErr.StackTrace, frame.Inspect())
+		}
+	}
+	return scriptErr
+}
+
+// callBuiltin invokes a host or standard library builtin. A Go panic inside
+// the builtin is recovered and reported at the calling script location.
+func (vm *VM) callBuiltin(b *object.Builtin, args []object.Object) (result object.Object, err error) {
+	if !vm.repanic {
+		defer func() {
+			if r := recover(); r != nil {
+				name := b.Name
+				if name == "" {
+					name = "anonymous"
+				}
+				err = vm.panicError("builtin %s panicked: %v", name, r)
+			}
+		}()
+	}
+
+	return b.Fn(vm, args...), nil
+}
+
+// panicError builds a runtime error for a recovered Go panic. The VM may be
+// in an inconsistent state, so frame information is only used if it is intact.
+func (vm *VM) panicError(format string, args ...interface{}) error {
+	if vm.framesIndex < 1 || vm.framesIndex > len(vm.frames) || vm.currentFrame() == nil {
+		return &token.ScriptError{
+			Kind:    token.ErrorKindRuntime,
+			Message: fmt.Sprintf(format, args...),
+		}
+	}
+	return vm.newRuntimeError(format, args...)
+}
+
 func translateIPToLine(sourceMap map[int]int, ip int) int {
 	// Search backwards from the current IP to find the instruction start
 	// ip points to the *next* instruction or the middle of current one depending on error context.
@@ -715,7 +1199,7 @@ func (vm *VM) stackTrace() []StackFrameInfo {
 
 		info := StackFrameInfo{
 			FunctionName: name,
-			FileName:     "script.ice",
+			FileName:     fileNameOf(frame.cl.Fn),
 			Line:         line,
 		}
 		stack = append(stack, info)
@@ -751,7 +1235,10 @@ func (vm *VM) executeBinaryOperation(op opcode.Opcode) error {
 	if leftType == object.STRING_OBJ && rightType == object.STRING_OBJ {
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
-	// floats, strings...
+	if isNumeric(leftType) && isNumeric(rightType) {
+		// At least one operand is a float; promote the other
+		return vm.executeBinaryFloatOperation(op, left, right)
+	}
 
 	return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
 }
@@ -771,14 +1258,85 @@ func (vm *VM) executeBinaryIntegerOperation(
 	case opcode.OpMul:
 		return vm.push(&object.Integer{Value: leftVal * rightVal})
 	case opcode.OpDiv:
+		if rightVal == 0 {
+			return fmt.Errorf("integer division by zero")
+		}
 		return vm.push(&object.Integer{Value: leftVal / rightVal})
 	case opcode.OpMod:
+		if rightVal == 0 {
+			return fmt.Errorf("integer modulo by zero")
+		}
 		return vm.push(&object.Integer{Value: leftVal % rightVal})
 	default:
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
 }
 
+// executeBitwiseOperation applies a bitwise or shift operator, which are only
+// defined for integers. Shifts follow Go: >> is arithmetic and shifting by 64
+// or more bits yields 0 (or -1 for a negative value shifted right).
+func (vm *VM) executeBitwiseOperation(op opcode.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return fmt.Errorf("unsupported types for bitwise operation: %s %s", left.Type(), right.Type())
+	}
+
+	var result int64
+	switch op {
+	case opcode.OpBitAnd:
+		result = l.Value & r.Value
+	case opcode.OpBitOr:
+		result = l.Value | r.Value
+	case opcode.OpBitXor:
+		result = l.Value ^ r.Value
+	case opcode.OpBitAndNot:
+		result = l.Value &^ r.Value
+	case opcode.OpShiftLeft, opcode.OpShiftRight:
+		if r.Value < 0 {
+			return fmt.Errorf("negative shift count: %d", r.Value)
+		}
+		if op == opcode.OpShiftLeft {
+			result = l.Value << r.Value
+		} else {
+			result = l.Value >> r.Value
+		}
+	default:
+		return fmt.Errorf("unknown bitwise operator: %d", op)
+	}
+	return vm.push(&object.Integer{Value: result})
+}
+
+func (vm *VM) executeBinaryFloatOperation(
+	op opcode.Opcode,
+	left, right object.Object,
+) error {
+	leftVal, _ := left.AsFloat()
+	rightVal, _ := right.AsFloat()
+
+	switch op {
+	case opcode.OpAdd:
+		return vm.push(&object.Float{Value: leftVal + rightVal})
+	case opcode.OpSub:
+		return vm.push(&object.Float{Value: leftVal - rightVal})
+	case opcode.OpMul:
+		return vm.push(&object.Float{Value: leftVal * rightVal})
+	case opcode.OpDiv:
+		return vm.push(&object.Float{Value: leftVal / rightVal})
+	case opcode.OpMod:
+		return vm.push(&object.Float{Value: math.Mod(leftVal, rightVal)})
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+}
+
+func isNumeric(t object.ObjectType) bool {
+	return t == object.INTEGER_OBJ || t == object.FLOAT_OBJ
+}
+
 func (vm *VM) executeBinaryStringOperation(
 	op opcode.Opcode,
 	left, right object.Object,
@@ -788,6 +1346,10 @@ func (vm *VM) executeBinaryStringOperation(
 
 	switch op {
 	case opcode.OpAdd:
+		err := vm.AllocString(int64(len(leftVal) + len(rightVal)))
+		if err != nil {
+			return err
+		}
 		return vm.push(&object.String{Value: leftVal + rightVal})
 	default:
 		return fmt.Errorf("unknown string operator: %d", op)
@@ -802,7 +1364,8 @@ func (vm *VM) executeComparison(op opcode.Opcode) error {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
-	if left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ {
+	if isNumeric(left.Type()) && isNumeric(right.Type()) {
+		// Float/float or mixed int/float: compare as floats
 		return vm.executeFloatComparison(op, left, right)
 	}
 
@@ -882,8 +1445,8 @@ func (vm *VM) executeFloatComparison(
 	op opcode.Opcode,
 	left, right object.Object,
 ) error {
-	leftVal := left.(*object.Float).Value
-	rightVal := right.(*object.Float).Value
+	leftVal, _ := left.AsFloat()
+	rightVal, _ := right.AsFloat()
 
 	switch op {
 	case opcode.OpEqual:
@@ -1004,8 +1567,12 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.STRUCT_OBJ && index.Type() == object.STRING_OBJ:
+		return vm.executeGetAttr(left, index.(*object.String))
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
 	}
@@ -1017,11 +1584,51 @@ func (vm *VM) executeSetIndexExpression(left, index, val object.Object) error {
 		return vm.executeArraySetIndex(left, index, val)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashSetIndex(left, index, val)
+	case left.Type() == object.STRUCT_OBJ && index.Type() == object.STRING_OBJ:
+		return vm.executeSetAttr(left, index.(*object.String), val)
 	default:
 		return fmt.Errorf("index assignment not supported: %s", left.Type())
 	}
 }
 
+// executeGetAttr implements obj.name: string-keyed lookup on hashes, or
+// attribute access on host values implementing object.AttrGetter.
+func (vm *VM) executeGetAttr(obj object.Object, name *object.String) error {
+	switch target := obj.(type) {
+	case *object.Hash:
+		return vm.executeHashIndex(target, name)
+	case *object.Struct:
+		val, err := target.Field(name.Value)
+		if err != nil {
+			return err
+		}
+		return vm.push(val)
+	case object.AttrGetter:
+		val, ok := target.GetAttr(name.Value)
+		if !ok || val == nil {
+			return fmt.Errorf("unknown attribute %s on %s", name.Value, obj.Inspect())
+		}
+		return vm.push(val)
+	default:
+		return fmt.Errorf("attribute access not supported: %s", obj.Type())
+	}
+}
+
+func (vm *VM) executeSetAttr(obj object.Object, name *object.String, val object.Object) error {
+	switch target := obj.(type) {
+	case *object.Hash:
+		return vm.executeHashSetIndex(target, name, val)
+	case object.AttrSetter:
+		err := target.SetAttr(name.Value, val)
+		if err != nil {
+			return err
+		}
+		return vm.push(val)
+	default:
+		return fmt.Errorf("attribute assignment not supported: %s", obj.Type())
+	}
+}
+
 func (vm *VM) executeArraySetIndex(array, index, val object.Object) error {
 	arrayObject := array.(*object.Array)
 	i := index.(*object.Integer).Value
@@ -1043,7 +1650,14 @@ func (vm *VM) executeHashSetIndex(hash, index, val object.Object) error {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
 	}
 
-	hashObject.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+	hashKey := key.HashKey()
+	if _, exists := hashObject.Pairs[hashKey]; !exists {
+		err := vm.AllocHash(int64(len(hashObject.Pairs))+1, 1)
+		if err != nil {
+			return err
+		}
+	}
+	hashObject.Pairs[hashKey] = object.HashPair{Key: index, Value: val}
 	return vm.push(val)
 }
 
@@ -1059,6 +1673,24 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	return vm.push(arrayObject.Elements[i])
 }
 
+// executeStringIndex returns the i-th rune of a string as a one-character
+// string. Like arrays, an out-of-range index yields null.
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	value := str.(*object.String).Value
+	i := index.(*object.Integer).Value
+
+	if i >= 0 {
+		for _, r := range value {
+			if i == 0 {
+				return vm.push(&object.String{Value: string(r)})
+			}
+			i--
+		}
+	}
+
+	return vm.push(Null)
+}
+
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
@@ -1075,27 +1707,59 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 }
 
 func (vm *VM) executeSliceExpression(left, start, end object.Object) error {
-	if left.Type() != object.ARRAY_OBJ {
+	switch left := left.(type) {
+	case *object.Array:
+		elements := left.Elements
+		startIndex, endIndex, err := sliceBounds(start, end, int64(len(elements)))
+		if err != nil {
+			return err
+		}
+
+		err = vm.AllocArray(endIndex-startIndex, endIndex-startIndex)
+		if err != nil {
+			return err
+		}
+		newElements := make([]object.Object, endIndex-startIndex)
+		copy(newElements, elements[startIndex:endIndex])
+
+		return vm.push(&object.Array{Elements: newElements})
+
+	case *object.String:
+		// Slice indexes count runes, not bytes
+		runes := []rune(left.Value)
+		startIndex, endIndex, err := sliceBounds(start, end, int64(len(runes)))
+		if err != nil {
+			return err
+		}
+
+		value := string(runes[startIndex:endIndex])
+		err = vm.AllocString(int64(len(value)))
+		if err != nil {
+			return err
+		}
+		return vm.push(&object.String{Value: value})
+
+	default:
 		return fmt.Errorf("slice operator not supported: %s", left.Type())
 	}
+}
 
-	arrayObject := left.(*object.Array)
-	elements := arrayObject.Elements
-	length := int64(len(elements))
-
+// sliceBounds resolves the optional start and end of a slice expression and
+// clamps them to [0, length].
+func sliceBounds(start, end object.Object, length int64) (int64, int64, error) {
 	var startIndex int64 = 0
 	var endIndex int64 = length
 
 	if start != Null {
 		if start.Type() != object.INTEGER_OBJ {
-			return fmt.Errorf("slice start index must be INTEGER, got %s", start.Type())
+			return 0, 0, fmt.Errorf("slice start index must be INTEGER, got %s", start.Type())
 		}
 		startIndex = start.(*object.Integer).Value
 	}
 
 	if end != Null {
 		if end.Type() != object.INTEGER_OBJ {
-			return fmt.Errorf("slice end index must be INTEGER, got %s", end.Type())
+			return 0, 0, fmt.Errorf("slice end index must be INTEGER, got %s", end.Type())
 		}
 		endIndex = end.(*object.Integer).Value
 	}
@@ -1107,14 +1771,14 @@ func (vm *VM) executeSliceExpression(left, start, end object.Object) error {
 	if endIndex > length {
 		endIndex = length
 	}
+	if endIndex < 0 {
+		endIndex = 0
+	}
 	if startIndex > endIndex {
 		startIndex = endIndex
 	}
 
-	newElements := make([]object.Object, endIndex-startIndex)
-	copy(newElements, elements[startIndex:endIndex])
-
-	return vm.push(&object.Array{Elements: newElements})
+	return startIndex, endIndex, nil
 }
 
 func (vm *VM) currentFrame() *Frame {
@@ -1135,6 +1799,321 @@ func (vm *VM) popFrame() *Frame {
 	return vm.frames[vm.framesIndex]
 }
 
+// localCell returns the cell holding a captured local of the current frame.
+// Parameters arrive as plain values and are boxed on first use.
+func (vm *VM) localCell(localIndex int) *object.Cell {
+	slot := vm.currentFrame().basePointer + localIndex
+	if cell, ok := vm.stack[slot].(*object.Cell); ok {
+		return cell
+	}
+	cell := &object.Cell{Value: vm.stack[slot]}
+	vm.stack[slot] = cell
+	return cell
+}
+
+// callValue calls the function below the numArgs arguments on top of the stack.
+func (vm *VM) callValue(numArgs int) error {
+	// Callee is on stack before args
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		frame, err := vm.newClosureFrame(callee, numArgs)
+		if err != nil {
+			return vm.newRuntimeError("%s", err.Error())
+		}
+		if callee.Fn.Generator {
+			vm.sp = frame.basePointer - 1
+			err = vm.push(vm.newCoroutine(frame))
+			if err != nil {
+				return vm.newRuntimeError("%s", err.Error())
+			}
+			return nil
+		}
+		err = vm.pushFrame(frame)
+		if err != nil {
+			return vm.newRuntimeError("%s", err.Error())
+		}
+		vm.sp = frame.basePointer + callee.Fn.NumLocals
+
+	case *object.StructType:
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		instance, err := callee.New(args)
+		if err != nil {
+			return vm.newRuntimeError("%s", err.Error())
+		}
+		vm.sp = vm.sp - numArgs - 1
+		err = vm.push(instance)
+		if err != nil {
+			return vm.newRuntimeError("%s", err.Error())
+		}
+
+	case *object.Coroutine:
+		if numArgs > 1 {
+			return vm.newRuntimeError("wrong number of arguments to resume coroutine: want=0..1, got=%d", numArgs)
+		}
+		var value object.Object = Null
+		if numArgs == 1 {
+			value = vm.pop()
+		}
+		_, err := vm.resumeCoroutine(callee, value)
+		if err != nil {
+			return vm.newRuntimeError("%s", err.Error())
+		}
+
+	case *object.Builtin:
+		if cost, ok := vm.builtinCosts[callee.Name]; ok {
+			err := vm.charge(cost)
+			if err != nil {
+				return err
+			}
+		}
+
+		args := vm.stack[vm.sp-numArgs : vm.sp] // Get args slice
+		var start time.Time
+		if vm.profiler != nil {
+			start = time.Now()
+		}
+		result, err := vm.callBuiltin(callee, args)
+		if vm.profiler != nil {
+			vm.profileBuiltin(callee, time.Since(start))
+		}
+		if err != nil {
+			return err
+		}
+		vm.sp = vm.sp - numArgs - 1 // Pop args and function
+		if result != nil {
+			if rtErr, ok := result.(*object.Panic); ok {
+				return vm.newRuntimeError("%s", rtErr.Message)
+			}
+			if crit, ok := result.(*object.Critical); ok {
+				return vm.newRuntimeError("%s", crit.Message)
+			}
+			vm.push(result)
+		} else {
+			vm.push(Null)
+		}
+
+	default:
+		return vm.newRuntimeError("calling non-function")
+	}
+
+	return nil
+}
+
+// newClosureFrame checks the arguments of a call to cl, which are the top
+// numArgs stack elements, and returns the frame for the call. The extra
+// arguments of a variadic function are collected into an array, optional
+// parameters that were not passed start out as null, and execution starts at
+// the code assigning their default values.
+func (vm *VM) newClosureFrame(cl *object.Closure, numArgs int) (*Frame, error) {
+	fn := cl.Fn
+	required := fn.NumRequired()
+	positional := fn.NumParameters
+	if fn.Variadic {
+		positional--
+	}
+
+	if numArgs < required || (numArgs > positional && !fn.Variadic) {
+		switch {
+		case fn.Variadic:
+			return nil, fmt.Errorf("wrong number of arguments: want at least %d, got=%d", required, numArgs)
+		case fn.NumDefaults > 0:
+			return nil, fmt.Errorf("wrong number of arguments: want=%d..%d, got=%d", required, positional, numArgs)
+		default:
+			return nil, fmt.Errorf("wrong number of arguments: want=%d, got=%d", fn.NumParameters, numArgs)
+		}
+	}
+
+	basePointer := vm.sp - numArgs
+	if basePointer+fn.NumLocals > StackSize {
+		return nil, fmt.Errorf("stack overflow")
+	}
+
+	passed := min(numArgs, positional)
+	if fn.Variadic {
+		if err := vm.AllocArray(int64(numArgs-passed), int64(numArgs-passed)); err != nil {
+			return nil, err
+		}
+		rest := make([]object.Object, numArgs-passed)
+		copy(rest, vm.stack[basePointer+passed:vm.sp])
+		vm.stack[basePointer+positional] = &object.Array{Elements: rest}
+	}
+	for i := passed; i < positional; i++ {
+		vm.stack[basePointer+i] = Null
+	}
+	if vm.debugger != nil {
+		// Locals are not cleared otherwise; a debugger would show stale values
+		// for those not assigned yet
+		clear(vm.stack[basePointer+fn.NumParameters : basePointer+fn.NumLocals])
+	}
+
+	frame := NewFrame(cl, basePointer)
+	if fn.Entries != nil {
+		frame.ip = fn.Entries[passed-required] - 1
+	}
+	return frame, nil
+}
+
+// newCoroutine captures frame, a call of a generator function whose
+// arguments have been bound, as a coroutine that has not started.
+func (vm *VM) newCoroutine(frame *Frame) *object.Coroutine {
+	fn := frame.cl.Fn
+	locals := make([]object.Object, fn.NumLocals)
+	n := copy(locals, vm.stack[frame.basePointer:frame.basePointer+fn.NumParameters])
+	for i := n; i < len(locals); i++ {
+		locals[i] = Null
+	}
+	return &object.Coroutine{Closure: frame.cl, IP: frame.ip, Stack: locals}
+}
+
+// resumeCoroutine continues co above its callee slot on top of the stack:
+// its saved stack slots and try handlers are restored relative to a new base
+// pointer and its frame is pushed. Unless the coroutine is starting, value
+// becomes the result of the yield it is suspended at.
+func (vm *VM) resumeCoroutine(co *object.Coroutine, value object.Object) (*Frame, error) {
+	switch co.Status {
+	case object.CoroutineRunning:
+		return nil, fmt.Errorf("cannot resume running coroutine")
+	case object.CoroutineDead:
+		return nil, fmt.Errorf("cannot resume dead coroutine")
+	}
+
+	basePointer := vm.sp
+	if basePointer+len(co.Stack)+1 > StackSize {
+		return nil, fmt.Errorf("stack overflow")
+	}
+	frame := &Frame{cl: co.Closure, ip: co.IP, basePointer: basePointer, co: co}
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	vm.sp = basePointer + copy(vm.stack[basePointer:], co.Stack)
+	for _, h := range co.Handlers {
+		vm.handlers = append(vm.handlers, handler{
+			framesIndex: vm.framesIndex,
+			sp:          basePointer + h.SP,
+			target:      h.Target,
+		})
+	}
+	if co.Started {
+		vm.push(value)
+	}
+
+	co.Status = object.CoroutineRunning
+	co.Started = true
+	co.Stack = nil
+	co.Handlers = nil
+	return frame, nil
+}
+
+// yieldFrame suspends the coroutine running in the current frame and hands
+// value to whoever resumed it: a call gets it as its result, a range loop as
+// its next element. It reports true when the outermost frame was suspended.
+func (vm *VM) yieldFrame(value object.Object) (bool, error) {
+	frame := vm.currentFrame()
+	co := frame.co
+	if co == nil {
+		return false, fmt.Errorf("yield outside of coroutine")
+	}
+
+	n := len(vm.handlers)
+	for n > 0 && vm.handlers[n-1].framesIndex >= vm.framesIndex {
+		n--
+	}
+	co.Handlers = make([]object.CoroutineHandler, 0, len(vm.handlers)-n)
+	for _, h := range vm.handlers[n:] {
+		co.Handlers = append(co.Handlers, object.CoroutineHandler{SP: h.sp - frame.basePointer, Target: h.target})
+	}
+	vm.handlers = vm.handlers[:n]
+
+	co.Stack = make([]object.Object, vm.sp-frame.basePointer)
+	copy(co.Stack, vm.stack[frame.basePointer:vm.sp])
+	co.IP = frame.ip
+	co.Status = object.CoroutineSuspended
+
+	vm.lastPopped = value
+	vm.popFrame()
+	if vm.framesIndex == 0 {
+		return true, nil
+	}
+
+	vm.sp = frame.basePointer - 1 // -1 to pop the coroutine itself
+	if frame.iter != nil {
+		err := vm.push(&object.Integer{Value: frame.iter.Advance()})
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, vm.push(value)
+}
+
+// returnFromFrame pops the current frame and hands value to its caller. It
+// reports true when the outermost frame has returned. A coroutine that
+// returns is finished; if a range loop resumed it, the loop ends and value
+// is dropped.
+func (vm *VM) returnFromFrame(value object.Object) (bool, error) {
+	vm.lastPopped = value
+	frame := vm.popFrame()
+	if frame.co != nil {
+		frame.co.Status = object.CoroutineDead
+	}
+	if vm.framesIndex == 0 {
+		return true, nil
+	}
+
+	vm.sp = frame.basePointer - 1 // -1 to pop the function/closure itself
+	if frame.iter != nil {
+		vm.currentFrame().ip = frame.iterExit - 1
+		return false, nil
+	}
+	return false, vm.push(value)
+}
+
+// abandonCoroutines marks the coroutines running in the frames from
+// framesIndex up as dead, because an error is unwinding past them.
+func (vm *VM) abandonCoroutines(framesIndex int) {
+	for i := framesIndex; i < vm.framesIndex; i++ {
+		if co := vm.frames[i].co; co != nil {
+			co.Status = object.CoroutineDead
+		}
+	}
+}
+
+// spreadArguments replaces the argument arrays pushed for OpCallSpread with
+// their elements and returns the resulting number of arguments. The argument
+// list is charged like an array of that length.
+func (vm *VM) spreadArguments(numSegments int) (int, error) {
+	segments := make([][]object.Object, numSegments)
+	numArgs := 0
+	for i, segment := range vm.stack[vm.sp-numSegments : vm.sp] {
+		switch segment := segment.(type) {
+		case *object.Array:
+			segments[i] = segment.Elements
+		case *object.Tuple:
+			segments[i] = segment.Elements
+		default:
+			return 0, fmt.Errorf("cannot spread %s into call arguments", segment.Type())
+		}
+		numArgs += len(segments[i])
+	}
+	vm.sp -= numSegments
+
+	if err := vm.AllocArray(int64(numArgs), int64(numArgs)); err != nil {
+		return 0, err
+	}
+	for _, elements := range segments {
+		for _, el := range elements {
+			err := vm.push(el)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return numArgs, nil
+}
+
 func (vm *VM) pushClosure(constIndex int, numFree int) error {
 	constant := vm.constants[constIndex]
 	function, ok := constant.(*object.CompiledFunction)
@@ -1152,6 +2131,17 @@ func (vm *VM) pushClosure(constIndex int, numFree int) error {
 	return vm.push(closure)
 }
 
+// isInstance implements 'is' for a type held in a value, such as a struct type.
+func isInstance(obj, typ object.Object) (bool, error) {
+	switch typ := typ.(type) {
+	case *object.StructType:
+		instance, ok := obj.(*object.Struct)
+		return ok && instance.Def == typ, nil
+	default:
+		return false, fmt.Errorf("right side of 'is' must be a type, got %s", typ.Type())
+	}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return True
@@ -78,6 +78,53 @@ func TestConditionals(t *testing.T) {
 		{"if 1 > 2 { 10 } else { 20 }", 20},
 		{"if 1 > 2 { 10 }", Null},
 		{"if false { 10 }", Null},
+		{"if false { 10 } else if true { 20 } else { 30 }", 20},
+		{"if false { 10 } else if false { 20 } else { 30 }", 30},
+		{"if false { 10 } else if false { 20 }", Null},
+		{"if 1 > 2 { 1 } else if 2 > 3 { 2 } else if 3 > 2 { 3 } else { 4 }", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIfExpressionValues(t *testing.T) {
+	tests := []vmTestCase{
+		{"x := if true { 1 } else { 2 }; x", 1},
+		{"var x = if false { 1 } else { 2 }; x", 2},
+		{"var y = 5 + if true { 1 } else { 2 }; y", 6},
+		{
+			`
+			func grade(score) {
+				return if score >= 90 {
+					4
+				} else if score >= 80 {
+					3
+				} else if score >= 70 {
+					2
+				} else {
+					0
+				}
+			}
+			grade(95) * 100 + grade(85) * 10 + grade(10)
+			`,
+			430,
+		},
+		{
+			`
+			var n = 0
+			for i := 0; i < 6; i = i + 1 {
+				if i == 1 {
+					n = n + 1
+				} else if i == 3 {
+					n = n + 10
+				} else if i == 5 {
+					n = n + 100
+				}
+			}
+			n
+			`,
+			111,
+		},
 	}
 
 	runVmTests(t, tests)
@@ -280,6 +327,16 @@ func parse(input string) *ast.Program {
 	return p.ParseProgram()
 }
 
+// compileInput compiles input for tests that configure the VM themselves.
+func compileInput(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return comp.Bytecode()
+}
+
 func testExpectedObject(t *testing.T, expected any, actual object.Object) {
 	t.Helper()
 
@@ -289,6 +346,16 @@ func testExpectedObject(t *testing.T, expected any, actual object.Object) {
 		if err != nil {
 			t.Errorf("testIntegerObject failed: %s", err)
 		}
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s", err)
+		}
+	case string:
+		err := testStringObject(expected, actual)
+		if err != nil {
+			t.Errorf("testStringObject failed: %s", err)
+		}
 	case bool:
 		err := testBooleanObject(bool(expected), actual)
 		if err != nil {
@@ -356,6 +423,36 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testStringObject(expected string, actual object.Object) error {
+	result, ok := actual.(*object.String)
+	if !ok {
+		return fmt.Errorf("object is not String. got=%T (%+v)",
+			actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. want=%q, got=%q",
+			expected, result.Value)
+	}
+
+	return nil
+}
+
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)",
+			actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. want=%f, got=%f",
+			expected, result.Value)
+	}
+
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(*object.Boolean)
 	if !ok {